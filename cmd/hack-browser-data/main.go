@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/signal"
+	"runtime"
 	"strings"
+	"syscall"
 
 	"hack-browser-data/internal/log"
-	"hack-browser-data/internal/provider"
 	"hack-browser-data/internal/utils/fileutil"
+	"hack-browser-data/pkg/hbd"
 
 	"github.com/urfave/cli/v2"
 )
@@ -18,6 +23,25 @@ var (
 	verbose      bool
 	compress     bool
 	profilePath  string
+	concurrency  int
+	cookieFormat string
+
+	offlineDir      string
+	keyFile         string
+	chromiumKey     string
+	safeStorage     string
+	profileOS       string
+	passwordStore   string
+	firefoxPassword string
+	firefoxOSKey    string
+	keyProviders    string
+)
+
+// exit codes, automation tells a broken run from a partial one by them
+const (
+	exitFailure     = 1
+	exitPartial     = 2
+	exitInterrupted = 130
 )
 
 func main() {
@@ -33,10 +57,21 @@ func Execute() {
 		Flags: []cli.Flag{
 			&cli.BoolFlag{Name: "verbose", Aliases: []string{"vv"}, Destination: &verbose, Value: false, Usage: "verbose"},
 			&cli.BoolFlag{Name: "compress", Aliases: []string{"zip"}, Destination: &compress, Value: false, Usage: "compress result to zip"},
-			&cli.StringFlag{Name: "browser", Aliases: []string{"b"}, Destination: &browserName, Value: "all", Usage: "available browsers: all|" + strings.Join(provider.ListBrowsers(), "|")},
+			&cli.StringFlag{Name: "browser", Aliases: []string{"b"}, Destination: &browserName, Value: "all", Usage: "available browsers: all|" + strings.Join(hbd.ListBrowsers(), "|")},
 			&cli.StringFlag{Name: "results-dir", Aliases: []string{"dir"}, Destination: &outputDir, Value: "results", Usage: "export dir"},
-			&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Destination: &outputFormat, Value: "csv", Usage: "file name csv|json"},
+			&cli.StringFlag{Name: "format", Aliases: []string{"f"}, Destination: &outputFormat, Value: "csv", Usage: "file name csv|json|jsonl|sqlite"},
 			&cli.StringFlag{Name: "profile-path", Aliases: []string{"p"}, Destination: &profilePath, Value: "", Usage: "custom profile dir path, get with chrome://version"},
+			&cli.StringFlag{Name: "cookie-format", Destination: &cookieFormat, Value: "", Usage: "also export cookies as netscape|editor, comma separated"},
+			&cli.IntFlag{Name: "concurrency", Aliases: []string{"j"}, Destination: &concurrency, Value: runtime.NumCPU(), Usage: "number of profiles and sources processed in parallel"},
+			&cli.StringFlag{Name: "offline", Destination: &offlineDir, Value: "", Usage: "parse profiles copied under this dir, never use the local keystore"},
+			&cli.StringFlag{Name: "key-file", Destination: &keyFile, Value: "", Usage: "key material json of the file key provider: chromium_key|safe_storage|profile_os|password_store|firefox_password|firefox_os_key"},
+			&cli.StringFlag{Name: "chromium-key", Destination: &chromiumKey, Value: "", Usage: "hex encoded chromium AES key"},
+			&cli.StringFlag{Name: "safe-storage", Destination: &safeStorage, Value: "", Usage: "chromium safe storage secret, the key is derived from it"},
+			&cli.StringFlag{Name: "profile-os", Destination: &profileOS, Value: "", Usage: "offline os the profiles come from: windows|darwin|linux, default current os"},
+			&cli.StringFlag{Name: "password-store", Destination: &passwordStore, Value: "", Usage: "linux store of the chromium secret: basic|gnome-libsecret|kwallet5|kwallet6, default all"},
+			&cli.StringFlag{Name: "firefox-password", Destination: &firefoxPassword, Value: "", Usage: "firefox primary password, if set"},
+			&cli.StringFlag{Name: "firefox-os-key", Destination: &firefoxOSKey, Value: "", Usage: "base64 encoded key of Firefox Encrypted Storage, card numbers are encrypted with it"},
+			&cli.StringFlag{Name: "key-providers", Destination: &keyProviders, Value: "", Usage: "key providers tried in order: static|file|env|keystore|basic|prompt, comma separated, default static,file,env,keystore,basic"},
 		},
 		HideHelpCommand: true,
 		Action: func(c *cli.Context) error {
@@ -45,30 +80,71 @@ func Execute() {
 			} else {
 				log.Init("notice")
 			}
-
-			browsers, err := provider.PickBrowsers(browserName, profilePath)
-			if err != nil {
-				log.Error(err)
+			keys := &hbd.Keys{
+				SafeStorage:     []byte(safeStorage),
+				ProfileOS:       profileOS,
+				PasswordStore:   passwordStore,
+				FirefoxPassword: []byte(firefoxPassword),
 			}
-
-			for _, b := range browsers {
-				data, err := b.BrowsingData()
-				if err != nil {
-					log.Error(err)
-				}
-				data.Output(outputDir, b.Name(), outputFormat)
+			if err := keys.SetChromiumKey(chromiumKey); err != nil {
+				return err
+			}
+			if err := keys.SetFirefoxOSKey(firefoxOSKey); err != nil {
+				return err
 			}
-			if compress {
-				if err = fileutil.CompressDir(outputDir); err != nil {
-					log.Error(err)
-				}
-				log.Noticef("compress success")
+			var providers []string
+			if keyProviders != "" {
+				providers = strings.Split(keyProviders, ",")
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			handleInterrupt(cancel)
+			report, err := hbd.ExportContext(ctx, hbd.Options{
+				Browser:       browserName,
+				ProfilePath:   profilePath,
+				OfflineDir:    offlineDir,
+				Keys:          *keys,
+				KeyFile:       keyFile,
+				KeyProviders:  providers,
+				OutputDir:     outputDir,
+				Format:        outputFormat,
+				CookieFormats: strings.Split(cookieFormat, ","),
+				Compress:      compress,
+				Concurrency:   concurrency,
+			})
+			if errors.Is(err, context.Canceled) {
+				return cli.Exit("interrupted, see "+hbd.ReportFile, exitInterrupted)
+			}
+			if err != nil {
+				return err
+			}
+			switch report.Status {
+			case hbd.RunFailed:
+				return cli.Exit("nothing was recovered, see "+hbd.ReportFile, exitFailure)
+			case hbd.RunPartial:
+				return cli.Exit("some items failed, see "+hbd.ReportFile, exitPartial)
 			}
 			return nil
 		},
 	}
-	err := app.Run(os.Args)
-	if err != nil {
-		panic(err)
+	if err := app.Run(os.Args); err != nil {
+		log.Error(err)
+		os.Exit(exitFailure)
 	}
 }
+
+// handleInterrupt cancels the run on the first SIGINT or SIGTERM, running
+// browsers are still written. The second one removes the copies of browser
+// files and exits at once.
+func handleInterrupt(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		log.Notice("interrupted, finishing running browsers, interrupt again to quit now")
+		cancel()
+		<-signals
+		fileutil.RemoveWorkDirs()
+		os.Exit(exitInterrupted)
+	}()
+}
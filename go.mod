@@ -6,6 +6,7 @@ require (
 	github.com/fatih/color v1.13.0
 	github.com/gocarina/gocsv v0.0.0-20220927221512-ad3251f9fa25
 	github.com/godbus/dbus/v5 v5.1.0
+	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db
 	github.com/gookit/color v1.5.2
 	github.com/gookit/slog v0.3.4
 	github.com/mattn/go-sqlite3 v1.14.16
@@ -16,12 +17,13 @@ require (
 	github.com/urfave/cli/v2 v2.23.0
 	golang.org/x/crypto v0.1.0
 	golang.org/x/exp v0.0.0-20221028150844-83b7d23a625f
+	golang.org/x/net v0.1.0
+	golang.org/x/term v0.1.0
 	golang.org/x/text v0.4.0
 )
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
-	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
 	github.com/gookit/goutil v0.5.15 // indirect
 	github.com/gookit/gsr v0.0.8 // indirect
 	github.com/mattn/go-colorable v0.1.9 // indirect
@@ -0,0 +1,315 @@
+package address
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/fileutil"
+	"hack-browser-data/internal/utils/sqliteutil"
+	"hack-browser-data/internal/utils/typeutil"
+
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/tidwall/gjson"
+)
+
+type ChromiumAddress []Address
+
+// Address is an address saved for autofill
+type Address struct {
+	GUID         string
+	Name         string
+	Organization string
+	Street       string
+	City         string
+	State        string
+	PostalCode   string
+	Country      string
+	Phone        string
+	Email        string
+	ModifyDate   time.Time
+}
+
+// chromiumAddressQueries select addresses of autofill_profiles, names,
+// emails and phones are kept in tables of their own. Older builds split
+// the street into address_line_1 and address_line_2.
+var chromiumAddressQueries = []sqliteutil.Query{
+	{
+		Table: "autofill_profiles",
+		Columns: []sqliteutil.Column{
+			{Name: "guid"}, {Name: "company_name", Default: "''"}, {Name: "street_address"}, {Name: "city", Default: "''"},
+			{Name: "state", Default: "''"}, {Name: "zipcode", Default: "''"}, {Name: "country_code", Default: "''"},
+			{Name: "date_modified", Default: "0"},
+		},
+	},
+	{
+		Table: "autofill_profiles",
+		Columns: []sqliteutil.Column{
+			{Name: "guid"}, {Name: "company_name", Default: "''"}, {Name: "address_line_1"}, {Name: "city", Default: "''"},
+			{Name: "state", Default: "''"}, {Name: "zipcode", Default: "''"}, {Name: "country_code", Default: "''"},
+			{Name: "date_modified", Default: "0"},
+		},
+	},
+}
+
+// chromiumAddressFields select the fields of autofill_profiles kept in
+// tables of their own, a profile may have several, the first one is used.
+var chromiumAddressFields = []struct {
+	query sqliteutil.Query
+	set   func(a *Address, values []string)
+}{
+	{
+		query: sqliteutil.Query{Table: "autofill_profile_names", Columns: []sqliteutil.Column{
+			{Name: "guid"}, {Name: "full_name", Default: "''"}, {Name: "first_name", Default: "''"},
+			{Name: "middle_name", Default: "''"}, {Name: "last_name", Default: "''"},
+		}},
+		set: func(a *Address, v []string) { a.Name = fullName(v[0], v[1:]...) },
+	},
+	{
+		query: sqliteutil.Query{Table: "autofill_profile_emails", Columns: []sqliteutil.Column{{Name: "guid"}, {Name: "email"}}},
+		set:   func(a *Address, v []string) { a.Email = v[0] },
+	},
+	{
+		query: sqliteutil.Query{Table: "autofill_profile_phones", Columns: []sqliteutil.Column{{Name: "guid"}, {Name: "number"}}},
+		set:   func(a *Address, v []string) { a.Phone = v[0] },
+	},
+}
+
+// chromiumTokenTables are the tables of addresses and their fields of
+// newer chromium builds, a row per field with its type. Tables of the
+// newest builds go first.
+var chromiumTokenTables = []struct {
+	table, tokens string
+}{
+	{"addresses", "address_type_tokens"},
+	{"local_addresses", "local_addresses_type_tokens"},
+}
+
+// types of autofill fields in the type tokens tables
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/autofill/core/browser/field_types.h
+const (
+	typeNameFirst     = 3
+	typeNameMiddle    = 4
+	typeNameLast      = 5
+	typeNameFull      = 7
+	typeEmail         = 9
+	typePhone         = 14
+	typeCity          = 33
+	typeState         = 34
+	typeZip           = 35
+	typeCountry       = 36
+	typeCompany       = 60
+	typeStreetAddress = 77
+)
+
+func (c *ChromiumAddress) Parse(_ decrypter.Keys, path string) error {
+	addressDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer addressDB.Close()
+	schema, err := sqliteutil.ReadSchema(addressDB)
+	if err != nil {
+		return err
+	}
+	for _, t := range chromiumTokenTables {
+		columns, err := schema.Columns(t.tokens)
+		if err != nil {
+			return err
+		}
+		if len(columns) > 0 {
+			return c.parseTokens(addressDB, t.table, t.tokens)
+		}
+	}
+	return c.parseProfiles(schema)
+}
+
+// parseProfiles reads addresses of autofill_profiles
+func (c *ChromiumAddress) parseProfiles(schema *sqliteutil.Schema) error {
+	rows, err := schema.Query(chromiumAddressQueries...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	index := make(map[string]int)
+	for rows.Next() {
+		var (
+			a        Address
+			modified int64
+		)
+		if err := rows.Scan(&a.GUID, &a.Organization, &a.Street, &a.City, &a.State, &a.PostalCode, &a.Country, &modified); err != nil {
+			log.Warn(err)
+		}
+		a.ModifyDate = typeutil.TimeStamp(modified)
+		index[a.GUID] = len(*c)
+		*c = append(*c, a)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, f := range chromiumAddressFields {
+		if err := c.readField(schema, f.query, index, f.set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readField sets a field of the addresses in index from the table of q,
+// a missing table leaves the field empty
+func (c *ChromiumAddress) readField(schema *sqliteutil.Schema, q sqliteutil.Query, index map[string]int, set func(*Address, []string)) error {
+	columns, err := schema.Columns(q.Table)
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+	rows, err := schema.Query(q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var (
+			guid   string
+			values = make([]string, len(q.Columns)-1)
+			dest   = []any{&guid}
+		)
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			log.Warn(err)
+			continue
+		}
+		i, ok := index[guid]
+		if !ok || seen[guid] {
+			continue
+		}
+		seen[guid] = true
+		set(&(*c)[i], values)
+	}
+	return rows.Err()
+}
+
+// parseTokens reads addresses of table, their fields are rows of tokens
+func (c *ChromiumAddress) parseTokens(db *sql.DB, table, tokens string) error {
+	rows, err := db.Query(fmt.Sprintf(`SELECT t.guid, t.type, t.value, IFNULL(a.date_modified, 0)
+		FROM %s t LEFT JOIN %s a ON a.guid = t.guid ORDER BY a.date_modified DESC, t.guid`, tokens, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	index := make(map[string]int)
+	names := make(map[string][]string)
+	for rows.Next() {
+		var (
+			guid, value   string
+			typ, modified int64
+		)
+		if err := rows.Scan(&guid, &typ, &value, &modified); err != nil {
+			log.Warn(err)
+			continue
+		}
+		i, ok := index[guid]
+		if !ok {
+			i = len(*c)
+			index[guid] = i
+			*c = append(*c, Address{GUID: guid, ModifyDate: typeutil.TimeStamp(modified)})
+			names[guid] = make([]string, 3)
+		}
+		a := &(*c)[i]
+		switch typ {
+		case typeNameFull:
+			a.Name = value
+		case typeNameFirst:
+			names[guid][0] = value
+		case typeNameMiddle:
+			names[guid][1] = value
+		case typeNameLast:
+			names[guid][2] = value
+		case typeEmail:
+			a.Email = value
+		case typePhone:
+			a.Phone = value
+		case typeCompany:
+			a.Organization = value
+		case typeStreetAddress:
+			a.Street = value
+		case typeCity:
+			a.City = value
+		case typeState:
+			a.State = value
+		case typeZip:
+			a.PostalCode = value
+		case typeCountry:
+			a.Country = value
+		}
+	}
+	for guid, i := range index {
+		a := &(*c)[i]
+		a.Name = fullName(a.Name, names[guid]...)
+	}
+	return rows.Err()
+}
+
+// fullName returns full, or the parts joined if it's empty
+func fullName(full string, parts ...string) string {
+	if full != "" {
+		return full
+	}
+	var l []string
+	for _, p := range parts {
+		if p != "" {
+			l = append(l, p)
+		}
+	}
+	return strings.Join(l, " ")
+}
+
+func (c *ChromiumAddress) Name() string {
+	return "address"
+}
+
+func (c *ChromiumAddress) Length() int {
+	return len(*c)
+}
+
+type FirefoxAddress []Address
+
+// Parse reads addresses of autofill-profiles.json, the name is kept in
+// name or split into given-name, additional-name and family-name.
+func (f *FirefoxAddress) Parse(_ decrypter.Keys, path string) error {
+	s, err := fileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, v := range gjson.Get(s, "addresses").Array() {
+		*f = append(*f, Address{
+			GUID: v.Get("guid").String(),
+			Name: fullName(v.Get("name").String(),
+				v.Get("given-name").String(), v.Get("additional-name").String(), v.Get("family-name").String()),
+			Organization: v.Get("organization").String(),
+			Street:       v.Get("street-address").String(),
+			City:         v.Get("address-level2").String(),
+			State:        v.Get("address-level1").String(),
+			PostalCode:   v.Get("postal-code").String(),
+			Country:      v.Get("country").String(),
+			Phone:        v.Get("tel").String(),
+			Email:        v.Get("email").String(),
+			ModifyDate:   typeutil.TimeStamp(v.Get("timeLastModified").Int() / 1000),
+		})
+	}
+	return nil
+}
+
+func (f *FirefoxAddress) Name() string {
+	return "address"
+}
+
+func (f *FirefoxAddress) Length() int {
+	return len(*f)
+}
@@ -0,0 +1,79 @@
+package address
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func webDataDB(t *testing.T, stmts ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Web Data")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestChromiumAddressSchemas(t *testing.T) {
+	t.Parallel()
+	tokens := func(table, tokens string) []string {
+		return []string{
+			`CREATE TABLE ` + table + ` (guid VARCHAR PRIMARY KEY, use_count INTEGER, use_date INTEGER, date_modified INTEGER)`,
+			`CREATE TABLE ` + tokens + ` (guid VARCHAR, type INTEGER, value VARCHAR, verification_status INTEGER)`,
+			`INSERT INTO ` + table + ` VALUES ('g1', 1, 0, 1672628645)`,
+			`INSERT INTO ` + tokens + ` VALUES ('g1', 3, 'Alice', 0), ('g1', 5, 'Liddell', 0),
+				('g1', 9, 'alice@example.com', 0), ('g1', 77, '1 Rabbit Hole', 0), ('g1', 33, 'Oxford', 0),
+				('g1', 36, 'GB', 0), ('g1', 1000, 'unknown', 0)`,
+		}
+	}
+	tokensWant := Address{GUID: "g1", Name: "Alice Liddell", Street: "1 Rabbit Hole", City: "Oxford", Country: "GB", Email: "alice@example.com"}
+	tests := []struct {
+		name  string
+		stmts []string
+		want  Address
+	}{
+		{
+			name: "address_line_1 without names",
+			stmts: []string{
+				`CREATE TABLE autofill_profiles (guid VARCHAR PRIMARY KEY, company_name VARCHAR, address_line_1 VARCHAR,
+					address_line_2 VARCHAR, city VARCHAR, state VARCHAR, zipcode VARCHAR, country VARCHAR, country_code VARCHAR,
+					date_modified INTEGER NOT NULL DEFAULT 0)`,
+				`INSERT INTO autofill_profiles VALUES ('g1', 'Wonderland Ltd', '1 Rabbit Hole', '', 'Oxford', NULL, 'OX1 1DP',
+					'United Kingdom', 'GB', 1672628645)`,
+			},
+			want: Address{GUID: "g1", Organization: "Wonderland Ltd", Street: "1 Rabbit Hole", City: "Oxford", PostalCode: "OX1 1DP", Country: "GB"},
+		},
+		{name: "local_addresses tokens", stmts: tokens("local_addresses", "local_addresses_type_tokens"), want: tokensWant},
+		{name: "addresses tokens", stmts: tokens("addresses", "address_type_tokens"), want: tokensWant},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var c ChromiumAddress
+			if err := c.Parse(nil, webDataDB(t, tt.stmts...)); err != nil {
+				t.Fatal(err)
+			}
+			if len(c) != 1 {
+				t.Fatalf("parsed %d addresses, want 1", len(c))
+			}
+			got := c[0]
+			if want := time.Unix(1672628645, 0); !got.ModifyDate.Equal(want) {
+				t.Errorf("ModifyDate = %s, want %s", got.ModifyDate, want)
+			}
+			got.ModifyDate = tt.want.ModifyDate
+			if got != tt.want {
+				t.Errorf("address = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
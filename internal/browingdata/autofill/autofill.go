@@ -0,0 +1,142 @@
+package autofill
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/sqliteutil"
+	"hack-browser-data/internal/utils/typeutil"
+
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type ChromiumAutofill []Entry
+
+// Entry is a value typed into a form field, Name is the name of the field.
+// Firefox keeps them as form history.
+type Entry struct {
+	Name         string
+	Value        string
+	Count        int
+	CreateDate   time.Time
+	LastUsedDate time.Time
+}
+
+// chromiumAutofillQueries select entries of the autofill table, dates are
+// kept in the table since schema version 55. Older schemas keep a date per
+// use in autofill_dates.
+var chromiumAutofillQueries = []sqliteutil.Query{
+	{
+		Table: "autofill",
+		Columns: []sqliteutil.Column{
+			{Name: "name"}, {Name: "value"}, {Name: "count", Default: "0"},
+			{Name: "date_created"}, {Name: "date_last_used", Default: "0"},
+		},
+	},
+	{
+		Table: "autofill",
+		Alias: "a",
+		Joins: map[string]string{"d": "autofill_dates"},
+		Columns: []sqliteutil.Column{
+			{Name: "name"}, {Name: "value"}, {Name: "count", Default: "0"},
+			{Table: "d", Name: "date_created", Expr: "MIN(d.date_created)", Default: "0"},
+			{Table: "d", Name: "date_created", Expr: "MAX(d.date_created)", Default: "0"},
+		},
+		Suffix: `LEFT JOIN autofill_dates d ON d.pair_id = a.pair_id GROUP BY a.pair_id`,
+	},
+}
+
+func (c *ChromiumAutofill) Parse(_ decrypter.Keys, path string) error {
+	autofillDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer autofillDB.Close()
+	schema, err := sqliteutil.ReadSchema(autofillDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumAutofillQueries...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			name, value       string
+			count             int
+			created, lastUsed int64
+		)
+		if err := rows.Scan(&name, &value, &count, &created, &lastUsed); err != nil {
+			log.Warn(err)
+		}
+		*c = append(*c, Entry{
+			Name:         name,
+			Value:        value,
+			Count:        count,
+			CreateDate:   typeutil.TimeStamp(created),
+			LastUsedDate: typeutil.TimeStamp(lastUsed),
+		})
+	}
+	sort.Slice(*c, func(i, j int) bool {
+		return (*c)[i].LastUsedDate.After((*c)[j].LastUsedDate)
+	})
+	return rows.Err()
+}
+
+func (c *ChromiumAutofill) Name() string {
+	return "autofill"
+}
+
+func (c *ChromiumAutofill) Length() int {
+	return len(*c)
+}
+
+type FirefoxFormHistory []Entry
+
+const queryFirefoxFormHistory = `SELECT fieldname, value, IFNULL(timesUsed, 0), IFNULL(firstUsed, 0), IFNULL(lastUsed, 0)
+	FROM moz_formhistory ORDER BY lastUsed DESC`
+
+// Parse reads moz_formhistory of formhistory.sqlite, times are microseconds
+func (f *FirefoxFormHistory) Parse(_ decrypter.Keys, path string) error {
+	formDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer formDB.Close()
+	rows, err := formDB.Query(queryFirefoxFormHistory)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			name, value     string
+			count           int
+			first, lastUsed int64
+		)
+		if err := rows.Scan(&name, &value, &count, &first, &lastUsed); err != nil {
+			log.Warn(err)
+		}
+		*f = append(*f, Entry{
+			Name:         name,
+			Value:        value,
+			Count:        count,
+			CreateDate:   typeutil.TimeStamp(first / 1000000),
+			LastUsedDate: typeutil.TimeStamp(lastUsed / 1000000),
+		})
+	}
+	return rows.Err()
+}
+
+func (f *FirefoxFormHistory) Name() string {
+	return "autofill"
+}
+
+func (f *FirefoxFormHistory) Length() int {
+	return len(*f)
+}
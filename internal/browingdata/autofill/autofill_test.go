@@ -0,0 +1,75 @@
+package autofill
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func webDataDB(t *testing.T, stmts ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Web Data")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestChromiumAutofillSchemas(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		stmts []string
+	}{
+		{
+			name: "autofill_dates",
+			stmts: []string{
+				`CREATE TABLE autofill (name VARCHAR, value VARCHAR, value_lower VARCHAR, pair_id INTEGER PRIMARY KEY,
+					count INTEGER DEFAULT 1)`,
+				`CREATE TABLE autofill_dates (pair_id INTEGER DEFAULT 0, date_created INTEGER DEFAULT 0)`,
+				`INSERT INTO autofill VALUES ('email', 'alice@example.com', 'alice@example.com', 1, 2)`,
+				`INSERT INTO autofill_dates VALUES (1, 1675307045), (1, 1672628645)`,
+			},
+		},
+		{
+			name: "date_last_used",
+			stmts: []string{
+				`CREATE TABLE autofill (name VARCHAR, value VARCHAR, value_lower VARCHAR, date_created INTEGER DEFAULT 0,
+					date_last_used INTEGER DEFAULT 0, count INTEGER DEFAULT 1, PRIMARY KEY (name, value))`,
+				`INSERT INTO autofill VALUES ('email', 'alice@example.com', 'alice@example.com', 1672628645, 1675307045, 2)`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var c ChromiumAutofill
+			if err := c.Parse(nil, webDataDB(t, tt.stmts...)); err != nil {
+				t.Fatal(err)
+			}
+			want := Entry{
+				Name:         "email",
+				Value:        "alice@example.com",
+				Count:        2,
+				CreateDate:   time.Unix(1672628645, 0),
+				LastUsedDate: time.Unix(1675307045, 0),
+			}
+			if len(c) != 1 {
+				t.Fatalf("parsed %d entries, want 1", len(c))
+			}
+			if got := c[0]; got.Name != want.Name || got.Value != want.Value || got.Count != want.Count ||
+				!got.CreateDate.Equal(want.CreateDate) || !got.LastUsedDate.Equal(want.LastUsedDate) {
+				t.Errorf("entry = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
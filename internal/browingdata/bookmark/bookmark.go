@@ -2,11 +2,10 @@ package bookmark
 
 import (
 	"database/sql"
-	"os"
 	"sort"
 	"time"
 
-	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/decrypter"
 	"hack-browser-data/internal/log"
 	"hack-browser-data/internal/utils/fileutil"
 	"hack-browser-data/internal/utils/typeutil"
@@ -16,9 +15,9 @@ import (
 	"github.com/tidwall/gjson"
 )
 
-type ChromiumBookmark []bookmark
+type ChromiumBookmark []Bookmark
 
-type bookmark struct {
+type Bookmark struct {
 	ID        int64
 	Name      string
 	Type      string
@@ -26,12 +25,11 @@ type bookmark struct {
 	DateAdded time.Time
 }
 
-func (c *ChromiumBookmark) Parse(masterKey []byte) error {
-	bookmarks, err := fileutil.ReadFile(item.TempChromiumBookmark)
+func (c *ChromiumBookmark) Parse(masterKey decrypter.Keys, path string) error {
+	bookmarks, err := fileutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempChromiumBookmark)
 	r := gjson.Parse(bookmarks)
 	if r.Exists() {
 		roots := r.Get("roots")
@@ -57,7 +55,7 @@ func getBookmarkChildren(value gjson.Result, w *ChromiumBookmark) (children gjso
 		bookmarkChildren = "children"
 	)
 	nodeType := value.Get(bookmarkType)
-	bm := bookmark{
+	bm := Bookmark{
 		ID:        value.Get(bookmarkID).Int(),
 		Name:      value.Get(bookmarkName).String(),
 		URL:       value.Get(bookmarkURL).String(),
@@ -93,24 +91,23 @@ func (c *ChromiumBookmark) Length() int {
 	return len(*c)
 }
 
-type FirefoxBookmark []bookmark
+type FirefoxBookmark []Bookmark
 
 const (
 	queryFirefoxBookMark = `SELECT id, url, type, dateAdded, title FROM (SELECT * FROM moz_bookmarks INNER JOIN moz_places ON moz_bookmarks.fk=moz_places.id)`
 	closeJournalMode     = `PRAGMA journal_mode=off`
 )
 
-func (f *FirefoxBookmark) Parse(masterKey []byte) error {
+func (f *FirefoxBookmark) Parse(masterKey decrypter.Keys, path string) error {
 	var (
 		err          error
 		keyDB        *sql.DB
 		bookmarkRows *sql.Rows
 	)
-	keyDB, err = sql.Open("sqlite3", item.TempFirefoxBookmark)
+	keyDB, err = sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempFirefoxBookmark)
 	defer keyDB.Close()
 	_, err = keyDB.Exec(closeJournalMode)
 	if err != nil {
@@ -129,7 +126,7 @@ func (f *FirefoxBookmark) Parse(masterKey []byte) error {
 		if err = bookmarkRows.Scan(&id, &url, &bType, &dateAdded, &title); err != nil {
 			log.Warn(err)
 		}
-		*f = append(*f, bookmark{
+		*f = append(*f, Bookmark{
 			ID:        id,
 			Name:      title,
 			Type:      bookmarkType(bType),
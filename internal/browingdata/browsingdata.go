@@ -1,135 +1,305 @@
 package browingdata
 
 import (
-	"os"
-	"path"
-	"strings"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
 
+	"hack-browser-data/internal/browingdata/address"
+	"hack-browser-data/internal/browingdata/autofill"
 	"hack-browser-data/internal/browingdata/bookmark"
 	"hack-browser-data/internal/browingdata/cookie"
 	"hack-browser-data/internal/browingdata/creditcard"
 	"hack-browser-data/internal/browingdata/download"
 	"hack-browser-data/internal/browingdata/extension"
 	"hack-browser-data/internal/browingdata/history"
+	"hack-browser-data/internal/browingdata/indexeddb"
 	"hack-browser-data/internal/browingdata/localstorage"
 	"hack-browser-data/internal/browingdata/password"
+	"hack-browser-data/internal/browingdata/searchterm"
+	"hack-browser-data/internal/browingdata/session"
+	"hack-browser-data/internal/decrypter"
 	"hack-browser-data/internal/item"
 	"hack-browser-data/internal/log"
 	"hack-browser-data/internal/utils/fileutil"
 )
 
 type Data struct {
+	items   []item.Item
 	sources map[item.Item]Source
+	// sink receives rows instead of sources if it's set
+	sink Sink
+
+	mu      sync.Mutex
+	reports map[item.Item]ItemReport
+	// keyProvider is the name of the provider of the master key
+	keyProvider string
 }
 
 type Source interface {
-	Parse(masterKey []byte) error
+	// Parse reads the item at path, which may be a copy private to this run
+	Parse(masterKey decrypter.Keys, path string) error
 
 	Name() string
 
 	Length() int
 }
 
-type CookieSplicing interface {
-	SaveCookie(outDir string, outFmt string)
+// Streamer is a Source able to hand rows to emit while parsing, rows are
+// emitted in the order they're read and none of them is kept.
+type Streamer interface {
+	Stream(masterKey decrypter.Keys, path string, emit func(row any) error) error
 }
 
-func New(sources []item.Item) *Data {
+// Sink receives rows of the artifact, see RowWriter
+type Sink func(artifact string, row any) error
+
+// New returns Data of the requested items, items missing in the profile
+// are reported as missing by Recovery. If sink is not nil, rows are handed
+// to it while parsed and sources of Data are left empty.
+func New(sources []item.Item, sink Sink) *Data {
 	bd := &Data{
+		items:   sources,
 		sources: make(map[item.Item]Source),
+		sink:    sink,
+		reports: make(map[item.Item]ItemReport),
 	}
 	bd.addSource(sources)
 	return bd
 }
 
-func (d *Data) Recovery(masterKey []byte) error {
-	for _, source := range d.sources {
-		if err := source.Parse(masterKey); err != nil {
-			log.Errorf("parse %s error %s", source.Name(), err.Error())
+// parseLimit limits sources parsed at the same time by all browsers
+var parseLimit = make(chan struct{}, 1)
+
+// SetConcurrency sets how many sources are parsed at the same time,
+// it must be called before any Recovery.
+func SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	parseLimit = make(chan struct{}, n)
+}
+
+// Recovery parses every source from the path of its item, sources are
+// parsed concurrently as allowed by SetConcurrency. The outcome of every
+// item is kept in Report, the error tells how many items failed.
+func (d *Data) Recovery(masterKey decrypter.Keys, paths map[item.Item]string) error {
+	// rows reach the sink in the order of items, whichever source is done first
+	sinks := make([]Sink, len(d.items))
+	for n := range sinks {
+		sinks[n] = d.sink
+	}
+	seq := NewSequence(sinks)
+	done := func(n int) {
+		if err := seq.Done(n); err != nil {
+			log.Errorf("write rows error %s", err.Error())
 		}
 	}
+	var wg sync.WaitGroup
+	for n, i := range d.items {
+		source, ok := d.sources[i]
+		path, found := paths[i]
+		switch {
+		case i.FileName() == item.UnsupportedItem:
+			d.SetStatus(i, StatusSkipped, nil)
+			done(n)
+			continue
+		case !found:
+			d.SetStatus(i, StatusMissing, nil)
+			done(n)
+			continue
+		case !ok:
+			// key files are read by the browser, not parsed as a source
+			d.SetStatus(i, StatusOK, nil)
+			done(n)
+			continue
+		}
+		var sink Sink
+		if d.sink != nil {
+			sink = seq.Sink(n)
+		}
+		wg.Add(1)
+		go func(n int, i item.Item, source Source, path string) {
+			defer wg.Done()
+			parseLimit <- struct{}{}
+			defer func() { <-parseLimit }()
+			rows, err := d.parse(i, source, masterKey, path, sink)
+			if err != nil {
+				log.Errorf("parse %s error %s", source.Name(), err.Error())
+			}
+			d.setReport(i, rows, err)
+			done(n)
+		}(n, i, source, path)
+	}
+	wg.Wait()
+	var failed int
+	for _, r := range d.Report() {
+		if r.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d items failed", failed, len(d.items))
+	}
 	return nil
 }
 
-func (d *Data) Output(dir, browserName, flag string) {
-	output := NewOutPutter(flag)
+// parse parses source, or streams it to sink if it's set, and returns the
+// number of rows. A panic of source is turned into an error, so one broken item
+// doesn't abort the whole run.
+func (d *Data) parse(i item.Item, source Source, masterKey decrypter.Keys, path string, sink Sink) (rows int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("parse panic: %v", r)
+		}
+	}()
+	if sink == nil {
+		err = source.Parse(masterKey, path)
+		return source.Length(), err
+	}
+	emit := func(row any) error {
+		rows++
+		return sink(source.Name(), row)
+	}
+	if s, ok := source.(Streamer); ok {
+		return rows, s.Stream(masterKey, path, emit)
+	}
+	// other sources are small, they're parsed as a whole into a source of
+	// their own and handed over, the source of Data is left empty
+	parsed := newSource(i)
+	err = parsed.Parse(masterKey, path)
+	if sinkErr := eachRow(parsed, emit); sinkErr != nil {
+		return rows, sinkErr
+	}
+	return rows, err
+}
+
+// Sources returns the sources of browsing data ordered by item
+func (d *Data) Sources() []Source {
+	items := make([]item.Item, 0, len(d.sources))
+	for i := range d.sources {
+		items = append(items, i)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i] < items[j]
+	})
+	sources := make([]Source, 0, len(items))
+	for _, i := range items {
+		sources = append(sources, d.sources[i])
+	}
+	return sources
+}
 
-	for _, source := range d.sources {
+// Output writes every source to its file under dir, and cookies to the
+// cookie formats of output as well.
+func (d *Data) Output(dir, browserName string, output *OutPutter) {
+	for _, source := range d.Sources() {
 		if source.Length() == 0 {
 			// if the length of the export data is 0, then it is not necessary to output
 			continue
 		}
 		filename := fileutil.ItemName(browserName, source.Name(), output.Ext())
-
-		f, err := output.CreateFile(dir, filename)
-		if err != nil {
-			log.Errorf("create file %s error %s", filename, err.Error())
-			continue
-		}
-		if err := output.Write(source, f); err != nil {
-			log.Errorf("write to file %s error %s", filename, err.Error())
+		if err := output.writeFile(dir, filename, func(w io.Writer) error {
+			return output.Write(source, w)
+		}); err != nil {
+			log.Error(err)
 			continue
 		}
-		if err := f.Close(); err != nil {
-			log.Errorf("close file %s error %s", filename, err.Error())
-			continue
-		}
-		log.Noticef("output to file %s success", strings.ReplaceAll(path.Join(dir, filename), "/", "\\"))
 
-		switch source.(type) {
+		var cookies []cookie.Cookie
+		switch s := source.(type) {
 		case *cookie.ChromiumCookie:
-			source.(*cookie.ChromiumCookie).SaveCookie(dir, browserName)
+			cookies = *s
 		case *cookie.FirefoxCookie:
-			source.(*cookie.FirefoxCookie).SaveCookie(dir, browserName)
+			cookies = *s
 		}
-
-		if _, err = os.Stat(item.TempChromiumKey); err == nil && !os.IsNotExist(err) {
-			_ = os.Remove(item.TempChromiumKey)
+		if cookies == nil {
+			continue
 		}
-
-		if _, err = os.Stat(item.TempFirefoxKey4); err == nil && !os.IsNotExist(err) {
-			_ = os.Remove(item.TempFirefoxKey4)
+		for _, format := range output.CookieFormats() {
+			format := format
+			filename := fileutil.ItemName(browserName, format.Item(), format.Ext())
+			if err := output.writeFile(dir, filename, func(w io.Writer) error {
+				return format.Write(w, cookies)
+			}); err != nil {
+				log.Error(err)
+			}
 		}
 	}
 }
 
 func (d *Data) addSource(Sources []item.Item) {
-	for _, source := range Sources {
-		switch source {
-		case item.ChromiumPassword:
-			d.sources[source] = &password.ChromiumPassword{}
-		case item.ChromiumCookie:
-			d.sources[source] = &cookie.ChromiumCookie{}
-		case item.ChromiumBookmark:
-			d.sources[source] = &bookmark.ChromiumBookmark{}
-		case item.ChromiumHistory:
-			d.sources[source] = &history.ChromiumHistory{}
-		case item.ChromiumDownload:
-			d.sources[source] = &download.ChromiumDownload{}
-		case item.ChromiumCreditCard:
-			d.sources[source] = &creditcard.ChromiumCreditCard{}
-		case item.ChromiumLocalStorage:
-			d.sources[source] = &localstorage.ChromiumLocalStorage{}
-		case item.ChromiumExtension:
-			d.sources[source] = &extension.ChromiumExtension{}
-		case item.YandexPassword:
-			d.sources[source] = &password.YandexPassword{}
-		case item.YandexCreditCard:
-			d.sources[source] = &creditcard.YandexCreditCard{}
-		case item.FirefoxPassword:
-			d.sources[source] = &password.FirefoxPassword{}
-		case item.FirefoxCookie:
-			d.sources[source] = &cookie.FirefoxCookie{}
-		case item.FirefoxBookmark:
-			d.sources[source] = &bookmark.FirefoxBookmark{}
-		case item.FirefoxHistory:
-			d.sources[source] = &history.FirefoxHistory{}
-		case item.FirefoxDownload:
-			d.sources[source] = &download.FirefoxDownload{}
-		case item.FirefoxLocalStorage:
-			d.sources[source] = &localstorage.FirefoxLocalStorage{}
-		case item.FirefoxExtension:
-			d.sources[source] = &extension.FirefoxExtension{}
+	for _, i := range Sources {
+		if source := newSource(i); source != nil {
+			d.sources[i] = source
 		}
 	}
 }
+
+// newSource returns an empty source of item i, or nil if i has no source
+func newSource(i item.Item) Source {
+	switch i {
+	case item.ChromiumPassword:
+		return &password.ChromiumPassword{}
+	case item.ChromiumCookie:
+		return &cookie.ChromiumCookie{}
+	case item.ChromiumBookmark:
+		return &bookmark.ChromiumBookmark{}
+	case item.ChromiumHistory:
+		return &history.ChromiumHistory{}
+	case item.ChromiumSearchTerm:
+		return &searchterm.ChromiumSearchTerm{}
+	case item.ChromiumDownload:
+		return &download.ChromiumDownload{}
+	case item.ChromiumCreditCard:
+		return &creditcard.ChromiumCreditCard{}
+	case item.ChromiumAddress:
+		return &address.ChromiumAddress{}
+	case item.ChromiumAutofill:
+		return &autofill.ChromiumAutofill{}
+	case item.ChromiumLocalStorage:
+		return &localstorage.ChromiumLocalStorage{}
+	case item.ChromiumSessionStorage:
+		return &localstorage.ChromiumSessionStorage{}
+	case item.ChromiumIndexedDB:
+		return &indexeddb.ChromiumIndexedDB{}
+	case item.ChromiumExtension:
+		return &extension.ChromiumExtension{}
+	case item.ChromiumSession:
+		return &session.ChromiumSession{}
+	case item.YandexPassword:
+		return &password.YandexPassword{}
+	case item.YandexCreditCard:
+		return &creditcard.YandexCreditCard{}
+	case item.FirefoxPassword:
+		return &password.FirefoxPassword{}
+	case item.FirefoxCookie:
+		return &cookie.FirefoxCookie{}
+	case item.FirefoxBookmark:
+		return &bookmark.FirefoxBookmark{}
+	case item.FirefoxHistory:
+		return &history.FirefoxHistory{}
+	case item.FirefoxSearchTerm:
+		return &searchterm.FirefoxSearchTerm{}
+	case item.FirefoxFormHistory:
+		return &autofill.FirefoxFormHistory{}
+	case item.FirefoxDownload:
+		return &download.FirefoxDownload{}
+	case item.FirefoxCreditCard:
+		return &creditcard.FirefoxCreditCard{}
+	case item.FirefoxAddress:
+		return &address.FirefoxAddress{}
+	case item.FirefoxLocalStorage:
+		return &localstorage.FirefoxLocalStorage{}
+	case item.FirefoxIndexedDB:
+		return &indexeddb.FirefoxIndexedDB{}
+	case item.FirefoxExtension:
+		return &extension.FirefoxExtension{}
+	case item.FirefoxPosture:
+		return &password.FirefoxPosture{}
+	case item.FirefoxSession:
+		return &session.FirefoxSession{}
+	}
+	return nil
+}
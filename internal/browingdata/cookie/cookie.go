@@ -1,27 +1,25 @@
 package cookie
 
 import (
-	"bufio"
+	"crypto/sha256"
 	"database/sql"
 	"fmt"
-	"os"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
 	"hack-browser-data/internal/decrypter"
-	"hack-browser-data/internal/item"
 	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/sqliteutil"
 	"hack-browser-data/internal/utils/typeutil"
 
 	// import sqlite3 driver
 	_ "github.com/mattn/go-sqlite3"
 )
 
-type ChromiumCookie []cookie
+type ChromiumCookie []Cookie
 
-type cookie struct {
+type Cookie struct {
 	Host         string
 	Path         string
 	KeyName      string
@@ -33,46 +31,122 @@ type cookie struct {
 	IsPersistent bool
 	CreateDate   time.Time
 	ExpireDate   time.Time
+	// SameSite is unspecified, none, lax or strict
+	SameSite string
+	// Priority is low, medium or high, chromium only
+	Priority string
+	// PartitionKey is the top level site of a partitioned (CHIPS) cookie
+	PartitionKey string
+	// SourceScheme is unset, non_secure or secure, chromium only
+	SourceScheme string
+	// SourcePort is -1 if unspecified, chromium only
+	SourcePort     int
+	LastAccessDate time.Time
+	// OriginAttributes isolate the cookie by container or first party, firefox only
+	OriginAttributes string
+	// DecryptStatus tells why Value is empty if it could not be decrypted
+	DecryptStatus decrypter.Status
 }
 
 const (
-	queryChromiumCookie = `SELECT name, encrypted_value, host_key, path, creation_utc, expires_utc, is_secure, is_httponly, has_expires, is_persistent FROM cookies`
+	sameSiteUnspecified = "unspecified"
+	sameSiteNone        = "none"
+	sameSiteLax         = "lax"
+	sameSiteStrict      = "strict"
 )
 
-func (c *ChromiumCookie) Parse(masterKey []byte) error {
-	cookieDB, err := sql.Open("sqlite3", item.TempChromiumCookie)
+// chromiumCookieQueries select cookies from the newest schema on, columns
+// with a default are added by newer chromium builds.
+var chromiumCookieQueries = []sqliteutil.Query{
+	{
+		Table: "cookies",
+		Columns: []sqliteutil.Column{
+			{Name: "name"}, {Name: "encrypted_value"}, {Name: "value", Default: "''"}, {Name: "host_key"}, {Name: "path"},
+			{Name: "creation_utc"}, {Name: "expires_utc"}, {Name: "is_secure"}, {Name: "is_httponly"},
+			{Name: "has_expires", Default: "expires_utc <> 0"}, {Name: "is_persistent", Default: "expires_utc <> 0"},
+			{Name: "samesite", Default: "-1"}, {Name: "priority", Default: "1"}, {Name: "top_frame_site_key", Default: "''"},
+			{Name: "source_scheme", Default: "0"}, {Name: "source_port", Default: "-1"}, {Name: "last_access_utc", Default: "0"},
+		},
+	},
+	{
+		// older builds name the flags without is_, samesite was firstpartyonly
+		// and the value was kept in plain text before encrypted_value
+		Table: "cookies",
+		Columns: []sqliteutil.Column{
+			{Name: "name"}, {Name: "encrypted_value", Default: "X''"}, {Name: "value", Default: "''"}, {Name: "host_key"}, {Name: "path"},
+			{Name: "creation_utc"}, {Name: "expires_utc"}, {Name: "secure"}, {Name: "httponly"},
+			{Name: "has_expires", Default: "expires_utc <> 0"}, {Name: "persistent", Default: "expires_utc <> 0"},
+			{Name: "firstpartyonly", Default: "-1"}, {Name: "priority", Default: "1"}, {Name: "top_frame_site_key", Default: "''"},
+			{Name: "source_scheme", Default: "0"}, {Name: "source_port", Default: "-1"}, {Name: "last_access_utc", Default: "0"},
+		},
+	},
+}
+
+// chromiumCookieHashVersion is the first Cookies version that prefixes the
+// decrypted value with the SHA256 of host_key
+const chromiumCookieHashVersion = 24
+
+func (c *ChromiumCookie) Parse(masterKey decrypter.Keys, path string) error {
+	err := c.Stream(masterKey, path, func(row any) error {
+		*c = append(*c, row.(Cookie))
+		return nil
+	})
+	sort.Slice(*c, func(i, j int) bool {
+		return (*c)[i].CreateDate.After((*c)[j].CreateDate)
+	})
+	return err
+}
+
+// Stream hands cookies to emit in the order of the database
+func (c *ChromiumCookie) Stream(masterKey decrypter.Keys, path string, emit func(row any) error) error {
+	cookieDB, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempChromiumCookie)
 	defer cookieDB.Close()
-	rows, err := cookieDB.Query(queryChromiumCookie)
+	schema, err := sqliteutil.ReadSchema(cookieDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumCookieQueries...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
+	var failed, total int
 	for rows.Next() {
 		var (
-			key, host, path                               string
+			key, host, path, partitionKey, plainValue     string
 			isSecure, isHTTPOnly, hasExpire, isPersistent int
-			createDate, expireDate                        int64
+			sameSite, priority, sourceScheme, sourcePort  int
+			createDate, expireDate, lastAccess            int64
 			value, encryptValue                           []byte
 		)
-		if err = rows.Scan(&key, &encryptValue, &host, &path, &createDate, &expireDate, &isSecure, &isHTTPOnly, &hasExpire, &isPersistent); err != nil {
+		if err = rows.Scan(&key, &encryptValue, &plainValue, &host, &path, &createDate, &expireDate, &isSecure, &isHTTPOnly, &hasExpire, &isPersistent,
+			&sameSite, &priority, &partitionKey, &sourceScheme, &sourcePort, &lastAccess); err != nil {
 			log.Warn(err)
 		}
 
-		cookie := cookie{
-			KeyName:      key,
-			Host:         host,
-			Path:         path,
-			encryptValue: encryptValue,
-			IsSecure:     typeutil.IntToBool(isSecure),
-			IsHTTPOnly:   typeutil.IntToBool(isHTTPOnly),
-			HasExpire:    typeutil.IntToBool(hasExpire),
-			IsPersistent: typeutil.IntToBool(isPersistent),
-			CreateDate:   typeutil.TimeEpoch(createDate),
-			ExpireDate:   typeutil.TimeEpoch(expireDate),
+		cookie := Cookie{
+			KeyName:       key,
+			Host:          host,
+			Path:          path,
+			encryptValue:  encryptValue,
+			IsSecure:      typeutil.IntToBool(isSecure),
+			IsHTTPOnly:    typeutil.IntToBool(isHTTPOnly),
+			HasExpire:     typeutil.IntToBool(hasExpire),
+			IsPersistent:  typeutil.IntToBool(isPersistent),
+			CreateDate:    typeutil.TimeEpoch(createDate),
+			ExpireDate:    typeutil.TimeEpoch(expireDate),
+			SameSite:      chromiumSameSite(sameSite),
+			Priority:      chromiumPriority(priority),
+			PartitionKey:  partitionKey,
+			SourceScheme:  chromiumSourceScheme(sourceScheme),
+			SourcePort:    sourcePort,
+			DecryptStatus: decrypter.StatusPlain,
+		}
+		if lastAccess > 0 {
+			cookie.LastAccessDate = typeutil.TimeEpoch(lastAccess)
 		}
 		if len(encryptValue) > 0 {
 			var err error
@@ -83,17 +157,71 @@ func (c *ChromiumCookie) Parse(masterKey []byte) error {
 			}
 			if err != nil {
 				log.Error(err)
+				failed++
+			} else if schema.Version >= chromiumCookieHashVersion && len(value) >= sha256.Size {
+				value = value[sha256.Size:]
 			}
+			cookie.DecryptStatus = decrypter.StatusOf(err)
+		} else {
+			value = []byte(plainValue)
 		}
 		cookie.Value = string(value)
-		*c = append(*c, cookie)
+		total++
+		if err := emit(cookie); err != nil {
+			return err
+		}
+	}
+	// an error of the rows loses the cookies after it, it outweighs the
+	// ones that failed to decrypt
+	if err := rows.Err(); err != nil {
+		if failed > 0 {
+			return fmt.Errorf("%w, %s: %d of %d cookies", err, decrypter.ErrDecryptFailed, failed, total)
+		}
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d cookies", decrypter.ErrDecryptFailed, failed, total)
 	}
-	sort.Slice(*c, func(i, j int) bool {
-		return (*c)[i].CreateDate.After((*c)[j].CreateDate)
-	})
 	return nil
 }
 
+// chromiumSameSite decodes net::CookieSameSite
+// @https://source.chromium.org/chromium/chromium/src/+/main:net/cookies/cookie_constants.h
+func chromiumSameSite(v int) string {
+	switch v {
+	case 0:
+		return sameSiteNone
+	case 1:
+		return sameSiteLax
+	case 2:
+		return sameSiteStrict
+	default:
+		return sameSiteUnspecified
+	}
+}
+
+func chromiumPriority(v int) string {
+	switch v {
+	case 0:
+		return "low"
+	case 2:
+		return "high"
+	default:
+		return "medium"
+	}
+}
+
+func chromiumSourceScheme(v int) string {
+	switch v {
+	case 1:
+		return "non_secure"
+	case 2:
+		return "secure"
+	default:
+		return "unset"
+	}
+}
+
 func (c *ChromiumCookie) Name() string {
 	return "cookie"
 }
@@ -102,148 +230,107 @@ func (c *ChromiumCookie) Length() int {
 	return len(*c)
 }
 
-func (c *ChromiumCookie) SaveCookie(outputPath string, outFormat string) {
-	// 存放 处理完之后的cookie
-	cookie_data := make(map[string]string)
-
-	// 遍历原始cookie
-	for _, data := range *c {
-		// 拿到原始cookie里面的host作为处理后的key
-		cookie_key := data.Host
-		if data.Host[:1] == "." {
-			cookie_key = data.Host[1:]
-		}
-
-		// 原始cookie里面的key
-		key := data.KeyName
-		value := data.Value
-		expire := strconv.FormatInt(data.ExpireDate.Unix(), 10)
-		httpOnly := boolToI(data.IsHTTPOnly)
-		secure := boolToI(data.IsSecure)
-
-		map_value := fmt.Sprintf("^%s=%s`%s%s%s", key, value, expire, httpOnly, secure)
-
-		if oldValue, exists := cookie_data[cookie_key]; exists {
-			// 存在, 追加
-			cookie_data[cookie_key] = oldValue + map_value
-		} else {
-			// 不存在, 添加
-			cookie_data[cookie_key] = map_value
-		}
+type FirefoxCookie []Cookie
 
-	}
-
-	save(&cookie_data, outputPath, outFormat)
+// firefoxCookieQueries select cookies, columns with a default are added by newer firefox
+var firefoxCookieQueries = []sqliteutil.Query{
+	{
+		Table: "moz_cookies",
+		Columns: []sqliteutil.Column{
+			{Name: "name"}, {Name: "value"}, {Name: "host"}, {Name: "path"}, {Name: "creationTime"}, {Name: "expiry"},
+			{Name: "isSecure"}, {Name: "isHttpOnly"},
+			{Name: "sameSite", Default: "-1"}, {Name: "lastAccessed", Default: "0"}, {Name: "originAttributes", Default: "''"},
+		},
+	},
 }
 
-type FirefoxCookie []cookie
-
-const (
-	queryFirefoxCookie = `SELECT name, value, host, path, creationTime, expiry, isSecure, isHttpOnly FROM moz_cookies`
-)
+func (f *FirefoxCookie) Parse(masterKey decrypter.Keys, path string) error {
+	return f.Stream(masterKey, path, func(row any) error {
+		*f = append(*f, row.(Cookie))
+		return nil
+	})
+}
 
-func (f *FirefoxCookie) Parse(masterKey []byte) error {
-	cookieDB, err := sql.Open("sqlite3", item.TempFirefoxCookie)
+// Stream hands cookies to emit in the order of the database
+func (f *FirefoxCookie) Stream(masterKey decrypter.Keys, path string, emit func(row any) error) error {
+	cookieDB, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempFirefoxCookie)
 	defer cookieDB.Close()
-	rows, err := cookieDB.Query(queryFirefoxCookie)
+	schema, err := sqliteutil.ReadSchema(cookieDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(firefoxCookieQueries...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var (
-			name, value, host, path string
-			isSecure, isHTTPOnly    int
-			creationTime, expiry    int64
+			name, value, host, path, originAttributes string
+			isSecure, isHTTPOnly, sameSite            int
+			creationTime, expiry, lastAccessed        int64
 		)
-		if err = rows.Scan(&name, &value, &host, &path, &creationTime, &expiry, &isSecure, &isHTTPOnly); err != nil {
+		if err = rows.Scan(&name, &value, &host, &path, &creationTime, &expiry, &isSecure, &isHTTPOnly,
+			&sameSite, &lastAccessed, &originAttributes); err != nil {
 			log.Warn(err)
 		}
-		*f = append(*f, cookie{
-			KeyName:    name,
-			Host:       host,
-			Path:       path,
-			IsSecure:   typeutil.IntToBool(isSecure),
-			IsHTTPOnly: typeutil.IntToBool(isHTTPOnly),
-			CreateDate: typeutil.TimeStamp(creationTime / 1000000),
-			ExpireDate: typeutil.TimeStamp(expiry),
-			Value:      value,
-		})
+		cookie := Cookie{
+			KeyName:          name,
+			Host:             host,
+			Path:             path,
+			IsSecure:         typeutil.IntToBool(isSecure),
+			IsHTTPOnly:       typeutil.IntToBool(isHTTPOnly),
+			CreateDate:       typeutil.TimeStamp(creationTime / 1000000),
+			ExpireDate:       typeutil.TimeStamp(expiry),
+			Value:            value,
+			SameSite:         firefoxSameSite(sameSite),
+			PartitionKey:     originAttribute(originAttributes, "partitionKey"),
+			OriginAttributes: originAttributes,
+			DecryptStatus:    decrypter.StatusPlain,
+		}
+		if lastAccessed > 0 {
+			cookie.LastAccessDate = typeutil.TimeStamp(lastAccessed / 1000000)
+		}
+		if err = emit(cookie); err != nil {
+			return err
+		}
 	}
-	return nil
+	return rows.Err()
 }
 
-func (f *FirefoxCookie) Name() string {
-	return "cookie"
-}
-
-func (f *FirefoxCookie) Length() int {
-	return len(*f)
+// firefoxSameSite decodes moz_cookies.sameSite, it has no unspecified value
+// @https://searchfox.org/mozilla-central/source/netwerk/cookie/nsICookie.idl
+func firefoxSameSite(v int) string {
+	switch v {
+	case 0:
+		return sameSiteNone
+	case 1:
+		return sameSiteLax
+	case 2:
+		return sameSiteStrict
+	default:
+		return sameSiteUnspecified
+	}
 }
 
-func (f *FirefoxCookie) SaveCookie(outputPath string, outFormat string) {
-	// 存放 处理完之后的cookie
-	cookie_data := make(map[string]string)
-
-	// 遍历原始cookie
-	for _, data := range *f {
-		// 拿到原始cookie里面的host作为处理后的key
-		cookie_key := data.Host
-		if data.Host[:1] == "." {
-			cookie_key = data.Host[1:]
-		}
-
-		// 原始cookie里面的key
-		key := data.KeyName
-		value := data.Value
-		expire := strconv.FormatInt(data.ExpireDate.Unix(), 10)
-		httpOnly := boolToI(data.IsHTTPOnly)
-		secure := boolToI(data.IsSecure)
-
-		map_value := fmt.Sprintf("^%s=%s`%s%s%s", key, value, expire, httpOnly, secure)
-
-		if oldValue, exists := cookie_data[cookie_key]; exists {
-			// 存在, 追加
-			cookie_data[cookie_key] = oldValue + map_value
-		} else {
-			// 不存在, 添加
-			cookie_data[cookie_key] = map_value
+// originAttribute returns the attribute of a firefox origin suffix like
+// ^userContextId=1&partitionKey=(https,example.com)
+func originAttribute(suffix, name string) string {
+	for _, kv := range strings.Split(strings.TrimPrefix(suffix, "^"), "&") {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == name {
+			return v
 		}
 	}
-
-	save(&cookie_data, outputPath, outFormat)
+	return ""
 }
 
-func boolToI(b bool) string {
-	if b == true {
-		return "1"
-	} else {
-		return "0"
-	}
+func (f *FirefoxCookie) Name() string {
+	return "cookie"
 }
 
-func save(cookie_data *map[string]string, outputPath string, outputFormat string) {
-	// 保存
-	fName := outputPath + "\\" + strings.ReplaceAll(outputFormat, ".", "_") + "_cookie.txt"
-	file, err := os.OpenFile(fName, os.O_WRONLY|os.O_CREATE, 0777)
-	if err != nil {
-		log.Errorf("save file %s error: ", fName, err.Error())
-		return
-	}
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			log.Errorf("close file %s error: ", fName, err.Error())
-		}
-	}(file)
-	write := bufio.NewWriter(file)
-	for k, v := range *cookie_data {
-		_, _ = write.WriteString(k + "/" + v + "\n")
-	}
-	_ = write.Flush()
-	log.Noticef("output to file %s success", fName)
+func (f *FirefoxCookie) Length() int {
+	return len(*f)
 }
@@ -0,0 +1,134 @@
+package cookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"hack-browser-data/internal/decrypter"
+)
+
+// testKey is a 32 bytes key, every platform decrypts v10 AES-GCM blobs with it
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func encryptGCM(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	return append(append([]byte("v10"), nonce...), gcm.Seal(nil, nonce, plain, nil)...)
+}
+
+func cookieDB(t *testing.T, stmts []string, args ...any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Cookies")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for i, s := range stmts {
+		var err error
+		if i == len(stmts)-1 {
+			_, err = db.Exec(s, args...)
+		} else {
+			_, err = db.Exec(s)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestChromiumCookieSchemaVersions(t *testing.T) {
+	t.Parallel()
+	hashed := sha256.Sum256([]byte(".example.com"))
+	tests := []struct {
+		name  string
+		stmts []string
+		args  []any
+		want  Cookie
+	}{
+		{
+			name: "v9 plain value",
+			stmts: []string{
+				`CREATE TABLE meta (key LONGVARCHAR, value LONGVARCHAR)`,
+				`INSERT INTO meta VALUES ('version', '9'), ('last_compatible_version', '9')`,
+				`CREATE TABLE cookies (creation_utc INTEGER, host_key TEXT, name TEXT, value TEXT, path TEXT, expires_utc INTEGER,
+					secure INTEGER, httponly INTEGER, last_access_utc INTEGER, has_expires INTEGER, persistent INTEGER,
+					priority INTEGER, encrypted_value BLOB, firstpartyonly INTEGER)`,
+				`INSERT INTO cookies VALUES (13300000000000000, '.example.com', 'sid', 'abc', '/', 0, 1, 0, 0, 0, 0, 2, X'', 1)`,
+			},
+			want: Cookie{Host: ".example.com", KeyName: "sid", Value: "abc", Path: "/", IsSecure: true, SameSite: sameSiteLax, Priority: "high", SourceScheme: "unset", SourcePort: -1, DecryptStatus: decrypter.StatusPlain},
+		},
+		{
+			name: "v12 is_ columns",
+			stmts: []string{
+				`CREATE TABLE meta (key LONGVARCHAR, value LONGVARCHAR)`,
+				`INSERT INTO meta VALUES ('version', '12'), ('last_compatible_version', '12')`,
+				`CREATE TABLE cookies (creation_utc INTEGER, host_key TEXT, name TEXT, value TEXT, path TEXT, expires_utc INTEGER,
+					is_secure INTEGER, is_httponly INTEGER, last_access_utc INTEGER, has_expires INTEGER, is_persistent INTEGER,
+					priority INTEGER, encrypted_value BLOB, samesite INTEGER)`,
+				`INSERT INTO cookies VALUES (13300000000000000, '.example.com', 'sid', 'abc', '/', 0, 0, 1, 0, 0, 0, 1, X'', 0)`,
+			},
+			want: Cookie{Host: ".example.com", KeyName: "sid", Value: "abc", Path: "/", IsHTTPOnly: true, SameSite: sameSiteNone, Priority: "medium", SourceScheme: "unset", SourcePort: -1, DecryptStatus: decrypter.StatusPlain},
+		},
+		{
+			name: "v18 source scheme",
+			stmts: []string{
+				`CREATE TABLE meta (key LONGVARCHAR, value LONGVARCHAR)`,
+				`INSERT INTO meta VALUES ('version', '18'), ('last_compatible_version', '18')`,
+				`CREATE TABLE cookies (creation_utc INTEGER, top_frame_site_key TEXT, host_key TEXT, name TEXT, value TEXT,
+					encrypted_value BLOB, path TEXT, expires_utc INTEGER, is_secure INTEGER, is_httponly INTEGER,
+					last_access_utc INTEGER, has_expires INTEGER, is_persistent INTEGER, priority INTEGER, samesite INTEGER,
+					source_scheme INTEGER, source_port INTEGER, is_same_party INTEGER)`,
+				`INSERT INTO cookies VALUES (13300000000000000, '', '.example.com', 'sid', '', ?, '/', 0, 1, 1, 0, 0, 0, 1, 2, 2, 443, 0)`,
+			},
+			args: []any{encryptGCM(t, []byte("abc"))},
+			want: Cookie{Host: ".example.com", KeyName: "sid", Value: "abc", Path: "/", IsSecure: true, IsHTTPOnly: true, SameSite: sameSiteStrict, Priority: "medium", SourceScheme: "secure", SourcePort: 443, DecryptStatus: decrypter.StatusOK},
+		},
+		{
+			name: "v24 hashed value",
+			stmts: []string{
+				`CREATE TABLE meta (key LONGVARCHAR, value LONGVARCHAR)`,
+				`INSERT INTO meta VALUES ('version', '24'), ('last_compatible_version', '24')`,
+				`CREATE TABLE cookies (creation_utc INTEGER, host_key TEXT, top_frame_site_key TEXT, name TEXT, value TEXT,
+					encrypted_value BLOB, path TEXT, expires_utc INTEGER, is_secure INTEGER, is_httponly INTEGER,
+					last_access_utc INTEGER, has_expires INTEGER, is_persistent INTEGER, priority INTEGER, samesite INTEGER,
+					source_scheme INTEGER, source_port INTEGER, last_update_utc INTEGER, source_type INTEGER, has_cross_site_ancestor INTEGER)`,
+				`INSERT INTO cookies VALUES (13300000000000000, '.example.com', 'https://top.com', 'sid', '', ?, '/', 0, 1, 0, 0, 0, 0, 0, 1, 2, 443, 0, 0, 1)`,
+			},
+			args: []any{encryptGCM(t, append(hashed[:], "abc"...))},
+			want: Cookie{Host: ".example.com", KeyName: "sid", Value: "abc", Path: "/", IsSecure: true, SameSite: sameSiteLax, Priority: "low", PartitionKey: "https://top.com", SourceScheme: "secure", SourcePort: 443, DecryptStatus: decrypter.StatusOK},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var c ChromiumCookie
+			if err := c.Parse(decrypter.NewKeys(testKey), cookieDB(t, tt.stmts, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if len(c) != 1 {
+				t.Fatalf("parsed %d cookies, want 1", len(c))
+			}
+			got := c[0]
+			got.encryptValue, got.CreateDate, got.ExpireDate, got.LastAccessDate = nil, tt.want.CreateDate, tt.want.ExpireDate, tt.want.LastAccessDate
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cookie = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package cookie
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is a cookie file format read by other tools
+type Format string
+
+const (
+	// FormatNetscape is the cookies.txt read by curl -b and wget --load-cookies
+	FormatNetscape Format = "netscape"
+	// FormatEditor is the JSON imported by cookie editor extensions,
+	// like Cookie-Editor and EditThisCookie
+	FormatEditor Format = "editor"
+)
+
+// ParseFormat returns the Format named s
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatNetscape, FormatEditor:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported cookie format %q, available: netscape|editor", s)
+	}
+}
+
+// Item names the exported file with Ext, like chrome_default_cookies.txt
+func (f Format) Item() string {
+	if f == FormatEditor {
+		return "cookie_editor"
+	}
+	return "cookies"
+}
+
+// Ext is the extension of the exported file
+func (f Format) Ext() string {
+	if f == FormatEditor {
+		return "json"
+	}
+	return "txt"
+}
+
+// Write writes cookies to w in the format
+func (f Format) Write(w io.Writer, cookies []Cookie) error {
+	if f == FormatEditor {
+		return writeEditor(w, cookies)
+	}
+	return writeNetscape(w, cookies)
+}
+
+// writeNetscape writes the tab separated cookies.txt, HttpOnly cookies are
+// prefixed with #HttpOnly_ as curl does, session cookies expire at 0.
+func writeNetscape(w io.Writer, cookies []Cookie) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("# Netscape HTTP Cookie File\n"); err != nil {
+		return err
+	}
+	for _, c := range cookies {
+		domain := c.Host
+		if c.IsHTTPOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		fields := []string{
+			domain,
+			netscapeBool(strings.HasPrefix(c.Host, ".")),
+			c.Path,
+			netscapeBool(c.IsSecure),
+			strconv.FormatInt(expires(c), 10),
+			c.KeyName,
+			c.Value,
+		}
+		if _, err := bw.WriteString(strings.Join(fields, "\t") + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// editorCookie is a cookie as exported by cookie editor extensions
+type editorCookie struct {
+	Domain         string `json:"domain"`
+	ExpirationDate *int64 `json:"expirationDate,omitempty"`
+	HostOnly       bool   `json:"hostOnly"`
+	HTTPOnly       bool   `json:"httpOnly"`
+	Name           string `json:"name"`
+	Path           string `json:"path"`
+	SameSite       string `json:"sameSite"`
+	Secure         bool   `json:"secure"`
+	Session        bool   `json:"session"`
+	Value          string `json:"value"`
+}
+
+func writeEditor(w io.Writer, cookies []Cookie) error {
+	l := make([]editorCookie, 0, len(cookies))
+	for _, c := range cookies {
+		e := editorCookie{
+			Domain:   c.Host,
+			HostOnly: !strings.HasPrefix(c.Host, "."),
+			HTTPOnly: c.IsHTTPOnly,
+			Name:     c.KeyName,
+			Path:     c.Path,
+			SameSite: editorSameSite(c.SameSite),
+			Secure:   c.IsSecure,
+			Value:    c.Value,
+		}
+		if exp := expires(c); exp > 0 {
+			e.ExpirationDate = &exp
+		} else {
+			e.Session = true
+		}
+		l = append(l, e)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(l)
+}
+
+// editorSameSite returns the chrome.cookies.SameSiteStatus of sameSite
+func editorSameSite(sameSite string) string {
+	switch sameSite {
+	case sameSiteNone:
+		return "no_restriction"
+	case sameSiteLax, sameSiteStrict:
+		return sameSite
+	default:
+		return "unspecified"
+	}
+}
+
+// expires returns the unix expiry of c, 0 for session cookies which
+// chromium stores with an expiry before 1970
+func expires(c Cookie) int64 {
+	if exp := c.ExpireDate.Unix(); exp > 0 {
+		return exp
+	}
+	return 0
+}
@@ -0,0 +1,95 @@
+package cookie
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteNetscape(t *testing.T) {
+	t.Parallel()
+	cookies := []Cookie{
+		{Host: ".example.com", Path: "/", KeyName: "sid", Value: "abc", IsSecure: true, IsHTTPOnly: true, ExpireDate: time.Unix(1900000000, 0)},
+		{Host: "www.example.com", Path: "/app", KeyName: "pref", Value: "x", ExpireDate: time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	var buf bytes.Buffer
+	if err := FormatNetscape.Write(&buf, cookies); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_.example.com\tTRUE\t/\tTRUE\t1900000000\tsid\tabc\n" +
+		"www.example.com\tFALSE\t/app\tFALSE\t0\tpref\tx\n"
+	if buf.String() != want {
+		t.Errorf("netscape cookies are %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteEditor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		cookie Cookie
+		want   string
+	}{
+		{
+			name: "same site none",
+			cookie: Cookie{
+				Host: ".example.com", Path: "/", KeyName: "sid", Value: "abc", IsSecure: true, IsHTTPOnly: true,
+				SameSite: sameSiteNone, ExpireDate: time.Unix(1900000000, 0),
+			},
+			want: `{"domain":".example.com","expirationDate":1900000000,"hostOnly":false,"httpOnly":true,"name":"sid",` +
+				`"path":"/","sameSite":"no_restriction","secure":true,"session":false,"value":"abc"}`,
+		},
+		{
+			name: "same site lax",
+			cookie: Cookie{
+				Host: "www.example.com", Path: "/app", KeyName: "pref", Value: "x",
+				SameSite: sameSiteLax, ExpireDate: time.Unix(1900000000, 0),
+			},
+			want: `{"domain":"www.example.com","expirationDate":1900000000,"hostOnly":true,"httpOnly":false,"name":"pref",` +
+				`"path":"/app","sameSite":"lax","secure":false,"session":false,"value":"x"}`,
+		},
+		{
+			name: "same site strict",
+			cookie: Cookie{
+				Host: "www.example.com", Path: "/", KeyName: "csrf", Value: "<t&k>",
+				SameSite: sameSiteStrict, ExpireDate: time.Unix(1900000000, 0),
+			},
+			want: `{"domain":"www.example.com","expirationDate":1900000000,"hostOnly":true,"httpOnly":false,"name":"csrf",` +
+				`"path":"/","sameSite":"strict","secure":false,"session":false,"value":"<t&k>"}`,
+		},
+		{
+			name: "session cookie before 1970",
+			cookie: Cookie{
+				Host: "www.example.com", Path: "/", KeyName: "tmp", Value: "y",
+				SameSite: sameSiteUnspecified, ExpireDate: time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			want: `{"domain":"www.example.com","hostOnly":true,"httpOnly":false,"name":"tmp",` +
+				`"path":"/","sameSite":"unspecified","secure":false,"session":true,"value":"y"}`,
+		},
+		{
+			name:   "session cookie without expiry",
+			cookie: Cookie{Host: "www.example.com", Path: "/", KeyName: "tmp", Value: "z"},
+			want: `{"domain":"www.example.com","hostOnly":true,"httpOnly":false,"name":"tmp",` +
+				`"path":"/","sameSite":"unspecified","secure":false,"session":true,"value":"z"}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if err := FormatEditor.Write(&buf, []Cookie{tt.cookie}); err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := json.Compact(&got, buf.Bytes()); err != nil {
+				t.Fatal(err)
+			}
+			if want := "[" + tt.want + "]"; got.String() != want {
+				t.Errorf("editor cookies are %s, want %s", got.String(), want)
+			}
+		})
+	}
+}
@@ -2,19 +2,23 @@ package creditcard
 
 import (
 	"database/sql"
-	"os"
+	"encoding/base64"
+	"errors"
+	"fmt"
 
 	"hack-browser-data/internal/decrypter"
-	"hack-browser-data/internal/item"
 	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/fileutil"
+	"hack-browser-data/internal/utils/sqliteutil"
 
 	// import sqlite3 driver
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/tidwall/gjson"
 )
 
-type ChromiumCreditCard []card
+type ChromiumCreditCard []Card
 
-type card struct {
+type Card struct {
 	GUID            string
 	Name            string
 	ExpirationYear  string
@@ -22,24 +26,38 @@ type card struct {
 	CardNumber      string
 	Address         string
 	NickName        string
+	// DecryptStatus tells why CardNumber is empty if it could not be decrypted
+	DecryptStatus decrypter.Status
 }
 
-const (
-	queryChromiumCredit = `SELECT guid, name_on_card, expiration_month, expiration_year, card_number_encrypted, billing_address_id, nickname FROM credit_cards`
-)
+// chromiumCreditQueries select credit cards, billing_address_id and nickname
+// are added by newer chromium builds
+var chromiumCreditQueries = []sqliteutil.Query{
+	{
+		Table: "credit_cards",
+		Columns: []sqliteutil.Column{
+			{Name: "guid"}, {Name: "name_on_card"}, {Name: "expiration_month"}, {Name: "expiration_year"},
+			{Name: "card_number_encrypted"}, {Name: "billing_address_id", Default: "''"}, {Name: "nickname", Default: "''"},
+		},
+	},
+}
 
-func (c *ChromiumCreditCard) Parse(masterKey []byte) error {
-	creditDB, err := sql.Open("sqlite3", item.TempChromiumCreditCard)
+func (c *ChromiumCreditCard) Parse(masterKey decrypter.Keys, path string) error {
+	creditDB, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempChromiumCreditCard)
 	defer creditDB.Close()
-	rows, err := creditDB.Query(queryChromiumCredit)
+	schema, err := sqliteutil.ReadSchema(creditDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumCreditQueries...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
+	var failed int
 	for rows.Next() {
 		var (
 			name, month, year, guid, address, nickname string
@@ -48,28 +66,34 @@ func (c *ChromiumCreditCard) Parse(masterKey []byte) error {
 		if err := rows.Scan(&guid, &name, &month, &year, &encryptValue, &address, &nickname); err != nil {
 			log.Warn(err)
 		}
-		ccInfo := card{
+		ccInfo := Card{
 			GUID:            guid,
 			Name:            name,
 			ExpirationMonth: month,
 			ExpirationYear:  year,
 			Address:         address,
 			NickName:        nickname,
+			DecryptStatus:   decrypter.StatusPlain,
 		}
-		if masterKey == nil {
-			value, err = decrypter.DPAPI(encryptValue)
-			if err != nil {
-				return err
+		if len(encryptValue) > 0 {
+			var err error
+			if masterKey == nil {
+				value, err = decrypter.DPAPI(encryptValue)
+			} else {
+				value, err = decrypter.Chromium(masterKey, encryptValue)
 			}
-		} else {
-			value, err = decrypter.Chromium(masterKey, encryptValue)
 			if err != nil {
-				return err
+				log.Error(err)
+				failed++
 			}
+			ccInfo.DecryptStatus = decrypter.StatusOf(err)
 		}
 		ccInfo.CardNumber = string(value)
 		*c = append(*c, ccInfo)
 	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d credit cards", decrypter.ErrDecryptFailed, failed, len(*c))
+	}
 	return nil
 }
 
@@ -81,21 +105,24 @@ func (c *ChromiumCreditCard) Length() int {
 	return len(*c)
 }
 
-type YandexCreditCard []card
+type YandexCreditCard []Card
 
-func (c *YandexCreditCard) Parse(masterKey []byte) error {
-	creditDB, err := sql.Open("sqlite3", item.TempYandexCreditCard)
+func (c *YandexCreditCard) Parse(masterKey decrypter.Keys, path string) error {
+	creditDB, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempYandexCreditCard)
-	defer creditDB.Close()
 	defer creditDB.Close()
-	rows, err := creditDB.Query(queryChromiumCredit)
+	schema, err := sqliteutil.ReadSchema(creditDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumCreditQueries...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
+	var failed int
 	for rows.Next() {
 		var (
 			name, month, year, guid, address, nickname string
@@ -104,28 +131,34 @@ func (c *YandexCreditCard) Parse(masterKey []byte) error {
 		if err := rows.Scan(&guid, &name, &month, &year, &encryptValue, &address, &nickname); err != nil {
 			log.Warn(err)
 		}
-		ccInfo := card{
+		ccInfo := Card{
 			GUID:            guid,
 			Name:            name,
 			ExpirationMonth: month,
 			ExpirationYear:  year,
 			Address:         address,
 			NickName:        nickname,
+			DecryptStatus:   decrypter.StatusPlain,
 		}
-		if masterKey == nil {
-			value, err = decrypter.DPAPI(encryptValue)
-			if err != nil {
-				return err
+		if len(encryptValue) > 0 {
+			var err error
+			if masterKey == nil {
+				value, err = decrypter.DPAPI(encryptValue)
+			} else {
+				value, err = decrypter.Chromium(masterKey, encryptValue)
 			}
-		} else {
-			value, err = decrypter.Chromium(masterKey, encryptValue)
 			if err != nil {
-				return err
+				log.Error(err)
+				failed++
 			}
+			ccInfo.DecryptStatus = decrypter.StatusOf(err)
 		}
 		ccInfo.CardNumber = string(value)
 		*c = append(*c, ccInfo)
 	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d credit cards", decrypter.ErrDecryptFailed, failed, len(*c))
+	}
 	return nil
 }
 
@@ -136,3 +169,88 @@ func (c *YandexCreditCard) Name() string {
 func (c *YandexCreditCard) Length() int {
 	return len(*c)
 }
+
+type FirefoxCreditCard []Card
+
+// errNoOSKey means the number is encrypted with the key firefox keeps in
+// the OS key store, which is unavailable
+var errNoOSKey = errors.New("firefox key of the OS key store is unavailable")
+
+// Parse reads credit cards of autofill-profiles.json, masterKey is the key
+// of logins and the one of decrypter.OSKeyStore. Numbers were encrypted
+// like logins by older Firefox, newer ones encrypt them with AES-GCM and
+// the key of the OS key store. Numbers of an unavailable OS key store are
+// left empty without failing the item, the key is out of the profile.
+func (f *FirefoxCreditCard) Parse(masterKey decrypter.Keys, path string) error {
+	s, err := fileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var failed int
+	for _, v := range gjson.Get(s, "creditCards").Array() {
+		name := v.Get("cc-name").String()
+		if name == "" {
+			name = v.Get("cc-given-name").String() + " " + v.Get("cc-family-name").String()
+		}
+		ccInfo := Card{
+			GUID:            v.Get("guid").String(),
+			Name:            name,
+			ExpirationMonth: v.Get("cc-exp-month").String(),
+			ExpirationYear:  v.Get("cc-exp-year").String(),
+			Address:         v.Get("billingAddressGUID").String(),
+			DecryptStatus:   decrypter.StatusPlain,
+		}
+		if encrypted := v.Get("cc-number-encrypted").String(); encrypted != "" {
+			number, err := decryptFirefoxNumber(masterKey, encrypted)
+			switch {
+			case errors.Is(err, errNoOSKey):
+				log.Warnf("decrypt firefox credit card %s error: %s", ccInfo.GUID, err)
+			case err != nil:
+				log.Errorf("decrypt firefox credit card %s error: %s", ccInfo.GUID, err)
+				failed++
+			}
+			ccInfo.CardNumber = string(number)
+			ccInfo.DecryptStatus = decrypter.StatusOf(err)
+		} else {
+			ccInfo.CardNumber = v.Get("cc-number").String()
+		}
+		*f = append(*f, ccInfo)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d credit cards", decrypter.ErrDecryptFailed, failed, len(*f))
+	}
+	return nil
+}
+
+// decryptFirefoxNumber decrypts a number encrypted like a login, it's an
+// ASN.1 SEQUENCE unlike numbers encrypted with the OS key store
+func decryptFirefoxNumber(keys decrypter.Keys, encrypted string) ([]byte, error) {
+	blob, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", decrypter.ErrCorruptBlob, err)
+	}
+	if len(blob) == 0 || blob[0] != 0x30 {
+		osKey := keys[decrypter.OSKeyStore]
+		if len(osKey) == 0 {
+			return nil, errNoOSKey
+		}
+		return decrypter.FirefoxOSKeyStore(osKey, blob)
+	}
+	masterKey := keys.Key(decrypter.AnyPrefix)
+	if len(masterKey) == 0 {
+		return nil, decrypter.ErrNoLoginKey
+	}
+	pbe, err := decrypter.NewASN1PBE(blob)
+	if err != nil {
+		return nil, err
+	}
+	return pbe.Decrypt(masterKey, nil)
+}
+
+func (f *FirefoxCreditCard) Name() string {
+	return "creditcard"
+}
+
+func (f *FirefoxCreditCard) Length() int {
+	return len(*f)
+}
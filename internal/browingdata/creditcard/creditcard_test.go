@@ -0,0 +1,110 @@
+package creditcard
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/testfixture"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestChromiumCreditCardSchemas(t *testing.T) {
+	t.Parallel()
+	blob, err := testfixture.EncryptChromium(testfixture.ChromiumWindowsKey, []byte("4111111111111111"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		create string
+		insert string
+		want   Card
+	}{
+		{
+			name: "without billing address and nickname",
+			create: `CREATE TABLE credit_cards (guid VARCHAR PRIMARY KEY, name_on_card VARCHAR, expiration_month INTEGER,
+				expiration_year INTEGER, card_number_encrypted BLOB, date_modified INTEGER NOT NULL DEFAULT 0)`,
+			insert: `INSERT INTO credit_cards VALUES ('g1', 'Alice', 1, 2030, ?, 0)`,
+			want:   Card{GUID: "g1", Name: "Alice", ExpirationMonth: "1", ExpirationYear: "2030", CardNumber: "4111111111111111"},
+		},
+		{
+			name: "nickname",
+			create: `CREATE TABLE credit_cards (guid VARCHAR PRIMARY KEY, name_on_card VARCHAR, expiration_month INTEGER,
+				expiration_year INTEGER, card_number_encrypted BLOB, date_modified INTEGER NOT NULL DEFAULT 0,
+				origin VARCHAR DEFAULT '', use_count INTEGER NOT NULL DEFAULT 0, use_date INTEGER NOT NULL DEFAULT 0,
+				billing_address_id VARCHAR, nickname VARCHAR)`,
+			insert: `INSERT INTO credit_cards VALUES ('g2', 'Bob', 12, 2031, ?, 0, '', 0, 0, 'a1', 'work')`,
+			want: Card{
+				GUID: "g2", Name: "Bob", ExpirationMonth: "12", ExpirationYear: "2031", CardNumber: "4111111111111111",
+				Address: "a1", NickName: "work",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "Web Data")
+			db, err := sql.Open("sqlite3", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec(tt.create); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec(tt.insert, blob); err != nil {
+				t.Fatal(err)
+			}
+			db.Close()
+			var c ChromiumCreditCard
+			if err := c.Parse(decrypter.NewKeys(testfixture.ChromiumWindowsKey), path); err != nil {
+				t.Fatal(err)
+			}
+			if len(c) != 1 {
+				t.Fatalf("parsed %d credit cards, want 1", len(c))
+			}
+			tt.want.DecryptStatus = decrypter.StatusOK
+			if c[0] != tt.want {
+				t.Errorf("credit card = %+v, want %+v", c[0], tt.want)
+			}
+		})
+	}
+}
+
+// TestFirefoxCreditCardWithoutKeys leaves the numbers of a profile without
+// keys encrypted, only the ones of the key of logins fail the item
+func TestFirefoxCreditCardWithoutKeys(t *testing.T) {
+	dir := t.TempDir()
+	if err := testfixture.Firefox(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	var cards FirefoxCreditCard
+	err := cards.Parse(nil, filepath.Join(dir, "autofill-profiles.json"))
+	if !errors.Is(err, decrypter.ErrDecryptFailed) || !strings.Contains(err.Error(), "1 of 2") {
+		t.Errorf("Parse() error = %v, want 1 of 2 credit cards failed", err)
+	}
+	for _, c := range cards {
+		if c.CardNumber != "" || c.DecryptStatus != decrypter.StatusFailed {
+			t.Errorf("card %s = %q %s, want no number %s", c.GUID, c.CardNumber, c.DecryptStatus, decrypter.StatusFailed)
+		}
+	}
+	encrypted := gjson.Get(readFile(t, filepath.Join(dir, "autofill-profiles.json")), "creditCards.0.cc-number-encrypted").String()
+	if _, err := decryptFirefoxNumber(nil, encrypted); !errors.Is(err, decrypter.ErrNoLoginKey) {
+		t.Errorf("decryptFirefoxNumber() error = %v, want %v", err, decrypter.ErrNoLoginKey)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
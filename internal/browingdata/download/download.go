@@ -2,13 +2,13 @@ package download
 
 import (
 	"database/sql"
-	"os"
 	"sort"
 	"strings"
 	"time"
 
-	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/decrypter"
 	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/sqliteutil"
 	"hack-browser-data/internal/utils/typeutil"
 
 	// import sqlite3 driver
@@ -16,9 +16,9 @@ import (
 	"github.com/tidwall/gjson"
 )
 
-type ChromiumDownload []download
+type ChromiumDownload []Download
 
-type download struct {
+type Download struct {
 	TargetPath string
 	URL        string
 	TotalBytes int64
@@ -27,18 +27,37 @@ type download struct {
 	MimeType   string
 }
 
-const (
-	queryChromiumDownload = `SELECT target_path, tab_url, total_bytes, start_time, end_time, mime_type FROM downloads`
-)
+// chromiumDownloadQueries select downloads of History from the newest schema on
+var chromiumDownloadQueries = []sqliteutil.Query{
+	{
+		// the url is the tab the download started from, mime_type is added by newer builds
+		Table: "downloads",
+		Columns: []sqliteutil.Column{
+			{Name: "target_path"}, {Name: "tab_url", Default: "''"}, {Name: "total_bytes"},
+			{Name: "start_time"}, {Name: "end_time"}, {Name: "mime_type", Default: "''"},
+		},
+	},
+	{
+		// before target_path the url of the download was kept in downloads
+		Table: "downloads",
+		Columns: []sqliteutil.Column{
+			{Name: "full_path"}, {Name: "url"}, {Name: "total_bytes"},
+			{Name: "start_time"}, {Name: "end_time", Default: "0"}, {Name: "mime_type", Default: "''"},
+		},
+	},
+}
 
-func (c *ChromiumDownload) Parse(masterKey []byte) error {
-	historyDB, err := sql.Open("sqlite3", item.TempChromiumDownload)
+func (c *ChromiumDownload) Parse(masterKey decrypter.Keys, path string) error {
+	historyDB, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempChromiumDownload)
 	defer historyDB.Close()
-	rows, err := historyDB.Query(queryChromiumDownload)
+	schema, err := sqliteutil.ReadSchema(historyDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumDownloadQueries...)
 	if err != nil {
 		return err
 	}
@@ -51,7 +70,7 @@ func (c *ChromiumDownload) Parse(masterKey []byte) error {
 		if err := rows.Scan(&targetPath, &tabURL, &totalBytes, &startTime, &endTime, &mimeType); err != nil {
 			log.Warn(err)
 		}
-		data := download{
+		data := Download{
 			TargetPath: targetPath,
 			URL:        tabURL,
 			TotalBytes: totalBytes,
@@ -75,30 +94,28 @@ func (c *ChromiumDownload) Length() int {
 	return len(*c)
 }
 
-type FirefoxDownload []download
+type FirefoxDownload []Download
 
 const (
 	queryFirefoxDownload = `SELECT place_id, GROUP_CONCAT(content), url, dateAdded FROM (SELECT * FROM moz_annos INNER JOIN moz_places ON moz_annos.place_id=moz_places.id) t GROUP BY place_id`
 	closeJournalMode     = `PRAGMA journal_mode=off`
 )
 
-func (f *FirefoxDownload) Parse(masterKey []byte) error {
+func (f *FirefoxDownload) Parse(masterKey decrypter.Keys, path string) error {
 	var (
 		err          error
 		keyDB        *sql.DB
 		downloadRows *sql.Rows
 	)
-	keyDB, err = sql.Open("sqlite3", item.TempFirefoxDownload)
+	keyDB, err = sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempFirefoxDownload)
 	defer keyDB.Close()
 	_, err = keyDB.Exec(closeJournalMode)
 	if err != nil {
 		return err
 	}
-	defer keyDB.Close()
 	downloadRows, err = keyDB.Query(queryFirefoxDownload)
 	if err != nil {
 		return err
@@ -118,7 +135,7 @@ func (f *FirefoxDownload) Parse(masterKey []byte) error {
 			json := "{" + contentList[1]
 			endTime := gjson.Get(json, "endTime")
 			fileSize := gjson.Get(json, "fileSize")
-			*f = append(*f, download{
+			*f = append(*f, Download{
 				TargetPath: path,
 				URL:        url,
 				TotalBytes: fileSize.Int(),
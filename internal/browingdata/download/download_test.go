@@ -0,0 +1,84 @@
+package download
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func historyDB(t *testing.T, stmts ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "History")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestChromiumDownloadSchemaVersions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		stmts []string
+		want  Download
+	}{
+		{
+			name: "v20 full_path",
+			stmts: []string{
+				`CREATE TABLE meta (key LONGVARCHAR, value LONGVARCHAR)`,
+				`INSERT INTO meta VALUES ('version', '20')`,
+				`CREATE TABLE downloads (id INTEGER, full_path TEXT, url TEXT, start_time INTEGER, received_bytes INTEGER,
+					total_bytes INTEGER, state INTEGER, end_time INTEGER, opened INTEGER)`,
+				`INSERT INTO downloads VALUES (1, '/tmp/a.zip', 'https://a.com/a.zip', 0, 10, 10, 1, 0, 0)`,
+			},
+			want: Download{TargetPath: "/tmp/a.zip", URL: "https://a.com/a.zip", TotalBytes: 10},
+		},
+		{
+			name: "v29 without mime_type",
+			stmts: []string{
+				`CREATE TABLE meta (key LONGVARCHAR, value LONGVARCHAR)`,
+				`INSERT INTO meta VALUES ('version', '29')`,
+				`CREATE TABLE downloads (id INTEGER, current_path TEXT, target_path TEXT, start_time INTEGER,
+					received_bytes INTEGER, total_bytes INTEGER, end_time INTEGER, tab_url TEXT)`,
+				`INSERT INTO downloads VALUES (1, '/tmp/b.zip', '/tmp/b.zip', 0, 20, 20, 0, 'https://b.com')`,
+			},
+			want: Download{TargetPath: "/tmp/b.zip", URL: "https://b.com", TotalBytes: 20},
+		},
+		{
+			name: "v58 mime_type",
+			stmts: []string{
+				`CREATE TABLE meta (key LONGVARCHAR, value LONGVARCHAR)`,
+				`INSERT INTO meta VALUES ('version', '58')`,
+				`CREATE TABLE downloads (id INTEGER, guid TEXT, current_path TEXT, target_path TEXT, start_time INTEGER,
+					received_bytes INTEGER, total_bytes INTEGER, end_time INTEGER, tab_url TEXT, mime_type TEXT)`,
+				`INSERT INTO downloads VALUES (1, 'g', '/tmp/c.pdf', '/tmp/c.pdf', 0, 30, 30, 0, 'https://c.com', 'application/pdf')`,
+			},
+			want: Download{TargetPath: "/tmp/c.pdf", URL: "https://c.com", TotalBytes: 30, MimeType: "application/pdf"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var d ChromiumDownload
+			if err := d.Parse(nil, historyDB(t, tt.stmts...)); err != nil {
+				t.Fatal(err)
+			}
+			if len(d) != 1 {
+				t.Fatalf("parsed %d downloads, want 1", len(d))
+			}
+			got := d[0]
+			got.StartTime, got.EndTime = tt.want.StartTime, tt.want.EndTime
+			if got != tt.want {
+				t.Errorf("download = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,16 @@
 package extension
 
 import (
-	"os"
-
-	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/decrypter"
 	"hack-browser-data/internal/log"
 	"hack-browser-data/internal/utils/fileutil"
 
 	"github.com/tidwall/gjson"
 )
 
-type ChromiumExtension []*extension
+type ChromiumExtension []*Extension
 
-type extension struct {
+type Extension struct {
 	Name        string
 	Description string
 	Version     string
@@ -23,12 +21,11 @@ const (
 	manifest = "manifest.json"
 )
 
-func (c *ChromiumExtension) Parse(masterKey []byte) error {
-	files, err := fileutil.FilesInFolder(item.TempChromiumExtension, manifest)
+func (c *ChromiumExtension) Parse(masterKey decrypter.Keys, path string) error {
+	files, err := fileutil.FilesInFolder(path, manifest)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(item.TempChromiumExtension)
 	for _, f := range files {
 		file, err := fileutil.ReadFile(f)
 		if err != nil {
@@ -36,7 +33,7 @@ func (c *ChromiumExtension) Parse(masterKey []byte) error {
 			continue
 		}
 		b := gjson.Parse(file)
-		*c = append(*c, &extension{
+		*c = append(*c, &Extension{
 			Name:        b.Get("name").String(),
 			Description: b.Get("description").String(),
 			Version:     b.Get("version").String(),
@@ -54,17 +51,16 @@ func (c *ChromiumExtension) Length() int {
 	return len(*c)
 }
 
-type FirefoxExtension []*extension
+type FirefoxExtension []*Extension
 
-func (f *FirefoxExtension) Parse(masterKey []byte) error {
-	s, err := fileutil.ReadFile(item.TempFirefoxExtension)
+func (f *FirefoxExtension) Parse(masterKey decrypter.Keys, path string) error {
+	s, err := fileutil.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempFirefoxExtension)
 	j := gjson.Parse(s)
 	for _, v := range j.Get("addons").Array() {
-		*f = append(*f, &extension{
+		*f = append(*f, &Extension{
 			Name:        v.Get("defaultLocale.name").String(),
 			Description: v.Get("defaultLocale.description").String(),
 			Version:     v.Get("version").String(),
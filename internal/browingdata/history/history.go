@@ -0,0 +1,257 @@
+package history
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/sqliteutil"
+	"hack-browser-data/internal/utils/typeutil"
+
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type ChromiumHistory []Visit
+
+// Visit is a single visit, not a single URL. Visits of the same URL share
+// the per-URL counters (VisitCount, TypedCount, LastVisitTime).
+type Visit struct {
+	VisitID       int64
+	Title         string
+	URL           string
+	VisitTime     time.Time
+	Transition    string
+	FromVisitID   int64
+	ReferrerURL   string
+	VisitDuration int64 // milliseconds, chromium only
+	VisitCount    int
+	TypedCount    int
+	LastVisitTime time.Time
+}
+
+// chromiumHistoryQueries select visits with their url and the url of the
+// visit they came from, visit_duration is added by newer chromium builds
+var chromiumHistoryQueries = []sqliteutil.Query{
+	{
+		Table: "visits",
+		Alias: "v",
+		Joins: map[string]string{"u": "urls", "fv": "visits", "fu": "urls"},
+		Columns: []sqliteutil.Column{
+			{Name: "id"}, {Table: "u", Name: "url"}, {Table: "u", Name: "title", Default: "''"},
+			{Name: "visit_time"}, {Name: "transition"}, {Name: "from_visit"}, {Table: "fu", Name: "url", Default: "''"},
+			{Name: "visit_duration", Default: "0"}, {Table: "u", Name: "visit_count", Default: "0"},
+			{Table: "u", Name: "typed_count", Default: "0"}, {Table: "u", Name: "last_visit_time", Default: "0"},
+		},
+		Suffix: `INNER JOIN urls u ON v.url = u.id
+			LEFT JOIN visits fv ON v.from_visit = fv.id LEFT JOIN urls fu ON fv.url = fu.id`,
+	},
+}
+
+func (c *ChromiumHistory) Parse(masterKey decrypter.Keys, path string) error {
+	err := c.Stream(masterKey, path, func(row any) error {
+		*c = append(*c, row.(Visit))
+		return nil
+	})
+	sort.Slice(*c, func(i, j int) bool {
+		return (*c)[i].VisitTime.After((*c)[j].VisitTime)
+	})
+	return err
+}
+
+// Stream hands visits to emit in the order of the database
+func (c *ChromiumHistory) Stream(masterKey decrypter.Keys, path string, emit func(row any) error) error {
+	historyDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer historyDB.Close()
+	schema, err := sqliteutil.ReadSchema(historyDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumHistoryQueries...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			url, title, referrer                     string
+			visitID, fromVisit, visitTime, lastVisit int64
+			transition, duration                     int64
+			visitCount, typedCount                   int
+		)
+		if err := rows.Scan(&visitID, &url, &title, &visitTime, &transition, &fromVisit, &referrer, &duration, &visitCount, &typedCount, &lastVisit); err != nil {
+			log.Warn(err)
+		}
+		err := emit(Visit{
+			VisitID:       visitID,
+			Title:         title,
+			URL:           url,
+			VisitTime:     typeutil.TimeEpoch(visitTime),
+			Transition:    chromiumTransition(transition),
+			FromVisitID:   fromVisit,
+			ReferrerURL:   referrer,
+			VisitDuration: duration / 1000,
+			VisitCount:    visitCount,
+			TypedCount:    typedCount,
+			LastVisitTime: typeutil.TimeEpoch(lastVisit),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// chromiumTransition decodes the page transition of a visit, the low byte is
+// the core type and the high bits are qualifiers.
+// @https://source.chromium.org/chromium/chromium/src/+/main:ui/base/page_transition_types.h
+func chromiumTransition(t int64) string {
+	core := []string{
+		"link", "typed", "auto_bookmark", "auto_subframe", "manual_subframe",
+		"generated", "auto_toplevel", "form_submit", "reload", "keyword", "keyword_generated",
+	}
+	qualifiers := []struct {
+		mask int64
+		name string
+	}{
+		{0x00800000, "blocked"},
+		{0x01000000, "forward_back"},
+		{0x02000000, "from_address_bar"},
+		{0x04000000, "home_page"},
+		{0x08000000, "from_api"},
+		{0x10000000, "chain_start"},
+		{0x20000000, "chain_end"},
+		{0x40000000, "client_redirect"},
+		{0x80000000, "server_redirect"},
+	}
+	name := "unknown"
+	if c := t & 0xFF; c < int64(len(core)) {
+		name = core[c]
+	}
+	parts := []string{name}
+	for _, q := range qualifiers {
+		if t&q.mask != 0 {
+			parts = append(parts, q.name)
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+func (c *ChromiumHistory) Name() string {
+	return "history"
+}
+
+func (c *ChromiumHistory) Length() int {
+	return len(*c)
+}
+
+type FirefoxHistory []Visit
+
+const (
+	queryFirefoxHistory = `SELECT v.id, p.url, IFNULL(p.title, ''), v.visit_date, v.visit_type, v.from_visit, IFNULL(fp.url, ''), p.visit_count, p.typed, IFNULL(p.last_visit_date, 0)
+		FROM moz_historyvisits v INNER JOIN moz_places p ON v.place_id = p.id
+		LEFT JOIN moz_historyvisits fv ON v.from_visit = fv.id LEFT JOIN moz_places fp ON fv.place_id = fp.id`
+	closeJournalMode = `PRAGMA journal_mode=off`
+)
+
+func (f *FirefoxHistory) Parse(masterKey decrypter.Keys, path string) error {
+	err := f.Stream(masterKey, path, func(row any) error {
+		*f = append(*f, row.(Visit))
+		return nil
+	})
+	sort.Slice(*f, func(i, j int) bool {
+		return (*f)[i].VisitTime.After((*f)[j].VisitTime)
+	})
+	return err
+}
+
+// Stream hands visits to emit in the order of the database
+func (f *FirefoxHistory) Stream(masterKey decrypter.Keys, path string, emit func(row any) error) error {
+	var (
+		err         error
+		historyDB   *sql.DB
+		historyRows *sql.Rows
+	)
+	historyDB, err = sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer historyDB.Close()
+	_, err = historyDB.Exec(closeJournalMode)
+	if err != nil {
+		log.Error(err)
+	}
+	historyRows, err = historyDB.Query(queryFirefoxHistory)
+	if err != nil {
+		return err
+	}
+	defer historyRows.Close()
+	for historyRows.Next() {
+		var (
+			url, title, referrer                     string
+			visitID, fromVisit, visitDate, lastVisit int64
+			visitType                                int64
+			visitCount, typed                        int
+		)
+		if err = historyRows.Scan(&visitID, &url, &title, &visitDate, &visitType, &fromVisit, &referrer, &visitCount, &typed, &lastVisit); err != nil {
+			log.Warn(err)
+		}
+		err = emit(Visit{
+			VisitID:       visitID,
+			Title:         title,
+			URL:           url,
+			VisitTime:     typeutil.TimeStamp(visitDate / 1000000),
+			Transition:    firefoxTransition(visitType),
+			FromVisitID:   fromVisit,
+			ReferrerURL:   referrer,
+			VisitCount:    visitCount,
+			TypedCount:    typed,
+			LastVisitTime: typeutil.TimeStamp(lastVisit / 1000000),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return historyRows.Err()
+}
+
+// firefoxTransition decodes moz_historyvisits.visit_type
+// @https://searchfox.org/mozilla-central/source/toolkit/components/places/nsINavHistoryService.idl
+func firefoxTransition(t int64) string {
+	switch t {
+	case 1:
+		return "link"
+	case 2:
+		return "typed"
+	case 3:
+		return "bookmark"
+	case 4:
+		return "embed"
+	case 5:
+		return "redirect_permanent"
+	case 6:
+		return "redirect_temporary"
+	case 7:
+		return "download"
+	case 8:
+		return "framed_link"
+	case 9:
+		return "reload"
+	default:
+		return "unknown"
+	}
+}
+
+func (f *FirefoxHistory) Name() string {
+	return "history"
+}
+
+func (f *FirefoxHistory) Length() int {
+	return len(*f)
+}
@@ -0,0 +1,99 @@
+package history
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"hack-browser-data/internal/utils/typeutil"
+)
+
+func TestChromiumHistorySchemas(t *testing.T) {
+	t.Parallel()
+	const (
+		visitTime = 13317102245000000
+		lastVisit = 13317102305000000
+	)
+	tests := []struct {
+		name  string
+		stmts []string
+		want  []Visit
+	}{
+		{
+			name: "without counters and duration",
+			stmts: []string{
+				`CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR)`,
+				`CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL,
+					from_visit INTEGER, transition INTEGER DEFAULT 0 NOT NULL)`,
+				`INSERT INTO urls VALUES (1, 'https://go.dev/', 'Go'), (2, 'https://go.dev/doc/', 'Documentation')`,
+				`INSERT INTO visits VALUES (1, 1, 13317102245000000, 0, 1), (2, 2, 13317102305000000, 1, 0)`,
+			},
+			want: []Visit{
+				{
+					VisitID: 2, Title: "Documentation", URL: "https://go.dev/doc/", VisitTime: typeutil.TimeEpoch(lastVisit),
+					Transition: "link", FromVisitID: 1, ReferrerURL: "https://go.dev/", LastVisitTime: typeutil.TimeEpoch(0),
+				},
+				{
+					VisitID: 1, Title: "Go", URL: "https://go.dev/", VisitTime: typeutil.TimeEpoch(visitTime),
+					Transition: "typed", LastVisitTime: typeutil.TimeEpoch(0),
+				},
+			},
+		},
+		{
+			name: "counters and duration",
+			stmts: []string{
+				`CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, visit_count INTEGER DEFAULT 0 NOT NULL,
+					typed_count INTEGER DEFAULT 0 NOT NULL, last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL)`,
+				`CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL, from_visit INTEGER,
+					transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER, visit_duration INTEGER DEFAULT 0 NOT NULL)`,
+				`INSERT INTO urls VALUES (1, 'https://go.dev/', 'Go', 3, 1, 13317102305000000, 0)`,
+				`INSERT INTO visits VALUES (1, 1, 13317102245000000, 0, 33554433, 0, 2500000)`,
+			},
+			want: []Visit{{
+				VisitID: 1, Title: "Go", URL: "https://go.dev/", VisitTime: typeutil.TimeEpoch(visitTime),
+				Transition: "typed|from_address_bar", VisitDuration: 2500, VisitCount: 3, TypedCount: 1,
+				LastVisitTime: typeutil.TimeEpoch(lastVisit),
+			}},
+		},
+		{
+			name: "null optional columns",
+			stmts: []string{
+				`CREATE TABLE urls (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR, visit_count INTEGER,
+					typed_count INTEGER, last_visit_time INTEGER)`,
+				`CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL, from_visit INTEGER,
+					transition INTEGER DEFAULT 0 NOT NULL, visit_duration INTEGER)`,
+				`INSERT INTO urls VALUES (1, 'https://go.dev/', NULL, NULL, NULL, NULL)`,
+				`INSERT INTO visits VALUES (1, 1, 13317102245000000, 0, 0, NULL)`,
+			},
+			want: []Visit{{
+				VisitID: 1, URL: "https://go.dev/", VisitTime: typeutil.TimeEpoch(visitTime),
+				Transition: "link", LastVisitTime: typeutil.TimeEpoch(0),
+			}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "History")
+			db, err := sql.Open("sqlite3", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.stmts {
+				if _, err := db.Exec(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			db.Close()
+			var c ChromiumHistory
+			if err := c.Parse(nil, path); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual([]Visit(c), tt.want) {
+				t.Errorf("visits = %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package indexeddb
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+
+	"github.com/golang/snappy"
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type FirefoxIndexedDB []Record
+
+const (
+	queryFirefoxDatabase = `SELECT name, origin FROM database`
+	queryFirefoxRecords  = `SELECT IFNULL(s.name, ''), d.key, d.data
+		FROM object_data d LEFT JOIN object_store s ON s.id = d.object_store_id ORDER BY d.object_store_id, d.key`
+)
+
+// Parse reads the databases of the origins of storage/default at path, they
+// are the idb/*.sqlite files of an origin. Values are structured clones
+// compressed with snappy, a database that can't be read is left out and
+// reported in the error.
+func (f *FirefoxIndexedDB) Parse(_ decrypter.Keys, path string) error {
+	databases, err := filepath.Glob(filepath.Join(path, "*", "idb", "*.sqlite"))
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, db := range databases {
+		records, err := readFirefoxDatabase(db)
+		if err != nil {
+			name, _ := filepath.Rel(path, db)
+			log.Warnf("read indexeddb %s error: %s", name, err)
+			failed = append(failed, name)
+			continue
+		}
+		*f = append(*f, records...)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("read indexeddb %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func readFirefoxDatabase(path string) ([]Record, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var name, origin string
+	if err := db.QueryRow(queryFirefoxDatabase).Scan(&name, &origin); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(queryFirefoxRecords)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []Record
+	for rows.Next() {
+		var (
+			objectStore string
+			key, data   []byte
+		)
+		if err := rows.Scan(&objectStore, &key, &data); err != nil {
+			log.Warn(err)
+			continue
+		}
+		record := Record{URL: origin, Database: name, ObjectStore: objectStore}
+		if record.Key, err = firefoxKeyToJSON(key); err != nil {
+			continue
+		}
+		raw, err := snappy.Decode(nil, data)
+		if err != nil {
+			// large values are kept in files of the database
+			record.Raw = data
+		} else if record.Value, err = cloneToJSON(raw); err != nil {
+			record.Raw = raw
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// types of keys of Firefox's IndexedDB, the type of an array is added to
+// the one of its first element, up to 3 nested arrays share a byte
+// @https://searchfox.org/mozilla-central/source/dom/indexedDB/Key.cpp
+const (
+	firefoxKeyTerminator = 0x00
+	firefoxKeyFloat      = 0x10
+	firefoxKeyDate       = 0x20
+	firefoxKeyString     = 0x30
+	firefoxKeyBinary     = 0x40
+	firefoxKeyArray      = 0x50
+
+	firefoxMaxArrayCollapse = 3
+)
+
+// firefoxKeyToJSON returns the json of the key b, like keyToJSON
+func firefoxKeyToJSON(b []byte) (string, error) {
+	d := &firefoxKeyDecoder{b: b}
+	if err := d.readKey(0, 0); err != nil {
+		return "", err
+	}
+	if len(d.b) > 0 {
+		return "", fmt.Errorf("%d bytes after key", len(d.b))
+	}
+	return d.out.String(), nil
+}
+
+type firefoxKeyDecoder struct {
+	b   []byte
+	out bytes.Buffer
+}
+
+func (d *firefoxKeyDecoder) readKey(typeOffset byte, depth int) error {
+	if depth > maxDepth {
+		return errTooDeep
+	}
+	if len(d.b) == 0 {
+		return errTruncated
+	}
+	if d.b[0] < typeOffset {
+		return fmt.Errorf("unknown key type %d", d.b[0])
+	}
+	t := d.b[0] - typeOffset
+	if t >= firefoxKeyArray {
+		typeOffset += firefoxKeyArray
+		if typeOffset == firefoxKeyArray*firefoxMaxArrayCollapse {
+			d.b = d.b[1:]
+			typeOffset = 0
+		}
+		d.out.WriteByte('[')
+		for first := true; len(d.b) > 0 && d.b[0]-typeOffset != firefoxKeyTerminator; first = false {
+			if !first {
+				d.out.WriteByte(',')
+			}
+			if err := d.readKey(typeOffset, depth+1); err != nil {
+				return err
+			}
+			typeOffset = 0
+		}
+		// the terminator of arrays at the end of the key is trimmed
+		d.skip(1)
+		d.out.WriteByte(']')
+		return nil
+	}
+	d.b = d.b[1:]
+	var v any
+	switch t {
+	case firefoxKeyFloat, firefoxKeyDate:
+		f := d.readNumber()
+		v = f
+		if t == firefoxKeyDate && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			v = time.UnixMilli(int64(f)).UTC().Format(time.RFC3339Nano)
+		}
+	case firefoxKeyString:
+		v = string(utf16.Decode(d.readUnits()))
+	case firefoxKeyBinary:
+		units := d.readUnits()
+		b := make([]byte, len(units))
+		for i, u := range units {
+			b[i] = byte(u)
+		}
+		v = base64.StdEncoding.EncodeToString(b)
+	default:
+		return fmt.Errorf("unknown key type %d", t)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		// NaN and infinities have no json
+		encoded = []byte("null")
+	}
+	d.out.Write(encoded)
+	return nil
+}
+
+// readNumber reads a double, it's big endian with the sign bit flipped for
+// positive numbers and negated for the others, so keys sort bytewise.
+// Trailing zero bytes at the end of the key are trimmed.
+func (d *firefoxKeyDecoder) readNumber() float64 {
+	var b [8]byte
+	n := copy(b[:], d.b)
+	d.b = d.b[n:]
+	const signBit = 1 << 63
+	number := binary.BigEndian.Uint64(b[:])
+	if number&signBit != 0 {
+		number &^= signBit
+	} else {
+		number = -number
+	}
+	return math.Float64frombits(number)
+}
+
+// readUnits reads the utf-16 units of a string until its terminator, units
+// up to 0x7e take a byte, units up to 0x3ffe two and the others three
+func (d *firefoxKeyDecoder) readUnits() []uint16 {
+	var units []uint16
+	for len(d.b) > 0 && d.b[0] != firefoxKeyTerminator {
+		var u uint32
+		switch c := uint32(d.b[0]); {
+		case c&0x80 == 0:
+			u = c - 1
+			d.b = d.b[1:]
+		case c&0x40 == 0:
+			u = c << 8
+			if len(d.b) > 1 {
+				u |= uint32(d.b[1])
+			}
+			u = u - 0x8000 + 0x7f
+			d.skip(2)
+		default:
+			u = c << 10
+			if len(d.b) > 1 {
+				u |= uint32(d.b[1]) << 2
+			}
+			if len(d.b) > 2 {
+				u |= uint32(d.b[2]) >> 6
+			}
+			d.skip(3)
+		}
+		units = append(units, uint16(u))
+	}
+	d.skip(1)
+	return units
+}
+
+// skip skips n bytes, or the rest of a truncated key
+func (d *firefoxKeyDecoder) skip(n int) {
+	if n > len(d.b) {
+		n = len(d.b)
+	}
+	d.b = d.b[n:]
+}
+
+func (f *FirefoxIndexedDB) Name() string {
+	return "indexedDB"
+}
+
+func (f *FirefoxIndexedDB) Length() int {
+	return len(*f)
+}
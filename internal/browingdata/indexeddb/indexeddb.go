@@ -0,0 +1,169 @@
+package indexeddb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/leveldbutil"
+
+	"github.com/golang/snappy"
+)
+
+type ChromiumIndexedDB []Record
+
+// Record is a record of an object store, Key and Value are json. Raw is
+// the value as stored if it can't be decoded, like values kept in blobs.
+type Record struct {
+	URL         string
+	Database    string
+	ObjectStore string
+	Key         string
+	Value       string
+	Raw         []byte
+}
+
+var errExternalBlob = errors.New("value is kept in a blob")
+
+// values starting with the version tag and this pseudo version are wrapped
+// by IndexedDB, blink can't read them as they are
+// @https://source.chromium.org/chromium/chromium/src/+/main:third_party/blink/renderer/modules/indexeddb/idb_value_wrapping.h
+const (
+	requiresProcessingVersion = 0x11
+	wrappedInBlob             = 1
+	compressedWithSnappy      = 2
+)
+
+const leveldbSuffix = ".indexeddb.leveldb"
+
+// Parse reads the <origin>.indexeddb.leveldb dirs of the IndexedDB dir at
+// path, records of a dir that can't be read in full are kept and the dir is
+// reported in the error.
+func (c *ChromiumIndexedDB) Parse(_ decrypter.Keys, path string) error {
+	dirs, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, dir := range dirs {
+		name := dir.Name()
+		if !dir.IsDir() || !strings.HasSuffix(name, leveldbSuffix) {
+			continue
+		}
+		records, err := leveldbutil.Read(filepath.Join(path, name))
+		if err != nil {
+			log.Warnf("read indexeddb %s error: %s", name, err)
+			failed = append(failed, name)
+		}
+		*c = append(*c, readRecords(originURL(name), records)...)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("read indexeddb %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// originURL returns the origin of a leveldb dir named like
+// https_go.dev_0.indexeddb.leveldb, port 0 is the default port of the scheme
+func originURL(dir string) string {
+	origin := strings.TrimSuffix(dir, leveldbSuffix)
+	scheme, hostPort, ok := strings.Cut(origin, "_")
+	if !ok {
+		return origin
+	}
+	i := strings.LastIndex(hostPort, "_")
+	if i < 0 {
+		return scheme + "://" + hostPort
+	}
+	host, port := hostPort[:i], hostPort[i+1:]
+	if port == "0" {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + host + ":" + port
+}
+
+// readRecords returns the records of object stores in the leveldb records
+// of an origin, names of databases and object stores come from metadata.
+func readRecords(url string, records []leveldbutil.Record) []Record {
+	databases := make(map[uint64]string)
+	objectStores := make(map[[2]uint64]string)
+	for _, r := range records {
+		prefix, rest, ok := readKeyPrefix(r.Key)
+		if !ok || len(rest) == 0 || prefix.objectStore != 0 || prefix.index != 0 {
+			continue
+		}
+		switch {
+		case prefix.database == 0 && rest[0] == databaseNameType:
+			// the origin, then the name
+			_, rest, ok := readStringWithLength(rest[1:])
+			if !ok {
+				continue
+			}
+			name, _, ok := readStringWithLength(rest)
+			id, idOK := readInt(r.Value)
+			if ok && idOK {
+				databases[id] = name
+			}
+		case prefix.database != 0 && rest[0] == objectStoreMetaDataType:
+			id, rest, ok := readVarint(rest[1:])
+			if ok && len(rest) == 1 && rest[0] == objectStoreName {
+				objectStores[[2]uint64{prefix.database, id}] = utf16BE(r.Value)
+			}
+		}
+	}
+	var result []Record
+	for _, r := range records {
+		prefix, rest, ok := readKeyPrefix(r.Key)
+		if !ok || prefix.database == 0 || prefix.objectStore == 0 || prefix.index != objectStoreDataIndex {
+			continue
+		}
+		key, err := keyToJSON(rest)
+		if err != nil {
+			continue
+		}
+		record := Record{
+			URL:         url,
+			Database:    databases[prefix.database],
+			ObjectStore: objectStores[[2]uint64{prefix.database, prefix.objectStore}],
+			Key:         key,
+		}
+		if record.Value, err = decodeValue(r.Value); err != nil {
+			record.Raw = r.Value
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+// decodeValue returns the json of a value of an object store, the value is
+// prefixed with its version
+func decodeValue(b []byte) (string, error) {
+	_, b, ok := readVarint(b)
+	if !ok {
+		return "", errTruncated
+	}
+	if len(b) >= 3 && b[0] == tagVersion && b[1] == requiresProcessingVersion {
+		switch b[2] {
+		case wrappedInBlob:
+			return "", errExternalBlob
+		case compressedWithSnappy:
+			var err error
+			if b, err = snappy.Decode(nil, b[3:]); err != nil {
+				return "", err
+			}
+		}
+	}
+	return v8ToJSON(b)
+}
+
+func (c *ChromiumIndexedDB) Name() string {
+	return "indexedDB"
+}
+
+func (c *ChromiumIndexedDB) Length() int {
+	return len(*c)
+}
@@ -0,0 +1,183 @@
+package indexeddb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// coding of the leveldb keys of chromium's IndexedDB
+// @https://source.chromium.org/chromium/chromium/src/+/main:content/browser/indexed_db/indexed_db_leveldb_coding.cc
+const (
+	// databaseNameType follows the global prefix, the key names a database
+	// by origin and name
+	databaseNameType = 201
+	// objectStoreMetaDataType follows the prefix of a database, the key
+	// names a metadata of an object store
+	objectStoreMetaDataType = 50
+	objectStoreName         = 0
+	// objectStoreDataIndex is the index id of the records of object stores
+	objectStoreDataIndex = 1
+)
+
+// types of IndexedDB keys
+const (
+	keyNull   = 0
+	keyString = 1
+	keyDate   = 2
+	keyNumber = 3
+	keyArray  = 4
+	keyMin    = 5
+	keyBinary = 6
+)
+
+// keyPrefix starts every key, ids of the global metadata are all 0 and the
+// metadata of a database has no object store
+type keyPrefix struct {
+	database, objectStore, index uint64
+}
+
+// readKeyPrefix returns the prefix of b and the rest of b, the first byte
+// holds the lengths of the little endian ids that follow it
+func readKeyPrefix(b []byte) (keyPrefix, []byte, bool) {
+	if len(b) == 0 {
+		return keyPrefix{}, nil, false
+	}
+	lengths := []int{int(b[0]>>5) + 1, int(b[0]>>2&7) + 1, int(b[0]&3) + 1}
+	b = b[1:]
+	var ids [3]uint64
+	for i, n := range lengths {
+		if len(b) < n {
+			return keyPrefix{}, nil, false
+		}
+		for j := n - 1; j >= 0; j-- {
+			ids[i] = ids[i]<<8 | uint64(b[j])
+		}
+		b = b[n:]
+	}
+	return keyPrefix{database: ids[0], objectStore: ids[1], index: ids[2]}, b, true
+}
+
+func readVarint(b []byte) (uint64, []byte, bool) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, false
+	}
+	return v, b[n:], true
+}
+
+// readStringWithLength returns a string of its length in utf-16 code units
+// followed by the units in big endian
+func readStringWithLength(b []byte) (string, []byte, bool) {
+	n, b, ok := readVarint(b)
+	if !ok || n > uint64(len(b)/2) {
+		return "", nil, false
+	}
+	return utf16BE(b[:2*n]), b[2*n:], true
+}
+
+// utf16BE returns the string of the utf-16 big endian bytes b
+func utf16BE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// readInt returns the little endian int of the bytes of b, ids of databases
+// are stored as such
+func readInt(b []byte) (uint64, bool) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, false
+	}
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, true
+}
+
+// keyToJSON returns the json of the IndexedDB key b, dates are strings of
+// their time and binaries strings of their base64
+func keyToJSON(b []byte) (string, error) {
+	var out bytes.Buffer
+	rest, err := readKey(b, &out, 0)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) > 0 {
+		return "", fmt.Errorf("%d bytes after key", len(rest))
+	}
+	return out.String(), nil
+}
+
+func readKey(b []byte, out *bytes.Buffer, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, errTooDeep
+	}
+	if len(b) == 0 {
+		return nil, errTruncated
+	}
+	t, b := b[0], b[1:]
+	var v any
+	switch t {
+	case keyNull, keyMin:
+		out.WriteString("null")
+		return b, nil
+	case keyString:
+		s, rest, ok := readStringWithLength(b)
+		if !ok {
+			return nil, errTruncated
+		}
+		v, b = s, rest
+	case keyDate, keyNumber:
+		if len(b) < 8 {
+			return nil, errTruncated
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		b = b[8:]
+		v = f
+		if t == keyDate && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			v = time.UnixMilli(int64(f)).UTC().Format(time.RFC3339Nano)
+		}
+	case keyArray:
+		n, rest, ok := readVarint(b)
+		if !ok || n > uint64(len(rest)) {
+			return nil, errTruncated
+		}
+		b = rest
+		out.WriteByte('[')
+		for i := uint64(0); i < n; i++ {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			var err error
+			if b, err = readKey(b, out, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		out.WriteByte(']')
+		return b, nil
+	case keyBinary:
+		n, rest, ok := readVarint(b)
+		if !ok || n > uint64(len(rest)) {
+			return nil, errTruncated
+		}
+		v, b = base64.StdEncoding.EncodeToString(rest[:n]), rest[n:]
+	default:
+		return nil, fmt.Errorf("unknown key type %d", t)
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		// NaN and infinities have no json
+		encoded = []byte("null")
+	}
+	out.Write(encoded)
+	return b, nil
+}
@@ -0,0 +1,98 @@
+package indexeddb
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestKeyToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "string", key: "0102006100e5", want: `"aå"`},
+		{name: "number", key: "03000000000000f83f", want: `1.5`},
+		{name: "date", key: "020000800caa567842", want: `"2023-01-01T00:00:00Z"`},
+		{name: "array", key: "0402030000000000000000010100ff", want: `[0,"ÿ"]`},
+		{name: "binary", key: "0603010203", want: `"AQID"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := hex.DecodeString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := keyToJSON(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("keyToJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirefoxKeyToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "string", key: "30628066d97940", want: `"aå日"`},
+		{name: "number", key: "10bff8", want: `1.5`},
+		{name: "negative number", key: "104010", want: `-1`},
+		{name: "date", key: "20c27856aa0c80", want: `"2023-01-01T00:00:00Z"`},
+		{name: "array", key: "60bff00000000000003062", want: `[1,"a"]`},
+		{name: "empty array", key: "50", want: `[]`},
+		{name: "nested array", key: "a000", want: `[[]]`},
+		{name: "binary", key: "40020304", want: `"AQID"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := hex.DecodeString(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := firefoxKeyToJSON(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("firefoxKeyToJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadKeyPrefix(t *testing.T) {
+	// a database id of 2 bytes, object store and index ids of 1
+	b, err := hex.DecodeString("2001010301ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, rest, ok := readKeyPrefix(b)
+	if !ok {
+		t.Fatal("readKeyPrefix() failed")
+	}
+	if want := (keyPrefix{database: 0x101, objectStore: 3, index: 1}); prefix != want {
+		t.Errorf("readKeyPrefix() = %+v, want %+v", prefix, want)
+	}
+	if len(rest) != 1 || rest[0] != 0xff {
+		t.Errorf("readKeyPrefix() rest = %x, want ff", rest)
+	}
+}
+
+func TestOriginURL(t *testing.T) {
+	tests := map[string]string{
+		"https_go.dev_0.indexeddb.leveldb":            "https://go.dev",
+		"http_localhost_8080.indexeddb.leveldb":       "http://localhost:8080",
+		"chrome-extension_abcdef_0.indexeddb.leveldb": "chrome-extension://abcdef",
+	}
+	for dir, want := range tests {
+		if got := originURL(dir); got != want {
+			t.Errorf("originURL(%s) = %s, want %s", dir, got, want)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package indexeddb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"hack-browser-data/internal/utils/typeutil"
+)
+
+// tags of SpiderMonkey's structured clone, values are pairs of a tag and
+// 32 bits of data in 64 bits, doubles are pairs with a tag up to scFloatMax
+// @https://searchfox.org/mozilla-central/source/js/src/vm/StructuredClone.cpp
+const (
+	scFloatMax      = 0xfff00000
+	scHeader        = 0xfff10000
+	scNull          = 0xffff0000
+	scUndefined     = 0xffff0001
+	scBoolean       = 0xffff0002
+	scInt32         = 0xffff0003
+	scString        = 0xffff0004
+	scDateObject    = 0xffff0005
+	scRegExpObject  = 0xffff0006
+	scArrayObject   = 0xffff0007
+	scObjectObject  = 0xffff0008
+	scArrayBufferV2 = 0xffff0009
+	scBooleanObject = 0xffff000a
+	scStringObject  = 0xffff000b
+	scNumberObject  = 0xffff000c
+	scBackReference = 0xffff000d
+	scTypedArrayV2  = 0xffff0010
+	scMapObject     = 0xffff0011
+	scSetObject     = 0xffff0012
+	scEndOfKeys     = 0xffff0013
+	scDataViewV2    = 0xffff0015
+	scBigInt        = 0xffff001d
+	scBigIntObject  = 0xffff001e
+	scArrayBuffer   = 0xffff001f
+	scTypedArray    = 0xffff0020
+	scDataView      = 0xffff0021
+)
+
+// bits of the data of strings and bigints, the rest is their length
+const (
+	scStringLatin1   = 0x80000000
+	scBigIntNegative = 0x80000000
+)
+
+// scRegExpFlags are the flags of a RegExp, in the order of their bits
+const scRegExpFlags = "igmyusdv"
+
+// typedArraySizes are the sizes of elements of typed arrays by their type
+var typedArraySizes = []uint64{1, 1, 2, 2, 4, 4, 4, 8, 1, 8, 8, 2}
+
+// cloneReader writes a structured clone as json, like v8Deserializer
+type cloneReader struct {
+	b       []byte
+	out     bytes.Buffer
+	objects [][]byte
+	depth   int
+}
+
+// cloneToJSON returns the json of the structured clone b
+func cloneToJSON(b []byte) (string, error) {
+	r := &cloneReader{b: b}
+	tag, data, err := r.readPair()
+	if err != nil {
+		return "", err
+	}
+	if tag == scHeader {
+		if tag, data, err = r.readPair(); err != nil {
+			return "", err
+		}
+	}
+	if err := r.readValue(tag, data); err != nil {
+		return "", err
+	}
+	return r.out.String(), nil
+}
+
+func (r *cloneReader) readUint64() (uint64, error) {
+	if len(r.b) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v, nil
+}
+
+func (r *cloneReader) readPair() (tag, data uint32, err error) {
+	v, err := r.readUint64()
+	return uint32(v >> 32), uint32(v), err
+}
+
+// peekTag returns the tag of the next pair without reading it
+func (r *cloneReader) peekTag() (uint32, error) {
+	if len(r.b) < 8 {
+		return 0, errTruncated
+	}
+	return binary.LittleEndian.Uint32(r.b[4:]), nil
+}
+
+// readBytes reads n bytes, they're padded to 8 bytes
+func (r *cloneReader) readBytes(n uint64) ([]byte, error) {
+	padded := (n + 7) &^ 7
+	if n > uint64(len(r.b)) || padded > uint64(len(r.b)) {
+		return nil, errTruncated
+	}
+	b := r.b[:n]
+	r.b = r.b[padded:]
+	return b, nil
+}
+
+func (r *cloneReader) write(v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		// NaN and infinities have no json
+		b = []byte("null")
+	}
+	r.out.Write(b)
+}
+
+// readValue writes the json of the value starting with the pair of tag and
+// data
+func (r *cloneReader) readValue(tag, data uint32) error {
+	if r.depth++; r.depth > maxDepth {
+		return errTooDeep
+	}
+	defer func() { r.depth-- }()
+	if r.out.Len() > maxOutput {
+		return errTooLarge
+	}
+	if tag <= scFloatMax {
+		r.write(math.Float64frombits(uint64(tag)<<32 | uint64(data)))
+		return nil
+	}
+	switch tag {
+	case scNull, scUndefined:
+		r.out.WriteString("null")
+	case scBoolean:
+		r.write(data != 0)
+	case scInt32:
+		r.out.WriteString(strconv.FormatInt(int64(int32(data)), 10))
+	case scString:
+		s, err := r.readString(data)
+		if err != nil {
+			return err
+		}
+		r.write(s)
+	case scBigInt:
+		return r.readBigInt(data)
+	case scBackReference:
+		if uint64(data) >= uint64(len(r.objects)) {
+			return fmt.Errorf("reference to unknown object %d", data)
+		}
+		if r.objects[data] == nil {
+			// a cycle, the object isn't complete yet
+			r.out.WriteString("null")
+		} else {
+			r.out.Write(r.objects[data])
+		}
+	default:
+		return r.readObject(tag, data)
+	}
+	return nil
+}
+
+// readObject writes the json of an object, objects are numbered in the order
+// they begin for back references
+func (r *cloneReader) readObject(tag, data uint32) error {
+	id := len(r.objects)
+	r.objects = append(r.objects, nil)
+	start := r.out.Len()
+	var err error
+	switch tag {
+	case scBooleanObject:
+		r.write(data != 0)
+	case scStringObject:
+		var s string
+		if s, err = r.readString(data); err == nil {
+			r.write(s)
+		}
+	case scNumberObject, scDateObject:
+		var v uint64
+		if v, err = r.readUint64(); err == nil {
+			f := math.Float64frombits(v)
+			if tag == scDateObject {
+				writeDate(&r.out, f)
+			} else {
+				r.write(f)
+			}
+		}
+	case scBigIntObject:
+		var t uint32
+		if t, data, err = r.readPair(); err == nil && t != scBigInt {
+			err = fmt.Errorf("tag 0x%08x isn't a bigint: %w", t, errNotSupported)
+		}
+		if err == nil {
+			err = r.readBigInt(data)
+		}
+	case scRegExpObject:
+		err = r.readRegExp(data)
+	case scObjectObject:
+		err = r.readProperties(false)
+	case scArrayObject:
+		err = r.readProperties(true)
+	case scMapObject:
+		err = r.readEntries(2)
+	case scSetObject:
+		err = r.readEntries(1)
+	case scArrayBuffer, scArrayBufferV2:
+		var b []byte
+		if b, err = r.readArrayBuffer(tag, data); err == nil {
+			r.write(base64.StdEncoding.EncodeToString(b))
+		}
+	case scTypedArray, scTypedArrayV2, scDataView, scDataViewV2:
+		err = r.readView(tag, data)
+	default:
+		return fmt.Errorf("unknown tag 0x%08x: %w", tag, errNotSupported)
+	}
+	if err != nil {
+		return err
+	}
+	r.objects[id] = append([]byte{}, r.out.Bytes()[start:]...)
+	return nil
+}
+
+// readString returns a string of data, its length and whether it's latin-1
+// or utf-16
+func (r *cloneReader) readString(data uint32) (string, error) {
+	n := uint64(data &^ scStringLatin1)
+	if data&scStringLatin1 != 0 {
+		b, err := r.readBytes(n)
+		return typeutil.Latin1(b), err
+	}
+	b, err := r.readBytes(2 * n)
+	return typeutil.UTF16LE(b), err
+}
+
+// readBigInt writes a bigint as a json string, its 64 bit digits are little
+// endian
+func (r *cloneReader) readBigInt(data uint32) error {
+	n := uint64(data &^ scBigIntNegative)
+	if n > uint64(len(r.b))/8 {
+		return errTruncated
+	}
+	v := new(big.Int)
+	digits := make([]uint64, n)
+	for i := range digits {
+		digits[i], _ = r.readUint64()
+	}
+	for i := len(digits) - 1; i >= 0; i-- {
+		v.Lsh(v, 64)
+		v.Or(v, new(big.Int).SetUint64(digits[i]))
+	}
+	if data&scBigIntNegative != 0 {
+		v.Neg(v)
+	}
+	r.write(v.String())
+	return nil
+}
+
+// readRegExp writes a RegExp as its literal, the flags are data and the
+// pattern follows
+func (r *cloneReader) readRegExp(flags uint32) error {
+	tag, data, err := r.readPair()
+	if err != nil {
+		return err
+	}
+	if tag != scString {
+		return fmt.Errorf("tag 0x%08x isn't a string: %w", tag, errNotSupported)
+	}
+	pattern, err := r.readString(data)
+	if err != nil {
+		return err
+	}
+	literal := "/" + pattern + "/"
+	for i := range scRegExpFlags {
+		if flags&(1<<i) != 0 {
+			literal += scRegExpFlags[i : i+1]
+		}
+	}
+	r.write(literal)
+	return nil
+}
+
+// readProperties writes the keys and values until the end of keys as a json
+// object, arrays of all their elements are written as json arrays
+func (r *cloneReader) readProperties(array bool) error {
+	start := r.out.Len()
+	r.out.WriteByte('{')
+	// values are the offsets of values in out, kept to rewrite arrays
+	var values [][2]int
+	dense := array
+	for first := true; ; first = false {
+		tag, data, err := r.readPair()
+		if err != nil {
+			return err
+		}
+		if tag == scEndOfKeys {
+			break
+		}
+		if !first {
+			r.out.WriteByte(',')
+		}
+		switch tag {
+		case scInt32:
+			dense = dense && int(data) == len(values)
+			r.write(strconv.FormatInt(int64(int32(data)), 10))
+		case scString:
+			dense = false
+			s, err := r.readString(data)
+			if err != nil {
+				return err
+			}
+			r.write(s)
+		default:
+			return fmt.Errorf("tag 0x%08x isn't a key: %w", tag, errNotSupported)
+		}
+		r.out.WriteByte(':')
+		if tag, data, err = r.readPair(); err != nil {
+			return err
+		}
+		valueStart := r.out.Len()
+		if err := r.readValue(tag, data); err != nil {
+			return err
+		}
+		values = append(values, [2]int{valueStart, r.out.Len()})
+	}
+	r.out.WriteByte('}')
+	if !dense {
+		return nil
+	}
+	elements := make([][]byte, len(values))
+	for i, v := range values {
+		elements[i] = r.out.Bytes()[v[0]:v[1]]
+	}
+	rewritten := append(append([]byte{'['}, bytes.Join(elements, []byte{','})...), ']')
+	r.out.Truncate(start)
+	r.out.Write(rewritten)
+	return nil
+}
+
+// readEntries writes the values until the end of keys as a json array, n
+// values make an entry
+func (r *cloneReader) readEntries(n int) error {
+	r.out.WriteByte('[')
+	for first := true; ; first = false {
+		tag, data, err := r.readPair()
+		if err != nil {
+			return err
+		}
+		if tag == scEndOfKeys {
+			break
+		}
+		if !first {
+			r.out.WriteByte(',')
+		}
+		if n > 1 {
+			r.out.WriteByte('[')
+		}
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				r.out.WriteByte(',')
+				if tag, data, err = r.readPair(); err != nil {
+					return err
+				}
+			}
+			if err := r.readValue(tag, data); err != nil {
+				return err
+			}
+		}
+		if n > 1 {
+			r.out.WriteByte(']')
+		}
+	}
+	r.out.WriteByte(']')
+	return nil
+}
+
+// readArrayBuffer returns the bytes of a buffer, its length is data in the
+// version 2 and the next pair in the current one
+func (r *cloneReader) readArrayBuffer(tag, data uint32) ([]byte, error) {
+	n := uint64(data)
+	if tag == scArrayBuffer {
+		var err error
+		if n, err = r.readUint64(); err != nil {
+			return nil, err
+		}
+	}
+	return r.readBytes(n)
+}
+
+// readView writes the bytes of a typed array or a data view as base64, the
+// length and the buffer follow the pair, then the offset into the buffer.
+// Views of a buffer written before are written as the whole buffer.
+func (r *cloneReader) readView(tag, data uint32) error {
+	size := uint64(1)
+	if tag == scTypedArray || tag == scTypedArrayV2 {
+		if uint64(data) >= uint64(len(typedArraySizes)) {
+			return fmt.Errorf("unknown typed array type %d: %w", data, errNotSupported)
+		}
+		size = typedArraySizes[data]
+	}
+	n, err := r.readUint64()
+	if err != nil {
+		return err
+	}
+	bufferTag, err := r.peekTag()
+	if err != nil {
+		return err
+	}
+	if bufferTag != scArrayBuffer && bufferTag != scArrayBufferV2 {
+		tag, data, err := r.readPair()
+		if err != nil {
+			return err
+		}
+		if err := r.readValue(tag, data); err != nil {
+			return err
+		}
+		_, err = r.readUint64()
+		return err
+	}
+	bufferTag, data, _ = r.readPair()
+	id := len(r.objects)
+	r.objects = append(r.objects, nil)
+	b, err := r.readArrayBuffer(bufferTag, data)
+	if err != nil {
+		return err
+	}
+	encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString(b))
+	r.objects[id] = encoded
+	offset, err := r.readUint64()
+	if err != nil {
+		return err
+	}
+	if n > math.MaxUint64/size || offset > uint64(len(b)) || n*size > uint64(len(b))-offset {
+		return errTruncated
+	}
+	r.write(base64.StdEncoding.EncodeToString(b[offset : offset+n*size]))
+	return nil
+}
@@ -0,0 +1,122 @@
+package indexeddb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// clone returns the structured clone of pairs, a pair is a tag and data,
+// or raw bytes after a pair
+func clone(values ...any) []byte {
+	var b []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case [2]uint32:
+			b = binary.LittleEndian.AppendUint64(b, uint64(v[0])<<32|uint64(v[1]))
+		case float64:
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+		case uint64:
+			b = binary.LittleEndian.AppendUint64(b, v)
+		case string:
+			b = append(b, v...)
+			for len(b)%8 != 0 {
+				b = append(b, 0)
+			}
+		}
+	}
+	return b
+}
+
+func TestCloneToJSON(t *testing.T) {
+	header := [2]uint32{scHeader, 0}
+	end := [2]uint32{scEndOfKeys, 0}
+	latin1 := func(s string) [2]uint32 { return [2]uint32{scString, uint32(len(s)) | scStringLatin1} }
+	tests := []struct {
+		name  string
+		value []byte
+		want  string
+	}{
+		{
+			name: "object",
+			value: clone(header, [2]uint32{scObjectObject, 0},
+				latin1("title"), "title", latin1("h\xe9llo"), "h\xe9llo",
+				latin1("n"), "n", [2]uint32{scInt32, uint32(0xfffffffb)},
+				latin1("f"), "f", 1.5,
+				latin1("done"), "done", [2]uint32{scBoolean, 1},
+				latin1("tags"), "tags", [2]uint32{scArrayObject, 2},
+				[2]uint32{scInt32, 0}, latin1("go"), "go",
+				[2]uint32{scInt32, 1}, [2]uint32{scString, 2}, "\xe5\x65\x2c\x67",
+				end, end),
+			want: `{"title":"héllo","n":-5,"f":1.5,"done":true,"tags":["go","日本"]}`,
+		},
+		{
+			name: "sparse array",
+			value: clone([2]uint32{scArrayObject, 3},
+				[2]uint32{scInt32, 0}, [2]uint32{scInt32, 1},
+				[2]uint32{scInt32, 2}, [2]uint32{scInt32, 3}, end),
+			want: `{"0":1,"2":3}`,
+		},
+		{name: "date", value: clone([2]uint32{scDateObject, 0}, 1672531200000.0), want: `"2023-01-01T00:00:00Z"`},
+		{
+			name:  "map",
+			value: clone([2]uint32{scMapObject, 0}, latin1("k"), "k", latin1("1"), "1", end),
+			want:  `[["k","1"]]`,
+		},
+		{
+			name:  "set",
+			value: clone([2]uint32{scSetObject, 0}, [2]uint32{scInt32, 1}, [2]uint32{scInt32, 2}, end),
+			want:  `[1,2]`,
+		},
+		{
+			name: "cycle",
+			value: clone([2]uint32{scObjectObject, 0}, latin1("a"), "a", [2]uint32{scInt32, 1},
+				latin1("self"), "self", [2]uint32{scBackReference, 0}, end),
+			want: `{"a":1,"self":null}`,
+		},
+		{
+			name: "reference",
+			value: clone([2]uint32{scObjectObject, 0},
+				latin1("x"), "x", [2]uint32{scArrayObject, 1}, [2]uint32{scInt32, 0}, [2]uint32{scInt32, 1}, end,
+				latin1("y"), "y", [2]uint32{scBackReference, 1}, end),
+			want: `{"x":[1],"y":[1]}`,
+		},
+		{name: "regexp", value: clone([2]uint32{scRegExpObject, 3}, latin1("ab+c"), "ab+c"), want: `"/ab+c/ig"`},
+		{name: "bigint", value: clone([2]uint32{scBigInt, 1 | scBigIntNegative}, uint64(1)<<40), want: `"-1099511627776"`},
+		{
+			name:  "uint8array",
+			value: clone([2]uint32{scTypedArray, 1}, uint64(2), [2]uint32{scArrayBuffer, 0}, uint64(4), "\x01\x02\x03\x04", uint64(1)),
+			want:  `"AgM="`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cloneToJSON(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("cloneToJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloneToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+		err   error
+	}{
+		{name: "unknown tag", value: clone([2]uint32{0xffff0100, 0}), err: errNotSupported},
+		{name: "truncated", value: clone([2]uint32{scObjectObject, 0}, [2]uint32{scString, 5 | scStringLatin1}), err: errTruncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := cloneToJSON(tt.value); !errors.Is(err, tt.err) {
+				t.Errorf("cloneToJSON() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,670 @@
+package indexeddb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"time"
+
+	"hack-browser-data/internal/utils/typeutil"
+)
+
+var (
+	errTruncated    = errors.New("truncated value")
+	errHostObject   = errors.New("host objects are not supported")
+	errTooDeep      = errors.New("value nested too deep")
+	errTooLarge     = errors.New("value too large")
+	errNotSupported = errors.New("unsupported serialization")
+)
+
+// tags of the V8 ValueSerializer
+// @https://source.chromium.org/chromium/chromium/src/+/main:v8/src/objects/value-serializer.cc
+const (
+	tagVersion             = 0xff
+	tagPadding             = 0x00
+	tagVerifyObjectCount   = '?'
+	tagTheHole             = '-'
+	tagUndefined           = '_'
+	tagNull                = '0'
+	tagTrue                = 'T'
+	tagFalse               = 'F'
+	tagInt32               = 'I'
+	tagUint32              = 'U'
+	tagDouble              = 'N'
+	tagBigInt              = 'Z'
+	tagUtf8String          = 'S'
+	tagOneByteString       = '"'
+	tagTwoByteString       = 'c'
+	tagObjectReference     = '^'
+	tagBeginJSObject       = 'o'
+	tagEndJSObject         = '{'
+	tagBeginSparseJSArray  = 'a'
+	tagEndSparseJSArray    = '@'
+	tagBeginDenseJSArray   = 'A'
+	tagEndDenseJSArray     = '$'
+	tagDate                = 'D'
+	tagTrueObject          = 'y'
+	tagFalseObject         = 'x'
+	tagNumberObject        = 'n'
+	tagBigIntObject        = 'z'
+	tagStringObject        = 's'
+	tagRegExp              = 'R'
+	tagBeginJSMap          = ';'
+	tagEndJSMap            = ':'
+	tagBeginJSSet          = '\''
+	tagEndJSSet            = ','
+	tagArrayBuffer         = 'B'
+	tagResizableBuffer     = '~'
+	tagArrayBufferView     = 'V'
+	tagError               = 'r'
+	tagHostObject          = '\\'
+	arrayBufferViewFlagsV8 = 14
+)
+
+// tags of errors, they follow tagError until errorEnd
+const (
+	errorEvalPrototype      = 'E'
+	errorRangePrototype     = 'R'
+	errorReferencePrototype = 'F'
+	errorSyntaxPrototype    = 'S'
+	errorTypePrototype      = 'T'
+	errorURIPrototype       = 'U'
+	errorMessage            = 'm'
+	errorCause              = 'c'
+	errorStack              = 's'
+	errorEnd                = '.'
+)
+
+// tags of the blink envelope of V8 values
+// @https://source.chromium.org/chromium/chromium/src/+/main:third_party/blink/renderer/bindings/core/v8/serialization/serialization_tag.h
+const (
+	blinkTrailerOffset = 0xfe
+	// the trailer offset is followed by an offset and a size
+	blinkTrailerSize = 12
+)
+
+const (
+	maxDepth = 512
+	// maxOutput bounds the json of a value, references copy the json of
+	// the object they name
+	maxOutput = 16 << 20
+)
+
+// regExpFlags are the flags of a RegExp, in the order of their bits
+const regExpFlags = "gimyusldv"
+
+// v8Deserializer writes a value of the V8 ValueSerializer as json
+type v8Deserializer struct {
+	b       []byte
+	version uint64
+	out     bytes.Buffer
+	// objects are the json of objects by id, nil until the object is
+	// complete, references to it write null then
+	objects [][]byte
+	depth   int
+}
+
+// v8ToJSON returns the json of the serialized value b, b is a V8 value in
+// the envelope of blink or a bare one.
+func v8ToJSON(b []byte) (string, error) {
+	d := &v8Deserializer{b: b}
+	if err := d.readHeader(); err != nil {
+		return "", err
+	}
+	if err := d.readValue(); err != nil {
+		return "", err
+	}
+	return d.out.String(), nil
+}
+
+// readHeader reads the version of blink and the one of V8, values written
+// by V8 alone have the latter only
+func (d *v8Deserializer) readHeader() error {
+	if len(d.b) == 0 || d.b[0] != tagVersion {
+		return errNotSupported
+	}
+	d.b = d.b[1:]
+	version, err := d.readVarint()
+	if err != nil {
+		return err
+	}
+	if len(d.b) > 0 && d.b[0] == blinkTrailerOffset {
+		if len(d.b) < 1+blinkTrailerSize {
+			return errTruncated
+		}
+		d.b = d.b[1+blinkTrailerSize:]
+	}
+	if len(d.b) > 0 && d.b[0] == tagVersion {
+		d.b = d.b[1:]
+		if version, err = d.readVarint(); err != nil {
+			return err
+		}
+	}
+	d.version = version
+	return nil
+}
+
+func (d *v8Deserializer) readVarint() (uint64, error) {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *v8Deserializer) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)) {
+		return nil, errTruncated
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+func (d *v8Deserializer) readDouble() (float64, error) {
+	b, err := d.readBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+}
+
+// readTag returns the next tag, padding is skipped
+func (d *v8Deserializer) readTag() (byte, error) {
+	for len(d.b) > 0 {
+		t := d.b[0]
+		d.b = d.b[1:]
+		if t != tagPadding {
+			return t, nil
+		}
+	}
+	return 0, errTruncated
+}
+
+// peekTag returns the next tag without reading it
+func (d *v8Deserializer) peekTag() (byte, error) {
+	for len(d.b) > 0 && d.b[0] == tagPadding {
+		d.b = d.b[1:]
+	}
+	if len(d.b) == 0 {
+		return 0, errTruncated
+	}
+	return d.b[0], nil
+}
+
+func (d *v8Deserializer) write(v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		// NaN and infinities have no json
+		b = []byte("null")
+	}
+	d.out.Write(b)
+}
+
+// readValue writes the json of the next value
+func (d *v8Deserializer) readValue() error {
+	if d.depth++; d.depth > maxDepth {
+		return errTooDeep
+	}
+	defer func() { d.depth-- }()
+	if d.out.Len() > maxOutput {
+		return errTooLarge
+	}
+	tag, err := d.readTag()
+	if err != nil {
+		return err
+	}
+	switch tag {
+	case tagVerifyObjectCount:
+		if _, err := d.readVarint(); err != nil {
+			return err
+		}
+		return d.readValue()
+	case tagUndefined, tagNull, tagTheHole:
+		d.out.WriteString("null")
+	case tagTrue:
+		d.out.WriteString("true")
+	case tagFalse:
+		d.out.WriteString("false")
+	case tagInt32:
+		v, err := d.readVarint()
+		if err != nil {
+			return err
+		}
+		// zigzag encoded
+		d.out.WriteString(strconv.FormatInt(int64(int32(v>>1)^-int32(v&1)), 10))
+	case tagUint32:
+		v, err := d.readVarint()
+		if err != nil {
+			return err
+		}
+		d.out.WriteString(strconv.FormatUint(uint64(uint32(v)), 10))
+	case tagDouble:
+		v, err := d.readDouble()
+		if err != nil {
+			return err
+		}
+		d.write(v)
+	case tagBigInt:
+		return d.readBigInt()
+	case tagUtf8String, tagOneByteString, tagTwoByteString:
+		s, err := d.readString(tag)
+		if err != nil {
+			return err
+		}
+		d.write(s)
+	case tagObjectReference:
+		id, err := d.readVarint()
+		if err != nil {
+			return err
+		}
+		if id >= uint64(len(d.objects)) {
+			return fmt.Errorf("reference to unknown object %d", id)
+		}
+		if d.objects[id] == nil {
+			// a cycle, the object isn't complete yet
+			d.out.WriteString("null")
+		} else {
+			d.out.Write(d.objects[id])
+		}
+	default:
+		return d.readObject(tag)
+	}
+	return nil
+}
+
+// readObject writes the json of an object, objects are numbered in the order
+// they begin for references
+func (d *v8Deserializer) readObject(tag byte) error {
+	id := len(d.objects)
+	d.objects = append(d.objects, nil)
+	start := d.out.Len()
+	var err error
+	switch tag {
+	case tagBeginJSObject:
+		err = d.readProperties(tagEndJSObject, '{', '}')
+		if err == nil {
+			_, err = d.readVarint()
+		}
+	case tagBeginSparseJSArray:
+		// sparse arrays are written as objects of their indexes
+		if _, err = d.readVarint(); err == nil {
+			err = d.readProperties(tagEndSparseJSArray, '{', '}')
+		}
+		if err == nil {
+			err = d.skipVarints(2)
+		}
+	case tagBeginDenseJSArray:
+		err = d.readDenseArray()
+	case tagDate:
+		var ms float64
+		if ms, err = d.readDouble(); err == nil {
+			writeDate(&d.out, ms)
+		}
+	case tagTrueObject:
+		d.out.WriteString("true")
+	case tagFalseObject:
+		d.out.WriteString("false")
+	case tagNumberObject:
+		var v float64
+		if v, err = d.readDouble(); err == nil {
+			d.write(v)
+		}
+	case tagBigIntObject:
+		err = d.readBigInt()
+	case tagStringObject:
+		err = d.readStringValue()
+	case tagRegExp:
+		err = d.readRegExp()
+	case tagBeginJSMap:
+		// maps are written as arrays of their key and value pairs
+		err = d.readEntries(tagEndJSMap, 2)
+	case tagBeginJSSet:
+		err = d.readEntries(tagEndJSSet, 1)
+	case tagArrayBuffer, tagResizableBuffer:
+		err = d.readArrayBuffer(tag)
+	case tagError:
+		err = d.readError()
+	case tagHostObject:
+		return errHostObject
+	default:
+		return fmt.Errorf("unknown tag 0x%02x: %w", tag, errNotSupported)
+	}
+	if err != nil {
+		return err
+	}
+	d.objects[id] = append([]byte{}, d.out.Bytes()[start:]...)
+	return nil
+}
+
+// readProperties writes the keys and values until end as a json object,
+// keys that are numbers are quoted
+func (d *v8Deserializer) readProperties(end, open, closing byte) error {
+	d.out.WriteByte(open)
+	for first := true; ; first = false {
+		tag, err := d.peekTag()
+		if err != nil {
+			return err
+		}
+		if tag == end {
+			d.b = d.b[1:]
+			break
+		}
+		if !first {
+			d.out.WriteByte(',')
+		}
+		if err := d.readKey(); err != nil {
+			return err
+		}
+		d.out.WriteByte(':')
+		if err := d.readValue(); err != nil {
+			return err
+		}
+	}
+	d.out.WriteByte(closing)
+	return nil
+}
+
+// readKey writes the next value as a json string
+func (d *v8Deserializer) readKey() error {
+	start := d.out.Len()
+	if err := d.readValue(); err != nil {
+		return err
+	}
+	key := d.out.Bytes()[start:]
+	if len(key) > 0 && key[0] == '"' {
+		return nil
+	}
+	quoted := strconv.Quote(string(key))
+	d.out.Truncate(start)
+	d.out.WriteString(quoted)
+	return nil
+}
+
+// readDenseArray writes the elements of an array, properties of the array
+// that aren't elements are left out
+func (d *v8Deserializer) readDenseArray() error {
+	length, err := d.readVarint()
+	if err != nil {
+		return err
+	}
+	if length > uint64(len(d.b)) {
+		return errTruncated
+	}
+	d.out.WriteByte('[')
+	for i := uint64(0); i < length; i++ {
+		if i > 0 {
+			d.out.WriteByte(',')
+		}
+		if err := d.readValue(); err != nil {
+			return err
+		}
+	}
+	d.out.WriteByte(']')
+	for {
+		tag, err := d.peekTag()
+		if err != nil {
+			return err
+		}
+		if tag == tagEndDenseJSArray {
+			d.b = d.b[1:]
+			return d.skipVarints(2)
+		}
+		start := d.out.Len()
+		if err := d.readValue(); err != nil {
+			return err
+		}
+		if err := d.readValue(); err != nil {
+			return err
+		}
+		d.out.Truncate(start)
+	}
+}
+
+// readEntries writes the values until end as a json array, n values make
+// an entry
+func (d *v8Deserializer) readEntries(end byte, n int) error {
+	d.out.WriteByte('[')
+	for first := true; ; first = false {
+		tag, err := d.peekTag()
+		if err != nil {
+			return err
+		}
+		if tag == end {
+			d.b = d.b[1:]
+			break
+		}
+		if !first {
+			d.out.WriteByte(',')
+		}
+		if n > 1 {
+			d.out.WriteByte('[')
+		}
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				d.out.WriteByte(',')
+			}
+			if err := d.readValue(); err != nil {
+				return err
+			}
+		}
+		if n > 1 {
+			d.out.WriteByte(']')
+		}
+	}
+	d.out.WriteByte(']')
+	_, err := d.readVarint()
+	return err
+}
+
+func (d *v8Deserializer) skipVarints(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := d.readVarint(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readString returns a string of tag, one byte strings are latin-1 and two
+// byte strings utf-16 little endian
+func (d *v8Deserializer) readString(tag byte) (string, error) {
+	n, err := d.readVarint()
+	if err != nil {
+		return "", err
+	}
+	b, err := d.readBytes(n)
+	if err != nil {
+		return "", err
+	}
+	switch tag {
+	case tagOneByteString:
+		return typeutil.Latin1(b), nil
+	case tagTwoByteString:
+		return typeutil.UTF16LE(b), nil
+	}
+	return string(b), nil
+}
+
+// nextString returns the next value, it must be a string
+func (d *v8Deserializer) nextString() (string, error) {
+	tag, err := d.readTag()
+	if err != nil {
+		return "", err
+	}
+	if tag != tagUtf8String && tag != tagOneByteString && tag != tagTwoByteString {
+		return "", fmt.Errorf("tag 0x%02x isn't a string: %w", tag, errNotSupported)
+	}
+	return d.readString(tag)
+}
+
+// readStringValue writes the next value, it must be a string
+func (d *v8Deserializer) readStringValue() error {
+	s, err := d.nextString()
+	if err != nil {
+		return err
+	}
+	d.write(s)
+	return nil
+}
+
+// readBigInt writes a bigint as a json string, its digits are little endian
+// and the lowest bit of the bitfield is the sign
+func (d *v8Deserializer) readBigInt() error {
+	bitfield, err := d.readVarint()
+	if err != nil {
+		return err
+	}
+	digits, err := d.readBytes(bitfield >> 1)
+	if err != nil {
+		return err
+	}
+	be := make([]byte, len(digits))
+	for i, b := range digits {
+		be[len(digits)-1-i] = b
+	}
+	v := new(big.Int).SetBytes(be)
+	if bitfield&1 == 1 {
+		v.Neg(v)
+	}
+	d.write(v.String())
+	return nil
+}
+
+// writeDate writes the milliseconds since the unix epoch as a json string
+func writeDate(out *bytes.Buffer, ms float64) {
+	if math.IsNaN(ms) || math.IsInf(ms, 0) {
+		out.WriteString("null")
+		return
+	}
+	b, _ := json.Marshal(time.UnixMilli(int64(ms)).UTC().Format(time.RFC3339Nano))
+	out.Write(b)
+}
+
+// readRegExp writes a RegExp as its literal
+func (d *v8Deserializer) readRegExp() error {
+	pattern, err := d.nextString()
+	if err != nil {
+		return err
+	}
+	flags, err := d.readVarint()
+	if err != nil {
+		return err
+	}
+	literal := "/" + pattern + "/"
+	for i := range regExpFlags {
+		if flags&(1<<i) != 0 {
+			literal += regExpFlags[i : i+1]
+		}
+	}
+	d.write(literal)
+	return nil
+}
+
+// readArrayBuffer writes the bytes of a buffer as base64, the view that may
+// follow the buffer is written instead of it
+func (d *v8Deserializer) readArrayBuffer(tag byte) error {
+	n, err := d.readVarint()
+	if err != nil {
+		return err
+	}
+	if tag == tagResizableBuffer {
+		// the max byte length
+		if _, err := d.readVarint(); err != nil {
+			return err
+		}
+	}
+	b, err := d.readBytes(n)
+	if err != nil {
+		return err
+	}
+	view := -1
+	if len(d.b) > 0 && d.b[0] == tagArrayBufferView {
+		d.b = d.b[1:]
+		// the view is an object of its own, after the buffer
+		view = len(d.objects)
+		d.objects = append(d.objects, nil)
+		if _, err := d.readBytes(1); err != nil {
+			return err
+		}
+		offset, err := d.readVarint()
+		if err != nil {
+			return err
+		}
+		length, err := d.readVarint()
+		if err != nil {
+			return err
+		}
+		if d.version >= arrayBufferViewFlagsV8 {
+			if _, err := d.readVarint(); err != nil {
+				return err
+			}
+		}
+		if offset > uint64(len(b)) || length > uint64(len(b))-offset {
+			return errTruncated
+		}
+		b = b[offset : offset+length]
+	}
+	encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString(b))
+	d.out.Write(encoded)
+	if view >= 0 {
+		d.objects[view] = encoded
+	}
+	return nil
+}
+
+// readError writes an error as an object of its name, message and stack
+func (d *v8Deserializer) readError() error {
+	name := "Error"
+	d.out.WriteByte('{')
+	for {
+		tag, err := d.readTag()
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case errorEvalPrototype:
+			name = "EvalError"
+		case errorRangePrototype:
+			name = "RangeError"
+		case errorReferencePrototype:
+			name = "ReferenceError"
+		case errorSyntaxPrototype:
+			name = "SyntaxError"
+		case errorTypePrototype:
+			name = "TypeError"
+		case errorURIPrototype:
+			name = "URIError"
+		case errorMessage, errorStack:
+			key := `"message":`
+			if tag == errorStack {
+				key = `"stack":`
+			}
+			d.out.WriteString(key)
+			if err := d.readStringValue(); err != nil {
+				return err
+			}
+			d.out.WriteByte(',')
+		case errorCause:
+			d.out.WriteString(`"cause":`)
+			if err := d.readValue(); err != nil {
+				return err
+			}
+			d.out.WriteByte(',')
+		case errorEnd:
+			d.out.WriteString(`"name":`)
+			d.write(name)
+			d.out.WriteByte('}')
+			return nil
+		default:
+			return fmt.Errorf("unknown error tag 0x%02x: %w", tag, errNotSupported)
+		}
+	}
+}
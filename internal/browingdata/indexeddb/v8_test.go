@@ -0,0 +1,85 @@
+package indexeddb
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestV8ToJSON(t *testing.T) {
+	// values written by v8.serialize of node, blink envelopes are prefixed
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{
+			name:  "object",
+			value: "ff0f6f22057469746c65220568e96c6c6f22016e49092201664e000000000000f83f2204646f6e655422047461677341022202676f006304e5652c672400027b05",
+			want:  `{"title":"héllo","n":-5,"f":1.5,"done":true,"tags":["go","日本"]}`,
+		},
+		{name: "sparse array", value: "ff0f61034900490249044906400203", want: `{"0":1,"2":3}`},
+		{name: "date", value: "ff0f440000800caa567842", want: `"2023-01-01T00:00:00Z"`},
+		{name: "map", value: "ff0f3b22016b5a1001000000000000003a02", want: `[["k","1"]]`},
+		{name: "set", value: "ff0f27490249042c02", want: `[1,2]`},
+		{name: "cycle", value: "ff0f6f2201614902220473656c665e007b02", want: `{"a":1,"self":null}`},
+		{name: "reference", value: "ff0f6f220178410249022201612400022201795e017b02", want: `{"x":[1,"a"],"y":[1,"a"]}`},
+		{name: "regexp", value: "ff0f52220461622b6303", want: `"/ab+c/gi"`},
+		{name: "error", value: "ff0f72546d2203626164732201732e", want: `{"message":"bad","stack":"s","name":"TypeError"}`},
+		{name: "uint8array", value: "ff0f4204010203045642010200", want: `"AgM="`},
+		{name: "blink", value: "ff14ff0f4e0000000000007042", want: `1099511627776`},
+		{name: "blink trailer", value: "ff15fe000000000000000000000000ff0f2202676f", want: `"go"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := hex.DecodeString(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := v8ToJSON(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("v8ToJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestV8ToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		err   error
+	}{
+		{name: "host object", value: "ff0f5c4205", err: errHostObject},
+		{name: "truncated", value: "ff0f6f2205746974", err: errTruncated},
+		{name: "no version", value: "6f7b00", err: errNotSupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := hex.DecodeString(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := v8ToJSON(b); !errors.Is(err, tt.err) {
+				t.Errorf("v8ToJSON() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// FuzzV8ToJSON only requires the decoders not to panic, run with
+// go test -fuzz=FuzzV8ToJSON
+func FuzzV8ToJSON(f *testing.F) {
+	f.Add([]byte("\xff\x0f\x6f\x22\x01\x61\x49\x02\x22\x04self\x5e\x00\x7b\x02"))
+	f.Add([]byte("\xff\x0f\x42\x04\x01\x02\x03\x04\x56\x42\x01\x02\x00"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = v8ToJSON(b)
+		_, _ = keyToJSON(b)
+		_, _ = decodeValue(b)
+		_, _ = cloneToJSON(b)
+		_, _ = firefoxKeyToJSON(b)
+	})
+}
@@ -4,56 +4,70 @@ import (
 	"bytes"
 	"database/sql"
 	"fmt"
-	"os"
+	"path/filepath"
 	"strings"
 
-	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/decrypter"
 	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/fileutil"
+	"hack-browser-data/internal/utils/leveldbutil"
+	"hack-browser-data/internal/utils/sqliteutil"
 	"hack-browser-data/internal/utils/typeutil"
 
-	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/golang/snappy"
 )
 
-type ChromiumLocalStorage []storage
+type ChromiumLocalStorage []Storage
 
-type storage struct {
-	IsMeta bool
-	URL    string
-	Key    string
-	Value  string
+type Storage struct {
+	URL   string
+	Key   string
+	Value string
 }
 
-func (c *ChromiumLocalStorage) Parse(masterKey []byte) error {
-	db, err := leveldb.OpenFile(item.TempChromiumLocalStorage, nil)
-	if err != nil {
-		return err
-	}
-	defer os.RemoveAll(item.TempChromiumLocalStorage)
-	// log.Info("parsing local storage now")
-	defer db.Close()
+// prefixes of keys and values of local storage, they tell the encoding of
+// the string that follows
+const (
+	utf16Prefix  = 0x00
+	latin1Prefix = 0x01
+)
 
-	iter := db.NewIterator(nil, nil)
-	for iter.Next() {
-		key := iter.Key()
-		value := iter.Value()
-		// don't parse value upper than 5kB
-		if len(value) > 1024*5 {
+// Parse reads the leveldb of local storage at path, keys of items are
+// _origin\x00key, the others are metadata of origins and the schema.
+func (c *ChromiumLocalStorage) Parse(_ decrypter.Keys, path string) error {
+	records, err := leveldbutil.Read(path)
+	for _, r := range records {
+		if !bytes.HasPrefix(r.Key, []byte("_")) {
 			continue
 		}
-		s := new(storage)
-		s.fillKey(key)
-		s.fillValue(value)
-		// don't save meta data
-		if s.IsMeta {
+		url, key, ok := bytes.Cut(r.Key[1:], []byte("\x00"))
+		if !ok {
 			continue
 		}
-		*c = append(*c, *s)
+		*c = append(*c, Storage{
+			URL:   string(url),
+			Key:   decodeString(key),
+			Value: decodeString(r.Value),
+		})
 	}
-	iter.Release()
-	err = iter.Error()
 	return err
 }
 
+// decodeString returns the string of b, its first byte is the encoding of
+// the rest
+func decodeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case utf16Prefix:
+		return typeutil.UTF16LE(b[1:])
+	case latin1Prefix:
+		return typeutil.Latin1(b[1:])
+	}
+	return string(b)
+}
+
 func (c *ChromiumLocalStorage) Name() string {
 	return "localStorage"
 }
@@ -62,56 +76,177 @@ func (c *ChromiumLocalStorage) Length() int {
 	return len(*c)
 }
 
-func (s *storage) fillKey(b []byte) {
-	keys := bytes.Split(b, []byte("\x00"))
-	if len(keys) == 1 && bytes.HasPrefix(keys[0], []byte("META:")) {
-		s.IsMeta = true
-		s.fillMetaHeader(keys[0])
+type ChromiumSessionStorage []Storage
+
+// Parse reads the leveldb of session storage at path, namespace-id-origin
+// keys map the storage of an origin in a tab to a map id, items of the map
+// are map-id-key. Keys are utf-8 and values utf-16, tabs share a map until
+// one of them changes it.
+func (c *ChromiumSessionStorage) Parse(_ decrypter.Keys, path string) error {
+	records, err := leveldbutil.Read(path)
+	origins := make(map[string]string)
+	for _, r := range records {
+		if !bytes.HasPrefix(r.Key, []byte("namespace-")) {
+			continue
+		}
+		// ids of namespaces are guids without dashes
+		if _, origin, ok := bytes.Cut(r.Key[len("namespace-"):], []byte("-")); ok {
+			if _, exist := origins[string(r.Value)]; !exist {
+				// origins end with a slash, the ones of local storage don't
+				origins[string(r.Value)] = strings.TrimSuffix(string(origin), "/")
+			}
+		}
 	}
-	if len(keys) == 2 && bytes.HasPrefix(keys[0], []byte("_")) {
-		s.fillHeader(keys[0], keys[1])
+	for _, r := range records {
+		if !bytes.HasPrefix(r.Key, []byte("map-")) {
+			continue
+		}
+		id, key, ok := bytes.Cut(r.Key[len("map-"):], []byte("-"))
+		if !ok {
+			continue
+		}
+		*c = append(*c, Storage{
+			URL:   origins[string(id)],
+			Key:   string(key),
+			Value: typeutil.UTF16LE(r.Value),
+		})
 	}
+	return err
 }
 
-func (s *storage) fillMetaHeader(b []byte) {
-	s.URL = string(bytes.Trim(b, "META:"))
-}
-
-func (s *storage) fillHeader(url, key []byte) {
-	s.URL = string(bytes.Trim(url, "_"))
-	s.Key = string(bytes.Trim(key, "\x01"))
+func (c *ChromiumSessionStorage) Name() string {
+	return "sessionStorage"
 }
 
-// fillValue fills value of the storage
-// TODO: support unicode charter
-func (s *storage) fillValue(b []byte) {
-	t := fmt.Sprintf("%c", b)
-	m := strings.NewReplacer(" ", "", "\x00", "", "\x01", "").Replace(t)
-	s.Value = m
+func (c *ChromiumSessionStorage) Length() int {
+	return len(*c)
 }
 
-type FirefoxLocalStorage []storage
+type FirefoxLocalStorage []Storage
 
 const (
 	queryFirefoxHistory = `SELECT originKey, key, value FROM webappsstore2`
 	closeJournalMode    = `PRAGMA journal_mode=off`
+	queryFirefoxOrigin  = `SELECT origin FROM database`
 )
 
-func (f *FirefoxLocalStorage) Parse(masterKey []byte) error {
-	db, err := sql.Open("sqlite3", item.TempFirefoxLocalStorage)
+// firefoxOriginQueries select items of ls/data.sqlite of an origin, values
+// are compressed with snappy if compression_type or compressed is set
+var firefoxOriginQueries = []sqliteutil.Query{
+	{
+		Table:   "data",
+		Columns: []sqliteutil.Column{{Name: "key"}, {Name: "value"}, {Name: "compression_type"}},
+	},
+	{
+		Table:   "data",
+		Columns: []sqliteutil.Column{{Name: "key"}, {Name: "value"}, {Name: "compressed", Default: "0"}},
+	},
+}
+
+// firefoxWebappsStore is read besides the origins of storage/default, see
+// FirefoxLocalStorage.Parse
+const firefoxWebappsStore = "webappsstore.sqlite"
+
+// Parse reads the origins of storage/default at path, each keeps its items
+// in ls/data.sqlite, or webappsstore.sqlite of older profiles if path is a
+// file. Profiles of Firefox 68 to 91 have storage/default but keep local
+// storage in webappsstore.sqlite, it's read as well if it's in path. An
+// origin that can't be read is left out and reported in the error.
+func (f *FirefoxLocalStorage) Parse(_ decrypter.Keys, path string) error {
+	if !fileutil.FolderExists(path) {
+		return f.parseWebappsStore(path)
+	}
+	databases, err := filepath.Glob(filepath.Join(path, "*", "ls", "data.sqlite"))
 	if err != nil {
 		return err
 	}
+	var failed []string
+	if store := filepath.Join(path, firefoxWebappsStore); fileutil.FileExists(store) {
+		if err := f.parseWebappsStore(store); err != nil {
+			log.Warnf("read local storage of %s error: %s", firefoxWebappsStore, err)
+			failed = append(failed, firefoxWebappsStore)
+		}
+	}
+	for _, db := range databases {
+		dir := filepath.Base(filepath.Dir(filepath.Dir(db)))
+		items, err := readFirefoxOrigin(db, dir)
+		if err != nil {
+			log.Warnf("read local storage of %s error: %s", dir, err)
+			failed = append(failed, dir)
+			continue
+		}
+		*f = append(*f, items...)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("read local storage of %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func readFirefoxOrigin(path, dir string) ([]Storage, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var origin string
+	if err := db.QueryRow(queryFirefoxOrigin).Scan(&origin); err != nil {
+		origin = dir
+	}
+	origin = firefoxOrigin(origin)
+	schema, err := sqliteutil.ReadSchema(db)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := schema.Query(firefoxOriginQueries...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Storage
+	for rows.Next() {
+		var (
+			key        string
+			value      []byte
+			compressed int
+		)
+		if err := rows.Scan(&key, &value, &compressed); err != nil {
+			log.Warn(err)
+			continue
+		}
+		if compressed != 0 {
+			if value, err = snappy.Decode(nil, value); err != nil {
+				log.Warnf("decompress local storage %s of %s error: %s", key, origin, err)
+				continue
+			}
+		}
+		items = append(items, Storage{URL: origin, Key: key, Value: string(value)})
+	}
+	return items, rows.Err()
+}
+
+// firefoxOrigin returns the url of an origin of storage/default, like
+// https://go.dev^userContextId=1 or its directory https+++localhost+8080,
+// attributes after ^ are left out
+func firefoxOrigin(origin string) string {
+	origin, _, _ = strings.Cut(origin, "^")
+	if scheme, host, ok := strings.Cut(origin, "+++"); ok {
+		origin = scheme + "://" + strings.ReplaceAll(host, "+", ":")
+	}
+	return origin
+}
+
+// parseWebappsStore reads webappsstore2 of webappsstore.sqlite
+func (f *FirefoxLocalStorage) parseWebappsStore(path string) error {
+	db, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempFirefoxLocalStorage)
 	defer db.Close()
 	_, err = db.Exec(closeJournalMode)
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 	rows, err := db.Query(queryFirefoxHistory)
 	if err != nil {
 		return err
@@ -122,14 +257,14 @@ func (f *FirefoxLocalStorage) Parse(masterKey []byte) error {
 		if err = rows.Scan(&originKey, &key, &value); err != nil {
 			log.Warn(err)
 		}
-		s := new(storage)
+		s := new(Storage)
 		s.fillFirefox(originKey, key, value)
 		*f = append(*f, *s)
 	}
-	return nil
+	return rows.Err()
 }
 
-func (s *storage) fillFirefox(originKey, key, value string) {
+func (s *Storage) fillFirefox(originKey, key, value string) {
 	// originKey = moc.buhtig.:https:443
 	p := strings.Split(originKey, ":")
 	h := typeutil.Reverse([]byte(p[0]))
@@ -1,12 +1,22 @@
 package browingdata
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"hack-browser-data/internal/browingdata/cookie"
+	"hack-browser-data/internal/log"
 
 	"github.com/gocarina/gocsv"
 	"golang.org/x/text/encoding/unicode"
@@ -14,27 +24,102 @@ import (
 )
 
 type OutPutter struct {
-	json bool
-	csv  bool
+	json   bool
+	jsonl  bool
+	sqlite bool
+	csv    bool
+	// cookieFormats are written besides the cookie file of every browser
+	cookieFormats []cookie.Format
 }
 
 func NewOutPutter(flag string) *OutPutter {
 	o := &OutPutter{}
-	if flag == "json" {
+	switch flag {
+	case "json":
 		o.json = true
-	} else {
+	case "jsonl":
+		o.jsonl = true
+	case "sqlite":
+		o.sqlite = true
+	default:
 		o.csv = true
 	}
 	return o
 }
 
+// SetCookieFormats sets the formats cookies are exported to as well, like
+// netscape for curl and editor for cookie editor extensions.
+func (o *OutPutter) SetCookieFormats(formats []string) error {
+	o.cookieFormats = nil
+	for _, s := range formats {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		f, err := cookie.ParseFormat(s)
+		if err != nil {
+			return err
+		}
+		o.cookieFormats = append(o.cookieFormats, f)
+	}
+	return nil
+}
+
+// CookieFormats returns the formats set by SetCookieFormats
+func (o *OutPutter) CookieFormats() []cookie.Format {
+	return o.cookieFormats
+}
+
+// StreamWriter receives rows of all browsers while sources are parsed
+type StreamWriter interface {
+	// Sink returns the Sink of a browser profile
+	Sink(browser, profile string) Sink
+	// Close writes out buffered rows and closes the output
+	Close() error
+}
+
+// Stream reports whether rows are written by a StreamWriter while sources
+// are parsed, instead of by Write once they're all in memory.
+func (o *OutPutter) Stream() bool {
+	return o.jsonl || o.sqlite
+}
+
+// StreamFile is the file rows of all browsers are streamed to
+func (o *OutPutter) StreamFile() string {
+	return "results." + o.Ext()
+}
+
+// OpenStream creates StreamFile under dir, only formats of Stream have one
+func (o *OutPutter) OpenStream(dir string) (StreamWriter, error) {
+	switch {
+	case o.jsonl:
+		f, err := o.CreateFile(dir, o.StreamFile())
+		if err != nil {
+			return nil, err
+		}
+		r := NewRowWriter(f)
+		r.closer = f
+		return r, nil
+	case o.sqlite:
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+		return NewSQLiteWriter(filepath.Join(dir, o.StreamFile()))
+	default:
+		return nil, errors.New("format doesn't stream")
+	}
+}
+
 func (o *OutPutter) Write(data Source, writer io.Writer) error {
-	switch o.json {
-	case true:
+	switch {
+	case o.json:
 		encoder := json.NewEncoder(writer)
 		encoder.SetIndent("  ", "  ")
 		encoder.SetEscapeHTML(false)
 		return encoder.Encode(data)
+	case o.jsonl:
+		encoder := json.NewEncoder(writer)
+		encoder.SetEscapeHTML(false)
+		return eachRow(data, encoder.Encode)
 	default:
 		gocsv.SetCSVWriter(func(w io.Writer) *gocsv.SafeCSVWriter {
 			writer := csv.NewWriter(transform.NewWriter(w, unicode.UTF8BOM.NewEncoder()))
@@ -69,9 +154,126 @@ func (o *OutPutter) CreateFile(dir, filename string) (*os.File, error) {
 	return file, nil
 }
 
+// writeFile creates filename under dir and fills it with write
+func (o *OutPutter) writeFile(dir, filename string, write func(w io.Writer) error) error {
+	f, err := o.CreateFile(dir, filename)
+	if err != nil {
+		return fmt.Errorf("create file %s error %w", filename, err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("write to file %s error %w", filename, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close file %s error %w", filename, err)
+	}
+	log.Noticef("output to file %s success", strings.ReplaceAll(path.Join(dir, filename), "/", "\\"))
+	return nil
+}
+
 func (o *OutPutter) Ext() string {
-	if o.json {
+	switch {
+	case o.json:
 		return "json"
+	case o.jsonl:
+		return "jsonl"
+	case o.sqlite:
+		return "db"
+	default:
+		return "csv"
+	}
+}
+
+// RowWriter writes rows as JSON lines tagged with the browser, profile and
+// artifact they come from, it's safe for concurrent use by browsers.
+type RowWriter struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+	// closer is the file opened by OpenStream
+	closer io.Closer
+}
+
+func NewRowWriter(w io.Writer) *RowWriter {
+	return &RowWriter{w: bufio.NewWriter(w)}
+}
+
+// Sink returns the Sink of a browser profile
+func (r *RowWriter) Sink(browser, profile string) Sink {
+	return func(artifact string, row any) error {
+		return r.writeRow(browser, profile, artifact, row)
+	}
+}
+
+func (r *RowWriter) writeRow(browser, profile, artifact string, row any) error {
+	tags, err := json.Marshal(struct {
+		Browser  string `json:"browser"`
+		Profile  string `json:"profile"`
+		Artifact string `json:"artifact"`
+	}{browser, profile, artifact})
+	if err != nil {
+		return err
+	}
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(row); err != nil {
+		return err
+	}
+	// fields of the row follow the tags in the same object, so every
+	// line stays a flat record for jq and log pipelines
+	body := bytes.TrimSpace(encoded.Bytes())
+	var line bytes.Buffer
+	line.Write(tags[:len(tags)-1])
+	switch {
+	case len(body) > 2 && body[0] == '{':
+		line.WriteByte(',')
+		line.Write(body[1:])
+	case body[0] == '{':
+		line.WriteByte('}')
+	default:
+		// rows that aren't objects are kept as a value
+		line.WriteString(`,"value":`)
+		line.Write(body)
+		line.WriteByte('}')
+	}
+	line.WriteByte('\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(line.Bytes())
+	return err
+}
+
+// Flush writes buffered rows to the underlying writer
+func (r *RowWriter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.w.Flush()
+}
+
+// Close flushes rows and closes the file opened by OpenStream
+func (r *RowWriter) Close() error {
+	err := r.Flush()
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// eachRow calls fn with every row of a source, sources are slices
+func eachRow(data Source, fn func(row any) error) error {
+	if data == nil {
+		return nil
+	}
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if v.Kind() != reflect.Slice {
+		return fn(data)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := fn(v.Index(i).Interface()); err != nil {
+			return err
+		}
 	}
-	return "csv"
+	return nil
 }
@@ -1,6 +1,7 @@
 package browingdata
 
 import (
+	"bytes"
 	"os"
 	"testing"
 )
@@ -21,3 +22,25 @@ func TestNewOutPutter(t *testing.T) {
 		t.Error("Write() returned an error", err)
 	}
 }
+
+func TestRowWriter(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	rows := NewRowWriter(&buf)
+	sink := rows.Sink("chrome-default", "/profile/Default")
+	if err := sink("history", struct{ URL string }{"https://a.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink("extension", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rows.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"browser":"chrome-default","profile":"/profile/Default","artifact":"history","URL":"https://a.com"}
+{"browser":"chrome-default","profile":"/profile/Default","artifact":"extension"}
+`
+	if buf.String() != want {
+		t.Errorf("RowWriter wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -3,14 +3,19 @@ package password
 import (
 	"bytes"
 	"database/sql"
+	"encoding/asn1"
 	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
 	"os"
 	"sort"
 	"time"
 
 	"hack-browser-data/internal/decrypter"
-	"hack-browser-data/internal/item"
 	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/bdbutil"
+	"hack-browser-data/internal/utils/sqliteutil"
 	"hack-browser-data/internal/utils/typeutil"
 
 	// import sqlite3 driver
@@ -18,34 +23,46 @@ import (
 	"github.com/tidwall/gjson"
 )
 
-type ChromiumPassword []loginData
+type ChromiumPassword []LoginData
 
-type loginData struct {
+type LoginData struct {
 	UserName    string
 	encryptPass []byte
 	encryptUser []byte
 	Password    string
 	LoginURL    string
 	CreateDate  time.Time
+	// DecryptStatus tells why Password is empty if it could not be decrypted
+	DecryptStatus decrypter.Status
 }
 
-const (
-	queryChromiumLogin = `SELECT origin_url, username_value, password_value, date_created FROM logins`
-)
+// chromiumLoginQueries select logins, very old Login Data has no date_created
+var chromiumLoginQueries = []sqliteutil.Query{
+	{
+		Table: "logins",
+		Columns: []sqliteutil.Column{
+			{Name: "origin_url"}, {Name: "username_value"}, {Name: "password_value"}, {Name: "date_created", Default: "0"},
+		},
+	},
+}
 
-func (c *ChromiumPassword) Parse(masterKey []byte) error {
-	loginDB, err := sql.Open("sqlite3", item.TempChromiumPassword)
+func (c *ChromiumPassword) Parse(masterKey decrypter.Keys, path string) error {
+	loginDB, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempChromiumPassword)
 	defer loginDB.Close()
-	rows, err := loginDB.Query(queryChromiumLogin)
+	schema, err := sqliteutil.ReadSchema(loginDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumLoginQueries...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
+	var failed int
 	for rows.Next() {
 		var (
 			url, username string
@@ -55,10 +72,11 @@ func (c *ChromiumPassword) Parse(masterKey []byte) error {
 		if err := rows.Scan(&url, &username, &pwd, &create); err != nil {
 			log.Warn(err)
 		}
-		login := loginData{
-			UserName:    username,
-			encryptPass: pwd,
-			LoginURL:    url,
+		login := LoginData{
+			UserName:      username,
+			encryptPass:   pwd,
+			LoginURL:      url,
+			DecryptStatus: decrypter.StatusPlain,
 		}
 		if len(pwd) > 0 {
 			var err error
@@ -69,7 +87,9 @@ func (c *ChromiumPassword) Parse(masterKey []byte) error {
 			}
 			if err != nil {
 				log.Error(err)
+				failed++
 			}
+			login.DecryptStatus = decrypter.StatusOf(err)
 		}
 		if create > time.Now().Unix() {
 			login.CreateDate = typeutil.TimeEpoch(create)
@@ -83,6 +103,9 @@ func (c *ChromiumPassword) Parse(masterKey []byte) error {
 	sort.Slice(*c, func(i, j int) bool {
 		return (*c)[i].CreateDate.After((*c)[j].CreateDate)
 	})
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d passwords", decrypter.ErrDecryptFailed, failed, len(*c))
+	}
 	return nil
 }
 
@@ -94,25 +117,35 @@ func (c *ChromiumPassword) Length() int {
 	return len(*c)
 }
 
-type YandexPassword []loginData
+type YandexPassword []LoginData
 
-const (
-	queryYandexLogin = `SELECT action_url, username_value, password_value, date_created FROM logins`
-)
+// yandexLoginQueries select logins of Ya Passman Data, the login url is action_url
+var yandexLoginQueries = []sqliteutil.Query{
+	{
+		Table: "logins",
+		Columns: []sqliteutil.Column{
+			{Name: "action_url"}, {Name: "username_value"}, {Name: "password_value"}, {Name: "date_created", Default: "0"},
+		},
+	},
+}
 
-func (c *YandexPassword) Parse(masterKey []byte) error {
-	loginDB, err := sql.Open("sqlite3", item.TempYandexPassword)
+func (c *YandexPassword) Parse(masterKey decrypter.Keys, path string) error {
+	loginDB, err := sql.Open("sqlite3", path)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(item.TempYandexPassword)
 	defer loginDB.Close()
-	rows, err := loginDB.Query(queryYandexLogin)
+	schema, err := sqliteutil.ReadSchema(loginDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(yandexLoginQueries...)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
+	var failed int
 	for rows.Next() {
 		var (
 			url, username string
@@ -122,10 +155,11 @@ func (c *YandexPassword) Parse(masterKey []byte) error {
 		if err := rows.Scan(&url, &username, &pwd, &create); err != nil {
 			log.Warn(err)
 		}
-		login := loginData{
-			UserName:    username,
-			encryptPass: pwd,
-			LoginURL:    url,
+		login := LoginData{
+			UserName:      username,
+			encryptPass:   pwd,
+			LoginURL:      url,
+			DecryptStatus: decrypter.StatusPlain,
 		}
 
 		if len(pwd) > 0 {
@@ -137,7 +171,9 @@ func (c *YandexPassword) Parse(masterKey []byte) error {
 			}
 			if err != nil {
 				log.Errorf("decrypt yandex password error %s", err)
+				failed++
 			}
+			login.DecryptStatus = decrypter.StatusOf(err)
 		}
 		if create > time.Now().Unix() {
 			login.CreateDate = typeutil.TimeEpoch(create)
@@ -151,6 +187,9 @@ func (c *YandexPassword) Parse(masterKey []byte) error {
 	sort.Slice(*c, func(i, j int) bool {
 		return (*c)[i].CreateDate.After((*c)[j].CreateDate)
 	})
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d passwords", decrypter.ErrDecryptFailed, failed, len(*c))
+	}
 	return nil
 }
 
@@ -162,74 +201,202 @@ func (c *YandexPassword) Length() int {
 	return len(*c)
 }
 
-type FirefoxPassword []loginData
+type FirefoxPassword []LoginData
 
 const (
 	queryMetaData   = `SELECT item1, item2 FROM metaData WHERE id = 'password'`
 	queryNssPrivate = `SELECT a11, a102 from nssPrivate`
 )
 
-func (f *FirefoxPassword) Parse(masterKey []byte) error {
-	globalSalt, metaBytes, nssA11, nssA102, err := getFirefoxDecryptKey(item.TempFirefoxKey4)
+var (
+	errPrimaryPassword = fmt.Errorf("%w: firefox primary password required or incorrect", decrypter.ErrWrongKey)
+	errNoPrivateKey    = errors.New("firefox key database has no private key for logins")
+	errKey3Entry       = fmt.Errorf("%w: firefox key3.db entry", decrypter.ErrCorruptBlob)
+)
+
+// firefoxKeyID is the CKA_ID of the private key of logins, a102 of
+// nssPrivate in key4.db and the key of its entry in key3.db
+var firefoxKeyID = []byte{248, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+
+// entries of key3.db besides the private key
+const (
+	key3GlobalSalt    = "global-salt"
+	key3PasswordCheck = "password-check"
+)
+
+// Parse decrypts logins.json or signons.sqlite, masterKey is the key of
+// logins derived from key4.db by FirefoxMasterKey or from key3.db by
+// FirefoxKey3MasterKey.
+func (f *FirefoxPassword) Parse(masterKey decrypter.Keys, path string) error {
+	key := masterKey.Key(decrypter.AnyPrefix)
+	if len(key) == 0 {
+		return decrypter.ErrNoLoginKey
+	}
+	allLogin, err := getFirefoxLoginData(path)
 	if err != nil {
 		return err
 	}
+	var failed int
+	for _, v := range allLogin {
+		user, pwd, err := decryptLogin(key, v)
+		if err != nil {
+			log.Errorf("decrypt firefox login of %s error: %s", v.LoginURL, err)
+			failed++
+		}
+		*f = append(*f, LoginData{
+			LoginURL:      v.LoginURL,
+			UserName:      string(user),
+			Password:      string(pwd),
+			CreateDate:    v.CreateDate,
+			DecryptStatus: decrypter.StatusOf(err),
+		})
+	}
+	sort.Slice(*f, func(i, j int) bool {
+		return (*f)[i].CreateDate.After((*f)[j].CreateDate)
+	})
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d passwords", decrypter.ErrDecryptFailed, failed, len(*f))
+	}
+	return nil
+}
+
+// decryptLogin returns the user and password of a login, neither is
+// returned unless both are decrypted
+func decryptLogin(masterKey []byte, l LoginData) (user, pwd []byte, err error) {
+	userPBE, err := decrypter.NewASN1PBE(l.encryptUser)
+	if err != nil {
+		return nil, nil, err
+	}
+	pwdPBE, err := decrypter.NewASN1PBE(l.encryptPass)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user, err = userPBE.Decrypt(masterKey, nil); err != nil {
+		return nil, nil, err
+	}
+	if pwd, err = pwdPBE.Decrypt(masterKey, nil); err != nil {
+		return nil, nil, err
+	}
+	return user, pwd, nil
+}
+
+// FirefoxMasterKey returns the key of logins stored in key4.db, primaryPassword
+// is empty unless the user set one.
+func FirefoxMasterKey(key4file string, primaryPassword []byte) ([]byte, error) {
+	globalSalt, metaBytes, nssA11, nssA102, err := getFirefoxDecryptKey(key4file)
+	if err != nil {
+		return nil, err
+	}
 	metaPBE, err := decrypter.NewASN1PBE(metaBytes)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	k, err := metaPBE.Decrypt(globalSalt, primaryPassword)
+	if errors.Is(err, decrypter.ErrWrongKey) {
+		return nil, errPrimaryPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+	// password-check is encrypted with the primary password, an empty
+	// password is used if the user never set one
+	if !bytes.Contains(k, []byte("password-check")) {
+		return nil, errPrimaryPassword
+	}
+	if !bytes.Equal(nssA102, firefoxKeyID) {
+		return nil, errNoPrivateKey
+	}
+	nssPBE, err := decrypter.NewASN1PBE(nssA11)
+	if err != nil {
+		return nil, err
+	}
+	finallyKey, err := nssPBE.Decrypt(globalSalt, primaryPassword)
+	if errors.Is(err, decrypter.ErrWrongKey) {
+		return nil, errPrimaryPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the key is 24 bytes for 3DES logins and 32 for AES-256 ones
+	if len(finallyKey) < 24 {
+		return nil, errPrimaryPassword
 	}
+	return finallyKey, nil
+}
 
-	k, err := metaPBE.Decrypt(globalSalt, masterKey)
+// FirefoxKey3MasterKey returns the key of logins stored in key3.db of
+// Firefox 57 and earlier, primaryPassword is empty unless the user set one.
+func FirefoxKey3MasterKey(key3file string, primaryPassword []byte) ([]byte, error) {
+	entries, err := bdbutil.ReadHash(key3file)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	keyLin := []byte{248, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
-	if bytes.Contains(k, []byte("password-check")) {
-		m := bytes.Compare(nssA102, keyLin)
-		if m == 0 {
-			nssPBE, err := decrypter.NewASN1PBE(nssA11)
-			if err != nil {
-				return err
-			}
-			finallyKey, err := nssPBE.Decrypt(globalSalt, masterKey)
-			finallyKey = finallyKey[:24]
-			if err != nil {
-				return err
-			}
-			allLogin, err := getFirefoxLoginData()
-			if err != nil {
-				return err
-			}
-			for _, v := range allLogin {
-				userPBE, err := decrypter.NewASN1PBE(v.encryptUser)
-				if err != nil {
-					return err
-				}
-				pwdPBE, err := decrypter.NewASN1PBE(v.encryptPass)
-				if err != nil {
-					return err
-				}
-				user, err := userPBE.Decrypt(finallyKey, masterKey)
-				if err != nil {
-					return err
-				}
-				pwd, err := pwdPBE.Decrypt(finallyKey, masterKey)
-				if err != nil {
-					return err
-				}
-				*f = append(*f, loginData{
-					LoginURL:   v.LoginURL,
-					UserName:   string(user),
-					Password:   string(pwd),
-					CreateDate: v.CreateDate,
-				})
-			}
+	globalSalt := entries[key3GlobalSalt]
+	// password-check is a version, the length of the salt and of the OID,
+	// the salt, the OID and the encrypted check of 16 bytes
+	check := entries[key3PasswordCheck]
+	if len(check) < 3 || len(check) < 3+int(check[1])+16 {
+		return nil, fmt.Errorf("%w %s", errKey3Entry, key3PasswordCheck)
+	}
+	checkPBE := decrypter.NewPKCS12PBE(check[3:3+int(check[1])], check[len(check)-16:])
+	k, err := checkPBE.Decrypt(globalSalt, primaryPassword)
+	if errors.Is(err, decrypter.ErrWrongKey) || (err == nil && string(k) != key3PasswordCheck) {
+		return nil, errPrimaryPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the private key entry is a version, the length of the salt and of
+	// the nickname, the salt, the nickname and the encrypted PKCS#8 key
+	entry, ok := entries[string(firefoxKeyID)]
+	if !ok {
+		return nil, errNoPrivateKey
+	}
+	if len(entry) < 3 || len(entry) < 3+int(entry[1])+int(entry[2]) {
+		return nil, fmt.Errorf("%w of the private key", errKey3Entry)
+	}
+	keyPBE, err := decrypter.NewASN1PBE(entry[3+int(entry[1])+int(entry[2]):])
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := keyPBE.Decrypt(globalSalt, primaryPassword)
+	if errors.Is(err, decrypter.ErrWrongKey) {
+		return nil, errPrimaryPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key3LoginKey(privateKey)
+}
+
+// key3LoginKey returns the 3DES key of logins, NSS keeps it as the private
+// exponent of an RSA key in key3.db
+func key3LoginKey(privateKeyInfo []byte) ([]byte, error) {
+	var (
+		info struct {
+			Version    int
+			Algorithm  asn1.RawValue
+			PrivateKey []byte
+		}
+		key struct {
+			Version         int
+			Modulus         *big.Int
+			PublicExponent  *big.Int
+			PrivateExponent *big.Int
 		}
+	)
+	if _, err := asn1.Unmarshal(privateKeyInfo, &info); err != nil {
+		return nil, fmt.Errorf("%w of the private key: %s", errKey3Entry, err)
 	}
-	sort.Slice(*f, func(i, j int) bool {
-		return (*f)[i].CreateDate.After((*f)[j].CreateDate)
-	})
-	return nil
+	if _, err := asn1.Unmarshal(info.PrivateKey, &key); err != nil {
+		return nil, fmt.Errorf("%w of the private key: %s", errKey3Entry, err)
+	}
+	k := key.PrivateExponent.Bytes()
+	if len(k) > 24 {
+		return nil, fmt.Errorf("%w: private key of %d bytes", errKey3Entry, len(k))
+	}
+	// leading zero bytes of the key are lost in the integer
+	return append(make([]byte, 24-len(k)), k...), nil
 }
 
 func getFirefoxDecryptKey(key4file string) (item1, item2, a11, a102 []byte, err error) {
@@ -238,18 +405,7 @@ func getFirefoxDecryptKey(key4file string) (item1, item2, a11, a102 []byte, err
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
-	defer func(name string) {
-		err := os.Remove(name)
-		if err != nil {
-			log.Errorf("remove %s error", name)
-		}
-	}(key4file)
-	defer func(keyDB *sql.DB) {
-		err := keyDB.Close()
-		if err != nil {
-
-		}
-	}(keyDB)
+	defer keyDB.Close()
 
 	if err = keyDB.QueryRow(queryMetaData).Scan(&item1, &item2); err != nil {
 		return nil, nil, nil, nil, err
@@ -261,22 +417,36 @@ func getFirefoxDecryptKey(key4file string) (item1, item2, a11, a102 []byte, err
 	return item1, item2, a11, a102, nil
 }
 
-func getFirefoxLoginData() (l []loginData, err error) {
-	s, err := os.ReadFile(item.TempFirefoxPassword)
+// sqliteHeader starts signons.sqlite, logins.json is json
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// signonsLoginQueries select logins of signons.sqlite, used before Firefox 32.
+// formSubmitURL is NULL for logins of HTTP authentication.
+var signonsLoginQueries = []sqliteutil.Query{
+	{
+		Table: "moz_logins",
+		Columns: []sqliteutil.Column{
+			{Name: "formSubmitURL", Default: "hostname"}, {Name: "encryptedUsername"}, {Name: "encryptedPassword"},
+			{Name: "timeCreated", Default: "0"},
+		},
+	},
+}
+
+// getFirefoxLoginData returns the encrypted logins of logins.json, or of
+// signons.sqlite in profiles of Firefox before 32.
+func getFirefoxLoginData(path string) (l []LoginData, err error) {
+	s, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer func(name string) {
-		err := os.Remove(name)
-		if err != nil {
-
-		}
-	}(item.TempFirefoxPassword)
+	if bytes.HasPrefix(s, sqliteHeader) {
+		return getSignonsLoginData(path)
+	}
 	h := gjson.GetBytes(s, "logins")
 	if h.Exists() {
 		for _, v := range h.Array() {
 			var (
-				m    loginData
+				m    LoginData
 				user []byte
 				pass []byte
 			)
@@ -298,6 +468,43 @@ func getFirefoxLoginData() (l []loginData, err error) {
 	return l, nil
 }
 
+func getSignonsLoginData(path string) ([]LoginData, error) {
+	signonsDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	defer signonsDB.Close()
+	schema, err := sqliteutil.ReadSchema(signonsDB)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := schema.Query(signonsLoginQueries...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var l []LoginData
+	for rows.Next() {
+		var (
+			m          LoginData
+			user, pass string
+			create     int64
+		)
+		if err := rows.Scan(&m.LoginURL, &user, &pass, &create); err != nil {
+			return nil, err
+		}
+		if m.encryptUser, err = base64.StdEncoding.DecodeString(user); err != nil {
+			return nil, err
+		}
+		if m.encryptPass, err = base64.StdEncoding.DecodeString(pass); err != nil {
+			return nil, err
+		}
+		m.CreateDate = typeutil.TimeStamp(create / 1000)
+		l = append(l, m)
+	}
+	return l, rows.Err()
+}
+
 func (f *FirefoxPassword) Name() string {
 	return "password"
 }
@@ -305,3 +512,55 @@ func (f *FirefoxPassword) Name() string {
 func (f *FirefoxPassword) Length() int {
 	return len(*f)
 }
+
+// FirefoxPosture audits how key4.db protects the saved logins, no login is decrypted.
+type FirefoxPosture []Posture
+
+type Posture struct {
+	PrimaryPassword bool
+	Algorithm       string
+	OID             string
+	Iterations      int
+	KeySize         int
+	LegacyNSSPBE    bool
+}
+
+func (f *FirefoxPosture) Parse(masterKey decrypter.Keys, path string) error {
+	globalSalt, metaBytes, nssA11, _, err := getFirefoxDecryptKey(path)
+	if err != nil {
+		return err
+	}
+	metaPBE, err := decrypter.NewASN1PBE(metaBytes)
+	if err != nil {
+		return err
+	}
+	// password-check only decrypts with an empty password if no primary password is set
+	k, checkErr := metaPBE.Decrypt(globalSalt, nil)
+	if checkErr != nil && !errors.Is(checkErr, decrypter.ErrWrongKey) {
+		return checkErr
+	}
+	// the private key protecting logins is described, it's the weakest link
+	nssPBE, err := decrypter.NewASN1PBE(nssA11)
+	if err != nil {
+		return err
+	}
+	params := nssPBE.Params()
+	p := Posture{
+		PrimaryPassword: checkErr != nil || !bytes.Contains(k, []byte("password-check")),
+		Algorithm:       params.Algorithm,
+		OID:             params.OID,
+		Iterations:      params.Iterations,
+		KeySize:         params.KeySize,
+		LegacyNSSPBE:    params.Legacy || metaPBE.Params().Legacy,
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+func (f *FirefoxPosture) Name() string {
+	return "posture"
+}
+
+func (f *FirefoxPosture) Length() int {
+	return len(*f)
+}
@@ -0,0 +1,108 @@
+package password
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/testfixture"
+)
+
+func TestFirefoxPosture(t *testing.T) {
+	tests := []struct {
+		name            string
+		primaryPassword string
+	}{
+		{name: "no primary password"},
+		{name: "primary password", primaryPassword: "hunter2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := testfixture.Firefox(dir, []byte(tt.primaryPassword)); err != nil {
+				t.Fatal(err)
+			}
+			var posture FirefoxPosture
+			if err := posture.Parse(nil, filepath.Join(dir, "key4.db")); err != nil {
+				t.Fatal(err)
+			}
+			if len(posture) != 1 {
+				t.Fatalf("Parse() = %d postures, want 1", len(posture))
+			}
+			p := posture[0]
+			if want := tt.primaryPassword != ""; p.PrimaryPassword != want {
+				t.Errorf("PrimaryPassword = %v, want %v", p.PrimaryPassword, want)
+			}
+			if p.Algorithm == "" || p.Iterations == 0 || p.LegacyNSSPBE {
+				t.Errorf("Parse() = %+v, want the PBES2 parameters of the login key", p)
+			}
+		})
+	}
+}
+
+func TestChromiumPasswordSchemas(t *testing.T) {
+	t.Parallel()
+	blob, err := testfixture.EncryptChromium(testfixture.ChromiumWindowsKey, []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		create string
+		insert string
+		want   time.Time
+	}{
+		{
+			name:   "without date_created",
+			create: `CREATE TABLE logins (origin_url VARCHAR NOT NULL, action_url VARCHAR, username_value VARCHAR, password_value BLOB)`,
+			insert: `INSERT INTO logins VALUES ('https://a.com/login', '', 'alice', ?)`,
+			want:   time.Unix(0, 0),
+		},
+		{
+			name: "date_created",
+			create: `CREATE TABLE logins (origin_url VARCHAR NOT NULL, action_url VARCHAR, username_value VARCHAR, password_value BLOB,
+				date_created INTEGER, blacklisted_by_user INTEGER, scheme INTEGER)`,
+			insert: `INSERT INTO logins VALUES ('https://a.com/login', '', 'alice', ?, 13318033445000000, 0, 0)`,
+			want:   time.Date(2023, 1, 12, 21, 44, 5, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "Login Data")
+			if err := writeDB(path, tt.create, tt.insert, blob); err != nil {
+				t.Fatal(err)
+			}
+			var c ChromiumPassword
+			if err := c.Parse(decrypter.NewKeys(testfixture.ChromiumWindowsKey), path); err != nil {
+				t.Fatal(err)
+			}
+			if len(c) != 1 {
+				t.Fatalf("parsed %d logins, want 1", len(c))
+			}
+			got := c[0]
+			if got.LoginURL != "https://a.com/login" || got.UserName != "alice" || got.Password != "hunter2" ||
+				got.DecryptStatus != decrypter.StatusOK || !got.CreateDate.Equal(tt.want) {
+				t.Errorf("login = %+v, want alice:hunter2 of https://a.com/login created %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeDB creates a database at path with the table of create and a row
+// inserted with args
+func writeDB(path, create, insert string, args ...any) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.Exec(create); err != nil {
+		return err
+	}
+	_, err = db.Exec(insert, args...)
+	return err
+}
@@ -0,0 +1,111 @@
+package browingdata
+
+import (
+	"errors"
+	"sort"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/item"
+)
+
+// Status is the outcome of an item of a browser profile
+type Status string
+
+const (
+	// StatusOK means the item was parsed, it may have no rows
+	StatusOK Status = "ok"
+	// StatusSkipped means the item is not supported
+	StatusSkipped Status = "skipped"
+	// StatusMissing means the file of the item is not in the profile
+	StatusMissing Status = "missing"
+	// StatusDecryptFailed means the item was parsed but some rows could not be decrypted
+	StatusDecryptFailed Status = "decrypt-failed"
+	// StatusParseFailed means the item could not be read
+	StatusParseFailed Status = "parse-failed"
+)
+
+// ItemReport is the outcome of an item, filled in by Recovery
+type ItemReport struct {
+	Item   string `json:"item"`
+	Status Status `json:"status"`
+	Rows   int    `json:"rows"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Failed reports whether the item was found but not fully recovered
+func (r ItemReport) Failed() bool {
+	return r.Status == StatusDecryptFailed || r.Status == StatusParseFailed
+}
+
+// Report returns the outcome of every requested item ordered by item
+func (d *Data) Report() []ItemReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	items := make([]item.Item, 0, len(d.reports))
+	for i := range d.reports {
+		items = append(items, i)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i] < items[j]
+	})
+	reports := make([]ItemReport, 0, len(items))
+	for _, i := range items {
+		reports = append(reports, d.reports[i])
+	}
+	return reports
+}
+
+// SetStatus overrides the outcome of an item, it's used by browsers for
+// items they read themselves, like the key file.
+func (d *Data) SetStatus(i item.Item, status Status, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := d.reports[i]
+	r.Item = d.itemName(i)
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+	}
+	d.reports[i] = r
+}
+
+// SetKeyProvider records the provider the master key came from
+func (d *Data) SetKeyProvider(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keyProvider = name
+}
+
+// KeyProvider returns the provider the master key came from, empty if
+// there's no key
+func (d *Data) KeyProvider() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.keyProvider
+}
+
+func (d *Data) setReport(i item.Item, rows int, err error) {
+	status := StatusOK
+	switch {
+	case errors.Is(err, decrypter.ErrDecryptFailed):
+		status = StatusDecryptFailed
+	case err != nil:
+		status = StatusParseFailed
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	r := ItemReport{Item: d.itemName(i), Status: status, Rows: rows}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	d.reports[i] = r
+}
+
+// itemName names an item by its source, items without source like the
+// key file are named by the item
+func (d *Data) itemName(i item.Item) string {
+	if source, ok := d.sources[i]; ok {
+		return source.Name()
+	}
+	return i.String()
+}
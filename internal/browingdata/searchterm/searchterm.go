@@ -0,0 +1,194 @@
+package searchterm
+
+import (
+	"database/sql"
+	"net/url"
+	"strings"
+	"time"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/sqliteutil"
+	"hack-browser-data/internal/utils/typeutil"
+
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/net/publicsuffix"
+)
+
+type ChromiumSearchTerm []SearchTerm
+
+// SearchTerm is a search of a search engine, URL is its result page
+type SearchTerm struct {
+	Term          string
+	URL           string
+	VisitCount    int
+	LastVisitTime time.Time
+}
+
+// chromiumSearchTermQueries select terms chromium keeps for searches typed
+// into the omnibox, with the visits of their result page
+var chromiumSearchTermQueries = []sqliteutil.Query{
+	{
+		Table: "keyword_search_terms",
+		Alias: "k",
+		Joins: map[string]string{"u": "urls"},
+		Columns: []sqliteutil.Column{
+			{Name: "term"}, {Table: "u", Name: "url", Default: "''"},
+			{Table: "u", Name: "visit_count", Default: "0"}, {Table: "u", Name: "last_visit_time", Default: "0"},
+		},
+		Suffix: `LEFT JOIN urls u ON u.id = k.url_id ORDER BY u.last_visit_time DESC`,
+	},
+}
+
+func (c *ChromiumSearchTerm) Parse(_ decrypter.Keys, path string) error {
+	historyDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer historyDB.Close()
+	schema, err := sqliteutil.ReadSchema(historyDB)
+	if err != nil {
+		return err
+	}
+	rows, err := schema.Query(chromiumSearchTermQueries...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			term, u    string
+			visitCount int
+			lastVisit  int64
+		)
+		if err := rows.Scan(&term, &u, &visitCount, &lastVisit); err != nil {
+			log.Warn(err)
+		}
+		*c = append(*c, SearchTerm{
+			Term:          term,
+			URL:           u,
+			VisitCount:    visitCount,
+			LastVisitTime: typeutil.TimeEpoch(lastVisit),
+		})
+	}
+	return rows.Err()
+}
+
+func (c *ChromiumSearchTerm) Name() string {
+	return "searchTerm"
+}
+
+func (c *ChromiumSearchTerm) Length() int {
+	return len(*c)
+}
+
+type FirefoxSearchTerm []SearchTerm
+
+// searchEngines maps hosts of search engines, without www., to the query
+// parameter of their result pages
+var searchEngines = map[string]string{
+	"bing.com":         "q",
+	"duckduckgo.com":   "q",
+	"search.yahoo.com": "p",
+	"baidu.com":        "wd",
+	"ecosia.org":       "q",
+	"startpage.com":    "query",
+	"qwant.com":        "q",
+	"search.brave.com": "q",
+	"sogou.com":        "query",
+	"so.com":           "q",
+	"search.naver.com": "query",
+}
+
+// searchEngineNames are search engines with a domain per country, like
+// google.de or yandex.ru, they are matched by the name of the registrable
+// domain
+var searchEngineNames = map[string]string{
+	"google": "q",
+	"yandex": "text",
+}
+
+// queryFirefoxSearchTerm selects places with a query, search terms are
+// taken from the ones of search engines
+const queryFirefoxSearchTerm = `SELECT url, IFNULL(visit_count, 0), IFNULL(last_visit_date, 0)
+	FROM moz_places WHERE url LIKE 'http%?%' ORDER BY last_visit_date DESC`
+
+// Parse reads searches of moz_places, Firefox keeps no search terms, they
+// are the query of result pages of known search engines.
+func (f *FirefoxSearchTerm) Parse(_ decrypter.Keys, path string) error {
+	placesDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer placesDB.Close()
+	rows, err := placesDB.Query(queryFirefoxSearchTerm)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			u          string
+			visitCount int
+			lastVisit  int64
+		)
+		if err := rows.Scan(&u, &visitCount, &lastVisit); err != nil {
+			log.Warn(err)
+		}
+		term := searchTerm(u)
+		if term == "" {
+			continue
+		}
+		*f = append(*f, SearchTerm{
+			Term:          term,
+			URL:           u,
+			VisitCount:    visitCount,
+			LastVisitTime: typeutil.TimeStamp(lastVisit / 1000000),
+		})
+	}
+	return rows.Err()
+}
+
+// searchTerm returns the term searched by the result page rawURL, it's
+// empty if rawURL is no result page of a search engine
+func searchTerm(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	param, ok := searchEngines[host]
+	if !ok {
+		if param, ok = searchEngineNames[domainName(host)]; !ok {
+			return ""
+		}
+	}
+	return strings.TrimSpace(u.Query().Get(param))
+}
+
+// domainName returns the name of host if it's a registrable domain, like
+// google of google.co.uk. It's empty for subdomains like mail.google.com
+// and for hosts of private suffixes like google.blogspot.com.
+func domainName(host string) string {
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	if !icann {
+		return ""
+	}
+	if !strings.HasSuffix(host, "."+suffix) {
+		return ""
+	}
+	name := strings.TrimSuffix(host, "."+suffix)
+	if strings.Contains(name, ".") {
+		return ""
+	}
+	return name
+}
+
+func (f *FirefoxSearchTerm) Name() string {
+	return "searchTerm"
+}
+
+func (f *FirefoxSearchTerm) Length() int {
+	return len(*f)
+}
@@ -0,0 +1,58 @@
+package searchterm
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"hack-browser-data/internal/testfixture"
+)
+
+func TestSearchTerm(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://www.google.com/search?q=hack+browser+data&client=firefox-b-d", want: "hack browser data"},
+		{url: "https://www.google.co.uk/search?q=sqlite", want: "sqlite"},
+		{url: "https://yandex.ru/search/?text=%D0%BF%D0%BE%D0%B8%D1%81%D0%BA", want: "поиск"},
+		{url: "https://www.baidu.com/s?wd=golang", want: "golang"},
+		{url: "https://search.yahoo.com/search?p=firefox", want: "firefox"},
+		{url: "https://mail.google.com/mail/?q=inbox", want: ""},
+		{url: "https://google.example.com/search?q=lookalike", want: ""},
+		{url: "https://google.blogspot.com/?q=private+suffix", want: ""},
+		{url: "https://google.invalid/?q=unknown+suffix", want: ""},
+		{url: "https://example.com/?q=not+a+search+engine", want: ""},
+		{url: "https://www.bing.com/", want: ""},
+	}
+	for _, tt := range tests {
+		if got := searchTerm(tt.url); got != tt.want {
+			t.Errorf("searchTerm(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestFirefoxSearchTermParse(t *testing.T) {
+	dir := t.TempDir()
+	if err := testfixture.Firefox(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	var f FirefoxSearchTerm
+	if err := f.Parse(nil, filepath.Join(dir, "places.sqlite")); err != nil {
+		t.Fatal(err)
+	}
+	want := FirefoxSearchTerm{
+		{
+			Term: "sqlite wal", URL: "https://www.google.co.uk/search?q=sqlite+wal",
+			VisitCount: 1, LastVisitTime: time.Unix(1672628695, 0),
+		},
+		{
+			Term: "firefox profile", URL: "https://duckduckgo.com/?q=firefox+profile&t=ffab",
+			VisitCount: 1, LastVisitTime: time.Unix(1672628675, 0),
+		},
+	}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("search terms = %+v, want %+v", f, want)
+	}
+}
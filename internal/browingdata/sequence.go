@@ -0,0 +1,147 @@
+package browingdata
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// maxPendingRows is how many rows a writer keeps in memory while writers
+// before it are not done, later rows are spilled to a temp file
+const maxPendingRows = 10000
+
+// Sequence hands rows of several writers to their sinks in the order of the
+// writers, so streamed rows don't depend on which writer runs first. Rows of
+// the first writer not done go to its sink at once, rows of the others are
+// kept until all writers before them are done, up to maxPendingRows in
+// memory and the rest in a temp file.
+type Sequence struct {
+	mu    sync.Mutex
+	head  int
+	limit int
+	slots []*slot
+}
+
+type slot struct {
+	sink  Sink
+	rows  []pendingRow
+	spill *spillFile
+	done  bool
+}
+
+// pendingRow is exported field by field, so it's encoded by gob
+type pendingRow struct {
+	Artifact string
+	Row      any
+}
+
+// spillFile keeps the pending rows of a slot past the limit, gob encoded
+type spillFile struct {
+	f   *os.File
+	w   *bufio.Writer
+	enc *gob.Encoder
+}
+
+// NewSequence returns a Sequence of a writer per sink, in the order of sinks
+func NewSequence(sinks []Sink) *Sequence {
+	s := &Sequence{slots: make([]*slot, len(sinks)), limit: maxPendingRows}
+	for i, sink := range sinks {
+		s.slots[i] = &slot{sink: sink}
+	}
+	return s
+}
+
+// Sink returns the Sink of writer i
+func (s *Sequence) Sink(i int) Sink {
+	return func(artifact string, row any) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		slot := s.slots[i]
+		if i == s.head {
+			return slot.sink(artifact, row)
+		}
+		if slot.spill == nil && len(slot.rows) < s.limit {
+			slot.rows = append(slot.rows, pendingRow{Artifact: artifact, Row: row})
+			return nil
+		}
+		return slot.spillRow(pendingRow{Artifact: artifact, Row: row})
+	}
+}
+
+// Done marks writer i as done, rows kept by the writers after it are handed
+// to their sinks up to the next writer not done. The error is the first one
+// of the sinks, rows after it are still handed over.
+func (s *Sequence) Done(i int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots[i].done = true
+	var err error
+	for ; s.head < len(s.slots); s.head++ {
+		head := s.slots[s.head]
+		for _, r := range head.rows {
+			if sinkErr := head.sink(r.Artifact, r.Row); sinkErr != nil && err == nil {
+				err = sinkErr
+			}
+		}
+		head.rows = nil
+		if spillErr := head.flushSpill(); spillErr != nil && err == nil {
+			err = spillErr
+		}
+		if !head.done {
+			break
+		}
+	}
+	return err
+}
+
+// spillRow appends r to the temp file of the slot, it's created on the
+// first row past the limit
+func (sl *slot) spillRow(r pendingRow) error {
+	if sl.spill == nil {
+		f, err := os.CreateTemp("", "hbd-rows-*")
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		sl.spill = &spillFile{f: f, w: w, enc: gob.NewEncoder(w)}
+	}
+	// the concrete type of the row is decoded by its registered name
+	gob.Register(r.Row)
+	return sl.spill.enc.Encode(&r)
+}
+
+// flushSpill hands the rows of the temp file to the sink, and removes it
+func (sl *slot) flushSpill() error {
+	if sl.spill == nil {
+		return nil
+	}
+	spill := sl.spill
+	sl.spill = nil
+	defer func() {
+		spill.f.Close()
+		os.Remove(spill.f.Name())
+	}()
+	if err := spill.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := spill.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(bufio.NewReader(spill.f))
+	var err error
+	for {
+		var r pendingRow
+		if decodeErr := dec.Decode(&r); decodeErr != nil {
+			if !errors.Is(decodeErr, io.EOF) && err == nil {
+				err = decodeErr
+			}
+			return err
+		}
+		if sinkErr := sl.sink(r.Artifact, r.Row); sinkErr != nil && err == nil {
+			err = sinkErr
+		}
+	}
+}
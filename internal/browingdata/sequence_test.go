@@ -0,0 +1,91 @@
+package browingdata
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSequence(t *testing.T) {
+	t.Parallel()
+	var got []string
+	sinks := make([]Sink, 3)
+	for i := range sinks {
+		i := i
+		sinks[i] = func(artifact string, row any) error {
+			got = append(got, fmt.Sprintf("%d %s %v", i, artifact, row))
+			return nil
+		}
+	}
+	seq := NewSequence(sinks)
+	write := func(i int, row any) {
+		if err := seq.Sink(i)("history", row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done := func(i int) {
+		if err := seq.Done(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(2, "c")
+	write(1, "b")
+	write(0, "a")
+	done(2)
+	write(1, "b2")
+	if want := []string{"0 history a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows before writer 0 is done = %q, want %q", got, want)
+	}
+	done(0)
+	write(1, "b3")
+	done(1)
+	want := []string{"0 history a", "1 history b", "1 history b2", "1 history b3", "2 history c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+type testRow struct {
+	Name string
+	Time time.Time
+}
+
+func TestSequenceSpill(t *testing.T) {
+	t.Parallel()
+	var got []any
+	sinks := []Sink{
+		func(artifact string, row any) error { return nil },
+		func(artifact string, row any) error {
+			got = append(got, row)
+			return nil
+		},
+	}
+	seq := NewSequence(sinks)
+	seq.limit = 2
+	var want []any
+	for n := 0; n < 5; n++ {
+		row := testRow{Name: fmt.Sprint(n), Time: time.Date(2022, 1, n+1, 0, 0, 0, 0, time.UTC)}
+		want = append(want, row)
+		if err := seq.Sink(1)("history", row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(seq.slots[1].rows); n != 2 {
+		t.Errorf("rows in memory = %d, want 2", n)
+	}
+	spill := seq.slots[1].spill
+	if spill == nil {
+		t.Fatal("rows past the limit are not spilled")
+	}
+	if err := seq.Done(0); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(spill.f.Name()); !os.IsNotExist(err) {
+		t.Errorf("spill file %s is not removed", spill.f.Name())
+	}
+}
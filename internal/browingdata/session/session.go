@@ -0,0 +1,485 @@
+package session
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/lz4util"
+	"hack-browser-data/internal/utils/typeutil"
+
+	"github.com/tidwall/gjson"
+)
+
+type ChromiumSession []Navigation
+
+// Navigation is an entry of the navigation stack of a tab, the tab is
+// open or closed in a window of the session File.
+type Navigation struct {
+	File     string
+	WindowID int
+	TabID    int
+	// TabIndex is the position of the tab in its window
+	TabIndex        int
+	Pinned          bool
+	NavigationIndex int
+	// Current is the navigation the tab shows, the one back and forward
+	// navigations start from
+	Current  bool
+	URL      string
+	Title    string
+	Referrer string // chromium only
+	// NavigationTime is the time of the navigation, chromium only
+	NavigationTime time.Time
+	LastActiveTime time.Time
+	Closed         bool
+	ClosedTime     time.Time
+}
+
+// commands of Session_* files
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/session_service_commands.cc
+const (
+	commandSetTabWindow                     = 0
+	commandSetTabIndexInWindow              = 2
+	commandTabNavigationPathPrunedFromBack  = 5
+	commandUpdateTabNavigation              = 6
+	commandSetSelectedNavigationIndex       = 7
+	commandTabNavigationPathPrunedFromFront = 11
+	commandSetPinnedState                   = 12
+	commandTabClosed                        = 16
+	commandWindowClosed                     = 17
+	commandLastActiveTime                   = 21
+	commandTabNavigationPathPruned          = 24
+)
+
+// commands of Tabs_* files, the recently closed tabs and windows
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/tab_restore_service_impl.cc
+const (
+	restoreUpdateTabNavigation     = 1
+	restoreRestoredEntry           = 2
+	restoreWindow                  = 3
+	restoreSelectedNavigationInTab = 4
+	restorePinnedState             = 5
+)
+
+// chromiumEpoch is the first year of chromium releases, last active times
+// before it are TimeTicks of older builds, not wall clock times
+const chromiumEpoch = 2008
+
+// chromiumTab is a tab built from the commands of a file
+type chromiumTab struct {
+	id, window, index int32
+	selected          int32
+	pinned            bool
+	lastActive        int64
+	closed            bool
+	closedTime        int64
+	navigations       map[int32]navigation
+}
+
+// session files of chromium 99 and earlier, they are in the profile dir
+// as there is no Sessions dir
+const (
+	currentSession = "Current Session"
+	currentTabs    = "Current Tabs"
+	lastSession    = "Last Session"
+	lastTabs       = "Last Tabs"
+)
+
+// ChromiumLegacyFiles are the session files of chromium 99 and earlier
+var ChromiumLegacyFiles = []string{currentSession, currentTabs, lastSession, lastTabs}
+
+// Parse reads the Session_* and Tabs_* files of the Sessions dir at path,
+// or the legacy session files of the profile dir at path. A file that
+// can't be read is left out and reported in the error.
+func (c *ChromiumSession) Parse(_ decrypter.Keys, path string) error {
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, f := range files {
+		name := f.Name()
+		var read func(b []byte) ([]*chromiumTab, error)
+		switch {
+		case strings.HasPrefix(name, "Session_"), name == currentSession, name == lastSession:
+			read = readSession
+		case strings.HasPrefix(name, "Tabs_"), name == currentTabs, name == lastTabs:
+			read = readTabRestore
+		default:
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(path, name))
+		if err == nil {
+			var tabs []*chromiumTab
+			if tabs, err = read(b); err == nil {
+				*c = append(*c, chromiumNavigations(name, tabs)...)
+				continue
+			}
+		}
+		log.Warnf("read session %s error: %s", name, err)
+		failed = append(failed, name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("read sessions %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// readSession returns the tabs of a Session_* file, commands update the
+// tabs they name in the order they were written
+func readSession(b []byte) ([]*chromiumTab, error) {
+	commands, err := readCommands(b)
+	if err != nil {
+		return nil, err
+	}
+	var tabs []*chromiumTab
+	byID := make(map[int32]*chromiumTab)
+	tab := func(id int32) *chromiumTab {
+		t, ok := byID[id]
+		if !ok {
+			t = &chromiumTab{id: id, navigations: make(map[int32]navigation)}
+			byID[id] = t
+			tabs = append(tabs, t)
+		}
+		return t
+	}
+	closedWindows := make(map[int32]int64)
+	for _, cmd := range commands {
+		switch cmd.id {
+		case commandUpdateTabNavigation:
+			id, nav, err := readNavigation(cmd.payload)
+			if err != nil {
+				log.Debugf("read session navigation error: %s", err)
+				continue
+			}
+			tab(id).navigations[nav.index] = nav
+		case commandSetTabWindow:
+			if window, id, ok := idIndex(cmd.payload); ok {
+				tab(id).window = window
+			}
+		case commandSetTabIndexInWindow:
+			if id, index, ok := idIndex(cmd.payload); ok {
+				tab(id).index = index
+			}
+		case commandSetSelectedNavigationIndex:
+			if id, index, ok := idIndex(cmd.payload); ok {
+				tab(id).selected = index
+			}
+		case commandSetPinnedState:
+			if id, pinned, ok := idIndex(cmd.payload); ok {
+				// the bool is followed by padding
+				tab(id).pinned = pinned&0xff != 0
+			}
+		case commandLastActiveTime:
+			if id, t, ok := idTime(cmd.payload); ok {
+				tab(id).lastActive = t
+			}
+		case commandTabClosed:
+			if id, t, ok := idTime(cmd.payload); ok {
+				tab(id).closed, tab(id).closedTime = true, t
+			}
+		case commandWindowClosed:
+			if id, t, ok := idTime(cmd.payload); ok {
+				closedWindows[id] = t
+			}
+		case commandTabNavigationPathPrunedFromBack:
+			if id, index, ok := idIndex(cmd.payload); ok {
+				tab(id).prune(index, -1)
+			}
+		case commandTabNavigationPathPrunedFromFront:
+			if id, count, ok := idIndex(cmd.payload); ok {
+				tab(id).prune(0, count)
+			}
+		case commandTabNavigationPathPruned:
+			if id, index, ok := idIndex(cmd.payload); ok && len(cmd.payload) >= 12 {
+				tab(id).prune(index, int32(binary.LittleEndian.Uint32(cmd.payload[8:])))
+			}
+		}
+	}
+	for _, t := range tabs {
+		if closed, ok := closedWindows[t.window]; ok && !t.closed {
+			t.closed, t.closedTime = true, closed
+		}
+	}
+	return tabs, nil
+}
+
+// prune removes count navigations from index, all of them if count is -1,
+// the following ones are moved down
+func (t *chromiumTab) prune(index, count int32) {
+	navigations := make(map[int32]navigation, len(t.navigations))
+	for i, nav := range t.navigations {
+		switch {
+		case i < index:
+		case count < 0 || i < index+count:
+			continue
+		default:
+			nav.index -= count
+		}
+		navigations[nav.index] = nav
+	}
+	t.navigations = navigations
+}
+
+// readTabRestore returns the closed tabs of a Tabs_* file, a tab starts at
+// its selected navigation command, a window at its window command and its
+// tabs follow it.
+func readTabRestore(b []byte) ([]*chromiumTab, error) {
+	commands, err := readCommands(b)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		tabs       []*chromiumTab
+		byID       = make(map[int32]*chromiumTab)
+		restored   = make(map[int32]bool)
+		window     int32
+		windowTabs int32
+		windowTime int64
+		// windowTab is the position of the next tab of the window
+		windowTab int32
+		current   *chromiumTab
+	)
+	for _, cmd := range commands {
+		switch cmd.id {
+		case restoreWindow:
+			window, windowTabs, windowTime = readRestoreWindow(cmd.payload)
+			windowTab = 0
+		case restoreSelectedNavigationInTab:
+			id, index, ok := idIndex(cmd.payload)
+			if !ok {
+				continue
+			}
+			current = &chromiumTab{id: id, selected: index, closed: true, navigations: make(map[int32]navigation)}
+			if _, t, ok := idTime(cmd.payload); ok {
+				current.closedTime = t
+			}
+			if windowTabs > 0 {
+				current.window = window
+				current.index = windowTab
+				if current.closedTime == 0 {
+					current.closedTime = windowTime
+				}
+				windowTabs--
+				windowTab++
+			}
+			byID[id] = current
+			tabs = append(tabs, current)
+		case restoreUpdateTabNavigation:
+			id, nav, err := readNavigation(cmd.payload)
+			if err != nil {
+				log.Debugf("read closed tab navigation error: %s", err)
+				continue
+			}
+			if t, ok := byID[id]; ok {
+				t.navigations[nav.index] = nav
+			}
+		case restorePinnedState:
+			if current != nil && len(cmd.payload) > 0 {
+				current.pinned = cmd.payload[0] != 0
+			}
+		case restoreRestoredEntry:
+			if len(cmd.payload) >= 4 {
+				restored[int32(binary.LittleEndian.Uint32(cmd.payload))] = true
+			}
+		}
+	}
+	kept := tabs[:0]
+	for _, t := range tabs {
+		if !restored[t.id] && !restored[t.window] {
+			kept = append(kept, t)
+		}
+	}
+	return kept, nil
+}
+
+// readRestoreWindow reads the id, number of tabs and close time of a window
+// command, a struct of older builds or a pickle. Pickles have no time, it's
+// the one of each tab.
+func readRestoreWindow(payload []byte) (id, tabs int32, closed int64) {
+	switch len(payload) {
+	case 12, 24:
+		id = int32(binary.LittleEndian.Uint32(payload))
+		tabs = int32(binary.LittleEndian.Uint32(payload[8:]))
+		if len(payload) == 24 {
+			closed = int64(binary.LittleEndian.Uint64(payload[16:]))
+		}
+		return id, tabs, closed
+	}
+	p := newPickle(payload)
+	id = p.int()
+	p.int() // selected tab index
+	tabs = p.int()
+	if p.err != nil {
+		return 0, 0, 0
+	}
+	return id, tabs, 0
+}
+
+// chromiumNavigations returns the navigations of tabs in the order of their
+// windows and positions
+func chromiumNavigations(file string, tabs []*chromiumTab) []Navigation {
+	sort.SliceStable(tabs, func(i, j int) bool {
+		if tabs[i].window != tabs[j].window {
+			return tabs[i].window < tabs[j].window
+		}
+		return tabs[i].index < tabs[j].index
+	})
+	var l []Navigation
+	for _, t := range tabs {
+		indexes := make([]int32, 0, len(t.navigations))
+		for i := range t.navigations {
+			indexes = append(indexes, i)
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+		for _, i := range indexes {
+			nav := t.navigations[i]
+			l = append(l, Navigation{
+				File:            file,
+				WindowID:        int(t.window),
+				TabID:           int(t.id),
+				TabIndex:        int(t.index),
+				Pinned:          t.pinned,
+				NavigationIndex: int(i),
+				Current:         i == t.selected,
+				URL:             nav.url,
+				Title:           nav.title,
+				Referrer:        nav.referrer,
+				NavigationTime:  chromiumTime(nav.timestamp),
+				LastActiveTime:  chromiumLastActive(t.lastActive),
+				Closed:          t.closed,
+				ClosedTime:      chromiumTime(t.closedTime),
+			})
+		}
+	}
+	return l
+}
+
+// chromiumTime returns the time of microseconds since 1601, it's zero if
+// the time is unknown
+func chromiumTime(t int64) time.Time {
+	if t <= 0 {
+		return time.Time{}
+	}
+	return typeutil.TimeEpoch(t)
+}
+
+func chromiumLastActive(t int64) time.Time {
+	last := chromiumTime(t)
+	if last.Year() < chromiumEpoch {
+		return time.Time{}
+	}
+	return last
+}
+
+func (c *ChromiumSession) Name() string {
+	return "session"
+}
+
+func (c *ChromiumSession) Length() int {
+	return len(*c)
+}
+
+type FirefoxSession []Navigation
+
+// FirefoxFiles are the session files of a Firefox profile dir.
+// sessionstore.jsonlz4 is the session saved on exit, recovery.jsonlz4 the
+// one of a running Firefox and previous.jsonlz4 the one before it.
+var FirefoxFiles = []string{
+	"sessionstore.jsonlz4",
+	filepath.Join("sessionstore-backups", "recovery.jsonlz4"),
+	filepath.Join("sessionstore-backups", "previous.jsonlz4"),
+}
+
+// Parse reads the sessions of Firefox, path is the profile dir
+func (f *FirefoxSession) Parse(_ decrypter.Keys, path string) error {
+	var failed []string
+	for _, name := range FirefoxFiles {
+		b, err := lz4util.ReadMozLz4(filepath.Join(path, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			log.Warnf("read session %s error: %s", name, err)
+			failed = append(failed, filepath.Base(name))
+			continue
+		}
+		*f = append(*f, firefoxNavigations(filepath.Base(name), gjson.ParseBytes(b))...)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("read sessions %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// firefoxNavigations returns the navigations of the open and closed windows
+// of session. Firefox windows and tabs have no ids, they're numbered from 1
+// in the order of the session, closed windows after the open ones.
+func firefoxNavigations(file string, session gjson.Result) []Navigation {
+	var l []Navigation
+	windows := session.Get("windows").Array()
+	windows = append(windows, session.Get("_closedWindows").Array()...)
+	open := len(session.Get("windows").Array())
+	for w, window := range windows {
+		closedWindow := w >= open
+		windowClosed := firefoxTime(window.Get("closedAt").Int())
+		tabs := window.Get("tabs").Array()
+		for i, tab := range tabs {
+			l = append(l, firefoxTab(file, w+1, i+1, i, tab, closedWindow, windowClosed)...)
+		}
+		for i, closed := range window.Get("_closedTabs").Array() {
+			id := len(tabs) + i + 1
+			l = append(l, firefoxTab(file, w+1, id, int(closed.Get("pos").Int()), closed.Get("state"),
+				true, firefoxTime(closed.Get("closedAt").Int()))...)
+		}
+	}
+	return l
+}
+
+// firefoxTab returns the navigations of tab, index of a tab is the 1-based
+// index of its current entry
+func firefoxTab(file string, window, id, pos int, tab gjson.Result, closed bool, closedTime time.Time) []Navigation {
+	var l []Navigation
+	current := int(tab.Get("index").Int()) - 1
+	for i, entry := range tab.Get("entries").Array() {
+		l = append(l, Navigation{
+			File:            file,
+			WindowID:        window,
+			TabID:           id,
+			TabIndex:        pos,
+			Pinned:          tab.Get("pinned").Bool(),
+			NavigationIndex: i,
+			Current:         i == current,
+			URL:             entry.Get("url").String(),
+			Title:           entry.Get("title").String(),
+			LastActiveTime:  firefoxTime(tab.Get("lastAccessed").Int()),
+			Closed:          closed,
+			ClosedTime:      closedTime,
+		})
+	}
+	return l
+}
+
+// firefoxTime returns the time of milliseconds since 1970, it's zero if
+// the time is unknown
+func firefoxTime(ms int64) time.Time {
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+func (f *FirefoxSession) Name() string {
+	return "session"
+}
+
+func (f *FirefoxSession) Length() int {
+	return len(*f)
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// snssFile returns an SNSS file of version with commands, a command is its
+// id followed by its payload
+func snssFile(version uint32, commands ...[]byte) []byte {
+	b := binary.LittleEndian.AppendUint32([]byte("SNSS"), version)
+	for _, c := range commands {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(c)))
+		b = append(b, c...)
+	}
+	return b
+}
+
+// navigationCommand returns an UpdateTabNavigation command of a pickle cut
+// after the title
+func navigationCommand(tabID, index int32, url string) []byte {
+	var p []byte
+	p = binary.LittleEndian.AppendUint32(p, uint32(tabID))
+	p = binary.LittleEndian.AppendUint32(p, uint32(index))
+	p = binary.LittleEndian.AppendUint32(p, uint32(len(url)))
+	p = append(p, url...)
+	for len(p)%4 != 0 {
+		p = append(p, 0)
+	}
+	p = binary.LittleEndian.AppendUint32(p, 0)
+	payload := binary.LittleEndian.AppendUint32(nil, uint32(len(p)))
+	return append([]byte{commandUpdateTabNavigation}, append(payload, p...)...)
+}
+
+func pairCommand(id uint8, a, b int32) []byte {
+	return binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32([]byte{id}, uint32(a)), uint32(b))
+}
+
+func TestReadSession(t *testing.T) {
+	urls := func(tabs []*chromiumTab) map[int32]string {
+		m := make(map[int32]string)
+		for i, nav := range tabs[0].navigations {
+			m[i] = nav.url
+		}
+		return m
+	}
+	tests := []struct {
+		name     string
+		commands [][]byte
+		want     map[int32]string
+	}{
+		{
+			name: "pruned from back",
+			commands: [][]byte{
+				navigationCommand(1, 0, "a"), navigationCommand(1, 1, "b"), navigationCommand(1, 2, "c"),
+				pairCommand(commandTabNavigationPathPrunedFromBack, 1, 1),
+			},
+			want: map[int32]string{0: "a"},
+		},
+		{
+			name: "pruned in between",
+			commands: [][]byte{
+				navigationCommand(1, 0, "a"), navigationCommand(1, 1, "b"), navigationCommand(1, 2, "c"),
+				append(pairCommand(commandTabNavigationPathPruned, 1, 1), 1, 0, 0, 0),
+			},
+			want: map[int32]string{0: "a", 1: "c"},
+		},
+		{
+			name: "truncated last command",
+			commands: [][]byte{
+				navigationCommand(1, 0, "a"),
+				{0xff, 0xff, commandUpdateTabNavigation},
+			},
+			want: map[int32]string{0: "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tabs, err := readSession(snssFile(snssVersion, tt.commands...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tabs) != 1 {
+				t.Fatalf("read %d tabs, want 1", len(tabs))
+			}
+			if got := urls(tabs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("navigations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSessionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{name: "sqlite", b: []byte("SQLite format 3\x00"), err: errNotSNSS},
+		{name: "encrypted", b: snssFile(snssVersionWithMarker + 1), err: errEncryptedSNSS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readSession(tt.b); !errors.Is(err, tt.err) {
+				t.Errorf("readSession() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// FuzzReadSession only requires the readers not to panic, run with
+// go test -fuzz=FuzzReadSession
+func FuzzReadSession(f *testing.F) {
+	f.Add(snssFile(snssVersion, navigationCommand(1, 0, "https://go.dev/"), pairCommand(commandSetSelectedNavigationIndex, 1, 0)))
+	f.Add(snssFile(snssVersion, pairCommand(restoreSelectedNavigationInTab, 1, 0), navigationCommand(1, 0, "https://go.dev/")))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = readSession(b)
+		_, _ = readTabRestore(b)
+	})
+}
@@ -0,0 +1,186 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// SNSS files are a log of commands, each one a little endian uint16 size,
+// which counts the id, an uint8 id and its payload. Version 2 and 4 are
+// the encrypted ones.
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/command_storage_backend.cc
+const (
+	snssMagic              = "SNSS"
+	snssVersion            = 1
+	snssVersionWithMarker  = 3
+	snssHeaderSize         = 8
+	snssInitialStateMarker = 255
+)
+
+var (
+	errNotSNSS       = errors.New("not an SNSS file")
+	errEncryptedSNSS = errors.New("encrypted SNSS files are not supported")
+	errPickle        = errors.New("corrupt pickle")
+)
+
+type command struct {
+	id      uint8
+	payload []byte
+}
+
+// readCommands returns the commands of the SNSS file b, a truncated command
+// at the end is left out, it's the one being written when chromium exited.
+func readCommands(b []byte) ([]command, error) {
+	if len(b) < snssHeaderSize || !bytes.HasPrefix(b, []byte(snssMagic)) {
+		return nil, errNotSNSS
+	}
+	switch v := binary.LittleEndian.Uint32(b[4:]); v {
+	case snssVersion, snssVersionWithMarker:
+	case snssVersion + 1, snssVersionWithMarker + 1:
+		return nil, errEncryptedSNSS
+	default:
+		return nil, fmt.Errorf("%w: version %d", errNotSNSS, v)
+	}
+	var commands []command
+	for i := snssHeaderSize; i+2 < len(b); {
+		size := int(binary.LittleEndian.Uint16(b[i:]))
+		i += 2
+		if size == 0 || i+size > len(b) {
+			break
+		}
+		if id := b[i]; id != snssInitialStateMarker {
+			commands = append(commands, command{id: id, payload: b[i+1 : i+size]})
+		}
+		i += size
+	}
+	return commands, nil
+}
+
+// pickle reads a base::Pickle, an uint32 size followed by fields aligned to
+// 4 bytes. Strings are prefixed with their length, in bytes for UTF-8 and
+// in code units for UTF-16.
+// @https://source.chromium.org/chromium/chromium/src/+/main:base/pickle.h
+type pickle struct {
+	b   []byte
+	off int
+	err error
+}
+
+func newPickle(payload []byte) *pickle {
+	p := &pickle{}
+	if len(payload) < 4 {
+		p.err = errPickle
+		return p
+	}
+	size := int(binary.LittleEndian.Uint32(payload))
+	p.b = payload[4:]
+	// navigations are cut to the max size of a command, keep what's there
+	if size < len(p.b) {
+		p.b = p.b[:size]
+	}
+	return p
+}
+
+// next returns the next n bytes and skips their padding
+func (p *pickle) next(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(p.b)-p.off {
+		p.err = errPickle
+		return nil
+	}
+	b := p.b[p.off : p.off+n]
+	p.off += (n + 3) &^ 3
+	if p.off > len(p.b) {
+		p.off = len(p.b)
+	}
+	return b
+}
+
+func (p *pickle) int() int32 {
+	b := p.next(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+func (p *pickle) int64() int64 {
+	b := p.next(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint64(b))
+}
+
+func (p *pickle) string() string {
+	return string(p.next(int(p.int())))
+}
+
+func (p *pickle) string16() string {
+	n := int(p.int())
+	if n > len(p.b) {
+		p.err = errPickle
+		return ""
+	}
+	b := p.next(n * 2)
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// navigation is a sessions::SerializedNavigationEntry
+type navigation struct {
+	index     int32
+	url       string
+	title     string
+	referrer  string
+	timestamp int64
+}
+
+// readNavigation reads the navigation of an UpdateTabNavigation command,
+// prefixed with the id of its tab. Fields after the title were added over
+// time, they're left empty if the pickle ends before, a failed read makes
+// the following ones return zero values.
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/sessions/core/serialized_navigation_entry.cc
+func readNavigation(payload []byte) (int32, navigation, error) {
+	p := newPickle(payload)
+	tabID := p.int()
+	nav := navigation{index: p.int(), url: p.string(), title: p.string16()}
+	if p.err != nil {
+		return 0, nav, p.err
+	}
+	p.string() // encoded page state
+	p.int()    // transition type
+	p.int()    // type mask
+	nav.referrer = p.string()
+	p.int()    // referrer policy, ignored
+	p.string() // original request url
+	p.int()    // is overriding user agent
+	nav.timestamp = p.int64()
+	return tabID, nav, nil
+}
+
+// idIndex reads the payloads of a pair of int32, most commands are such a
+// pair of a session id and an index
+func idIndex(payload []byte) (int32, int32, bool) {
+	if len(payload) < 8 {
+		return 0, 0, false
+	}
+	return int32(binary.LittleEndian.Uint32(payload)), int32(binary.LittleEndian.Uint32(payload[4:])), true
+}
+
+// idTime reads the payloads of an int32 session id and an int64 time, the
+// time is aligned to 8 bytes
+func idTime(payload []byte) (int32, int64, bool) {
+	if len(payload) < 16 {
+		return 0, 0, false
+	}
+	return int32(binary.LittleEndian.Uint32(payload)), int64(binary.LittleEndian.Uint64(payload[8:])), true
+}
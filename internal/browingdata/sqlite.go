@@ -0,0 +1,268 @@
+package browingdata
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"hack-browser-data/internal/browingdata/address"
+	"hack-browser-data/internal/browingdata/autofill"
+	"hack-browser-data/internal/browingdata/bookmark"
+	"hack-browser-data/internal/browingdata/cookie"
+	"hack-browser-data/internal/browingdata/creditcard"
+	"hack-browser-data/internal/browingdata/download"
+	"hack-browser-data/internal/browingdata/extension"
+	"hack-browser-data/internal/browingdata/history"
+	"hack-browser-data/internal/browingdata/indexeddb"
+	"hack-browser-data/internal/browingdata/localstorage"
+	"hack-browser-data/internal/browingdata/password"
+	"hack-browser-data/internal/browingdata/searchterm"
+	"hack-browser-data/internal/browingdata/session"
+
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteTables maps artifacts to their tables, tables are created up front
+// so every results.db has the same schema, even without rows.
+var sqliteTables = []struct {
+	artifact string
+	table    string
+	row      any
+}{
+	{"password", "passwords", password.LoginData{}},
+	{"cookie", "cookies", cookie.Cookie{}},
+	{"history", "history_visits", history.Visit{}},
+	{"download", "downloads", download.Download{}},
+	{"bookmark", "bookmarks", bookmark.Bookmark{}},
+	{"extension", "extensions", extension.Extension{}},
+	{"localStorage", "local_storage", localstorage.Storage{}},
+	{"sessionStorage", "session_storage", localstorage.Storage{}},
+	{"indexedDB", "indexed_db", indexeddb.Record{}},
+	{"creditcard", "credit_cards", creditcard.Card{}},
+	{"address", "addresses", address.Address{}},
+	{"autofill", "autofill_entries", autofill.Entry{}},
+	{"session", "session_navigations", session.Navigation{}},
+	{"searchTerm", "search_terms", searchterm.SearchTerm{}},
+	{"posture", "firefox_posture", password.Posture{}},
+}
+
+// sqliteBatchRows is how many rows are committed at once, a run that is
+// interrupted loses at most the rows of a batch
+var sqliteBatchRows = 1000
+
+// SQLiteWriter writes rows into a table per artifact of a single database,
+// every table has browser and profile columns. It's safe for concurrent use
+// by browsers, rows are committed in batches of sqliteBatchRows and on Close.
+type SQLiteWriter struct {
+	mu     sync.Mutex
+	db     *sql.DB
+	tables map[string]*sqliteTable
+	// tx is the transaction of the current batch, nil between batches
+	tx   *sql.Tx
+	rows int
+}
+
+type sqliteTable struct {
+	stmt *sql.Stmt
+	// txStmt is stmt of the current batch
+	txStmt *sql.Stmt
+	fields []int
+}
+
+// NewSQLiteWriter creates the database at filename, an existing one is replaced
+func NewSQLiteWriter(filename string) (*SQLiteWriter, error) {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	// rows of all browsers go through one connection
+	db.SetMaxOpenConns(1)
+	w := &SQLiteWriter{db: db, tables: make(map[string]*sqliteTable)}
+	for _, t := range sqliteTables {
+		if _, err := w.createTable(t.artifact, t.table, reflect.TypeOf(t.row)); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Sink returns the Sink of a browser profile
+func (w *SQLiteWriter) Sink(browser, profile string) Sink {
+	return func(artifact string, row any) error {
+		return w.writeRow(browser, profile, artifact, row)
+	}
+}
+
+func (w *SQLiteWriter) writeRow(browser, profile, artifact string, row any) error {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t, ok := w.tables[artifact]
+	if !ok {
+		// artifacts without a table in sqliteTables get one named by the artifact
+		var err error
+		if t, err = w.createTable(artifact, snakeCase(artifact), v.Type()); err != nil {
+			return err
+		}
+	}
+	args := []any{browser, profile}
+	if v.Kind() == reflect.Struct {
+		for _, i := range t.fields {
+			args = append(args, sqliteValue(v.Field(i)))
+		}
+	} else {
+		args = append(args, sqliteValue(v))
+	}
+	if w.tx == nil {
+		tx, err := w.db.Begin()
+		if err != nil {
+			return err
+		}
+		w.tx = tx
+	}
+	if t.txStmt == nil {
+		t.txStmt = w.tx.Stmt(t.stmt)
+	}
+	if _, err := t.txStmt.Exec(args...); err != nil {
+		return err
+	}
+	if w.rows++; w.rows >= sqliteBatchRows {
+		return w.commit()
+	}
+	return nil
+}
+
+// commit commits the current batch, it must be called with mu held
+func (w *SQLiteWriter) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+	for _, t := range w.tables {
+		t.txStmt = nil
+	}
+	err := w.tx.Commit()
+	w.tx, w.rows = nil, 0
+	return err
+}
+
+// createTable creates the table of artifact with a column per exported field
+// of rowType, it must be called with mu held or before the writer is shared.
+// The current batch is committed first, the only connection is its own.
+func (w *SQLiteWriter) createTable(artifact, table string, rowType reflect.Type) (*sqliteTable, error) {
+	t := &sqliteTable{}
+	columns := []string{"browser TEXT", "profile TEXT"}
+	if rowType.Kind() == reflect.Struct {
+		for i := 0; i < rowType.NumField(); i++ {
+			f := rowType.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			t.fields = append(t.fields, i)
+			columns = append(columns, snakeCase(f.Name)+" "+sqliteType(f.Type))
+		}
+	} else {
+		columns = append(columns, "value "+sqliteType(rowType))
+	}
+	if err := w.commit(); err != nil {
+		return nil, err
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(columns, ", "))
+	if _, err := w.db.Exec(create); err != nil {
+		return nil, err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	stmt, err := w.db.Prepare(fmt.Sprintf("INSERT INTO %s VALUES (%s)", table, placeholders))
+	if err != nil {
+		return nil, err
+	}
+	t.stmt = stmt
+	w.tables[artifact] = t
+	return t, nil
+}
+
+// Close commits the last batch and closes the database
+func (w *SQLiteWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.commit()
+	for _, t := range w.tables {
+		t.stmt.Close()
+	}
+	if closeErr := w.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func sqliteType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Time{}) {
+		return "TIMESTAMP"
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "BLOB"
+		}
+	}
+	return "TEXT"
+}
+
+func sqliteValue(v reflect.Value) any {
+	switch sqliteType(v.Type()) {
+	case "TIMESTAMP", "BLOB":
+		return v.Interface()
+	case "INTEGER":
+		switch v.Kind() {
+		case reflect.Bool:
+			return v.Bool()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return int64(v.Uint())
+		default:
+			return v.Int()
+		}
+	case "REAL":
+		return v.Float()
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	// anything else is kept as json
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+// snakeCase turns a field name like LoginURL into login_url
+func snakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package browingdata
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"hack-browser-data/internal/browingdata/history"
+)
+
+func TestSQLiteWriterBatches(t *testing.T) {
+	batch := sqliteBatchRows
+	sqliteBatchRows = 2
+	defer func() { sqliteBatchRows = batch }()
+
+	filename := filepath.Join(t.TempDir(), "results.db")
+	w, err := NewSQLiteWriter(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := w.Sink("chrome-default", "/profile/Default")
+	for _, url := range []string{"https://a.com", "https://b.com", "https://c.com", "https://d.com", "https://e.com"} {
+		if err := sink("history", history.Visit{URL: url}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the run is interrupted before Close, full batches are in the database
+	if got := countRows(t, filename, "history_visits"); got != 4 {
+		t.Errorf("rows before Close = %d, want 4", got)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := countRows(t, filename, "history_visits"); got != 5 {
+		t.Errorf("rows after Close = %d, want 5", got)
+	}
+}
+
+func countRows(t *testing.T, filename, table string) int {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -7,6 +7,9 @@ import (
 type Browser interface {
 	// Name is browser's name
 	Name() string
-	// BrowsingData returns all browsing data in the browser.
-	BrowsingData() (*browingdata.Data, error)
+	// Profile is the profile dir of the browser
+	Profile() string
+	// BrowsingData returns all browsing data in the browser, if sink is
+	// not nil rows are handed to it while parsed instead of kept in Data.
+	BrowsingData(sink browingdata.Sink) (*browingdata.Data, error)
 }
@@ -4,215 +4,180 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/des"
-	"crypto/hmac"
-	"crypto/sha1"
-	"crypto/sha256"
-	"encoding/asn1"
 	"errors"
-
-	"golang.org/x/crypto/pbkdf2"
+	"fmt"
 )
 
-var (
-	errPasswordIsEmpty  = errors.New("password is empty")
-	errDecodeASN1Failed = errors.New("decode ASN1 data failed")
-	errEncryptedLength  = errors.New("length of encrypted password less than block size")
-)
+// ErrDecryptFailed is wrapped by sources that could not decrypt some of their rows
+var ErrDecryptFailed = errors.New("decrypt failed")
 
-type ASN1PBE interface {
-	Decrypt(globalSalt, masterPwd []byte) (key []byte, err error)
-}
+// ErrNoLoginKey means the firefox key of logins, which credit card numbers
+// of older firefox are encrypted with as well, couldn't be derived
+var ErrNoLoginKey = fmt.Errorf("%w: firefox key of logins is unavailable", ErrDecryptFailed)
 
-func NewASN1PBE(b []byte) (pbe ASN1PBE, err error) {
-	var (
-		n nssPBE
-		m metaPBE
-		l loginPBE
-	)
-	if _, err := asn1.Unmarshal(b, &n); err == nil {
-		return n, nil
-	}
-	if _, err := asn1.Unmarshal(b, &m); err == nil {
-		return m, nil
-	}
-	if _, err := asn1.Unmarshal(b, &l); err == nil {
-		return l, nil
-	}
-	return nil, errDecodeASN1Failed
-}
+// errors of the decrypt routines, a wrong key never turns into garbled plaintext
+var (
+	// ErrWrongKey means the blob is well formed but the key doesn't open
+	// it, its padding or authentication tag is invalid
+	ErrWrongKey = errors.New("wrong key")
+	// ErrCorruptBlob means the blob is malformed, like a ciphertext which
+	// is not a multiple of the block size or an IV of the wrong length
+	ErrCorruptBlob = errors.New("corrupt blob")
+	// ErrUnsupportedPrefix means the version prefix of a chromium blob is unknown
+	ErrUnsupportedPrefix = errors.New("unsupported prefix")
+)
 
-// nssPBE Struct
-//
-//	SEQUENCE (2 elem)
-//		OBJECT IDENTIFIER
-//		SEQUENCE (2 elem)
-//			OCTET STRING (20 byte)
-//			INTEGER 1
-//	OCTET STRING (16 byte)
-type nssPBE struct {
-	AlgoAttr struct {
-		asn1.ObjectIdentifier
-		SaltAttr struct {
-			EntrySalt []byte
-			Len       int
-		}
-	}
-	Encrypted []byte
-}
+var errDecodeASN1Failed = fmt.Errorf("%w: decode ASN1 data failed", ErrCorruptBlob)
 
-func (n nssPBE) Decrypt(globalSalt, masterPwd []byte) (key []byte, err error) {
-	glmp := append(globalSalt, masterPwd...)
-	hp := sha1.Sum(glmp)
-	s := append(hp[:], n.entrySalt()...)
-	chp := sha1.Sum(s)
-	pes := paddingZero(n.entrySalt(), 20)
-	tk := hmac.New(sha1.New, chp[:])
-	tk.Write(pes)
-	pes = append(pes, n.entrySalt()...)
-	k1 := hmac.New(sha1.New, chp[:])
-	k1.Write(pes)
-	tkPlus := append(tk.Sum(nil), n.entrySalt()...)
-	k2 := hmac.New(sha1.New, chp[:])
-	k2.Write(tkPlus)
-	k := append(k1.Sum(nil), k2.Sum(nil)...)
-	iv := k[len(k)-8:]
-	return des3Decrypt(k[:24], iv, n.encrypted())
-}
+// errNoDPAPI is the error of DPAPI blobs outside Windows, they have no
+// version prefix and only the Windows user who encrypted them can decrypt them
+var errNoDPAPI = fmt.Errorf("%w: DPAPI blob can only be decrypted on Windows", ErrUnsupportedPrefix)
 
-func (n nssPBE) entrySalt() []byte {
-	return n.AlgoAttr.SaltAttr.EntrySalt
-}
-
-func (n nssPBE) encrypted() []byte {
-	return n.Encrypted
-}
+const (
+	aes128KeySize = 16
+	aes256KeySize = 32
+	des3KeySize   = 24
+	gcmNonceSize  = 12
+	gcmTagSize    = 16
+)
 
-// MetaPBE Struct
-//
-//	SEQUENCE (2 elem)
-//		OBJECT IDENTIFIER
-//	    SEQUENCE (2 elem)
-//	    SEQUENCE (2 elem)
-//	      	OBJECT IDENTIFIER
-//	       	SEQUENCE (4 elem)
-//	       	OCTET STRING (32 byte)
-//	      		INTEGER 1
-//	       		INTEGER 32
-//	       		SEQUENCE (1 elem)
-//	          	OBJECT IDENTIFIER
-//	    SEQUENCE (2 elem)
-//	      	OBJECT IDENTIFIER
-//	      	OCTET STRING (14 byte)
-//	OCTET STRING (16 byte)
-type metaPBE struct {
-	AlgoAttr  algoAttr
-	Encrypted []byte
-}
+// Status is the outcome of decrypting the value of a record, it's kept
+// in the DecryptStatus field of records with encrypted values.
+type Status string
+
+const (
+	StatusOK Status = "ok"
+	// StatusPlain means the value was not encrypted
+	StatusPlain             Status = "plain"
+	StatusWrongKey          Status = "wrong-key"
+	StatusCorruptBlob       Status = "corrupt-blob"
+	StatusUnsupportedPrefix Status = "unsupported-prefix"
+	// StatusUnsupportedAlgorithm means the OID of a firefox blob is unknown
+	StatusUnsupportedAlgorithm Status = "unsupported-algorithm"
+	// StatusFailed is any other error, like a missing key
+	StatusFailed Status = "failed"
+)
 
-type algoAttr struct {
-	asn1.ObjectIdentifier
-	Data struct {
-		Data struct {
-			asn1.ObjectIdentifier
-			SlatAttr slatAttr
-		}
-		IVData ivAttr
+// StatusOf returns the Status of err returned by a decrypt routine
+func StatusOf(err error) Status {
+	switch {
+	case err == nil:
+		return StatusOK
+	case errors.Is(err, ErrWrongKey):
+		return StatusWrongKey
+	case errors.Is(err, ErrCorruptBlob):
+		return StatusCorruptBlob
+	case errors.Is(err, ErrUnsupportedPrefix):
+		return StatusUnsupportedPrefix
+	case errors.Is(err, ErrUnsupportedOID):
+		return StatusUnsupportedAlgorithm
+	default:
+		return StatusFailed
 	}
 }
 
-type ivAttr struct {
-	asn1.ObjectIdentifier
-	IV []byte
-}
-
-type slatAttr struct {
-	EntrySalt      []byte
-	IterationCount int
-	KeySize        int
-	Algorithm      struct {
-		asn1.ObjectIdentifier
+func aes128CBCDecrypt(key, iv, encryptPass []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
 	}
+	return cbcDecrypt(block, iv, encryptPass)
 }
 
-func (m metaPBE) Decrypt(globalSalt, masterPwd []byte) (key2 []byte, err error) {
-	k := sha1.Sum(globalSalt)
-	key := pbkdf2.Key(k[:], m.entrySalt(), m.iterationCount(), m.keySize(), sha256.New)
-	iv := append([]byte{4, 14}, m.iv()...)
-	return aes128CBCDecrypt(key, iv, m.encrypted())
-}
-
-func (m metaPBE) entrySalt() []byte {
-	return m.AlgoAttr.Data.Data.SlatAttr.EntrySalt
+// chromiumCBCDecrypt decrypts the v10/v11 blob of chromium on macOS and Linux
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/os_crypt_linux.cc
+func chromiumCBCDecrypt(key, encryptPass []byte) ([]byte, error) {
+	iv := []byte{32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
+	return aes128CBCDecrypt(key, iv, encryptPass[prefixLen:])
 }
 
-func (m metaPBE) iterationCount() int {
-	return m.AlgoAttr.Data.Data.SlatAttr.IterationCount
-}
-
-func (m metaPBE) keySize() int {
-	return m.AlgoAttr.Data.Data.SlatAttr.KeySize
-}
-
-func (m metaPBE) iv() []byte {
-	return m.AlgoAttr.Data.IVData.IV
-}
-
-func (m metaPBE) encrypted() []byte {
-	return m.Encrypted
-}
-
-// loginPBE Struct
-//
-//	OCTET STRING (16 byte)
-//	SEQUENCE (2 elem)
-//			OBJECT IDENTIFIER
-//			OCTET STRING (8 byte)
-//	OCTET STRING (16 byte)
-type loginPBE struct {
-	CipherText []byte
-	Data       struct {
-		asn1.ObjectIdentifier
-		IV []byte
-	}
-	Encrypted []byte
-}
-
-func (l loginPBE) Decrypt(globalSalt, masterPwd []byte) (key []byte, err error) {
-	return des3Decrypt(globalSalt, l.iv(), l.encrypted())
+// chromiumGCMDecrypt decrypts the v10 blob of chromium > 80 on Windows
+// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_win.cc
+func chromiumGCMDecrypt(key, encryptPass []byte) ([]byte, error) {
+	if len(encryptPass) < prefixLen+gcmNonceSize {
+		return nil, fmt.Errorf("%w: GCM blob of %d bytes", ErrCorruptBlob, len(encryptPass))
+	}
+	return aesGCMDecrypt(encryptPass[prefixLen+gcmNonceSize:], key, encryptPass[prefixLen:prefixLen+gcmNonceSize])
 }
 
-func (l loginPBE) iv() []byte {
-	return l.Data.IV
+// FirefoxOSKeyStore decrypts a blob of firefox encrypted with the key of the
+// OS key store, like credit card numbers, it's the 12 bytes IV followed
+// by the AES-256-GCM ciphertext
+// @https://searchfox.org/mozilla-central/source/security/manager/ssl/OSKeyStore.cpp
+func FirefoxOSKeyStore(key, blob []byte) ([]byte, error) {
+	if len(key) != aes256KeySize {
+		return nil, fmt.Errorf("%w: OS key store key of %d bytes", ErrWrongKey, len(key))
+	}
+	if len(blob) < gcmNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("%w: OS key store blob of %d bytes", ErrCorruptBlob, len(blob))
+	}
+	return aesGCMDecrypt(blob[gcmNonceSize:], key, blob[:gcmNonceSize])
 }
 
-func (l loginPBE) encrypted() []byte {
-	return l.Encrypted
+// chromiumBlobKey checks the prefix of a chromium blob and returns the key of it
+func chromiumBlobKey(keys Keys, encryptPass []byte) ([]byte, error) {
+	if len(encryptPass) < prefixLen {
+		return nil, fmt.Errorf("%w: blob of %d bytes", ErrCorruptBlob, len(encryptPass))
+	}
+	switch prefix := string(encryptPass[:prefixLen]); prefix {
+	case PrefixV10, PrefixV11:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPrefix, prefix)
+	}
+	return keys.blobKey(encryptPass)
 }
 
-func aes128CBCDecrypt(key, iv, encryptPass []byte) ([]byte, error) {
+func aesGCMDecrypt(crypted, key, nounce []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
-	encryptLen := len(encryptPass)
-	if encryptLen < block.BlockSize() {
-		return nil, errEncryptedLength
+	blockMode, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nounce) != blockMode.NonceSize() || len(crypted) < blockMode.Overhead() {
+		return nil, fmt.Errorf("%w: GCM nonce of %d and ciphertext of %d bytes", ErrCorruptBlob, len(nounce), len(crypted))
 	}
+	origData, err := blockMode.Open(nil, nounce, crypted, nil)
+	if err != nil {
+		// the tag can't tell a wrong key from a damaged blob, the key is far more likely
+		return nil, fmt.Errorf("%w: %s", ErrWrongKey, err)
+	}
+	return origData, nil
+}
 
-	dst := make([]byte, encryptLen)
-	mode := cipher.NewCBCDecrypter(block, iv)
-	mode.CryptBlocks(dst, encryptPass)
-	dst = pkcs5UnPadding(dst, block.BlockSize())
-	return dst, nil
+// cbcDecrypt decrypts src and removes its PKCS#7 padding
+func cbcDecrypt(block cipher.Block, iv, src []byte) ([]byte, error) {
+	blockSize := block.BlockSize()
+	if len(iv) != blockSize {
+		return nil, fmt.Errorf("%w: IV of %d bytes", ErrCorruptBlob, len(iv))
+	}
+	if len(src) == 0 || len(src)%blockSize != 0 {
+		return nil, fmt.Errorf("%w: ciphertext of %d bytes", ErrCorruptBlob, len(src))
+	}
+	dst := make([]byte, len(src))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(dst, src)
+	return pkcs7Unpad(dst, blockSize)
 }
 
-func pkcs5UnPadding(src []byte, blockSize int) []byte {
+// pkcs7Unpad removes the padding of src, an invalid padding means the
+// key was wrong as the ciphertext is a whole number of blocks
+func pkcs7Unpad(src []byte, blockSize int) ([]byte, error) {
 	n := len(src)
-	paddingNum := int(src[n-1])
-	if n < paddingNum || paddingNum > blockSize {
-		return src
+	if n == 0 || n%blockSize != 0 {
+		return nil, fmt.Errorf("%w: plaintext of %d bytes", ErrCorruptBlob, n)
 	}
-	return src[:n-paddingNum]
+	padding := int(src[n-1])
+	if padding == 0 || padding > blockSize {
+		return nil, fmt.Errorf("%w: invalid padding", ErrWrongKey)
+	}
+	for _, b := range src[n-padding:] {
+		if int(b) != padding {
+			return nil, fmt.Errorf("%w: invalid padding", ErrWrongKey)
+		}
+	}
+	return src[:n-padding], nil
 }
 
 // des3Decrypt use for decrypt firefox PBE
@@ -221,10 +186,15 @@ func des3Decrypt(key, iv []byte, src []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	blockMode := cipher.NewCBCDecrypter(block, iv)
-	sq := make([]byte, len(src))
-	blockMode.CryptBlocks(sq, src)
-	return pkcs5UnPadding(sq, block.BlockSize()), nil
+	return cbcDecrypt(block, iv, src)
+}
+
+// saltedPassword returns globalSalt + primary password of key4.db without
+// touching the backing array of globalSalt
+func saltedPassword(globalSalt, masterPwd []byte) []byte {
+	glmp := make([]byte, 0, len(globalSalt)+len(masterPwd))
+	glmp = append(glmp, globalSalt...)
+	return append(glmp, masterPwd...)
 }
 
 func paddingZero(s []byte, l int) []byte {
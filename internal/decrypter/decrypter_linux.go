@@ -2,15 +2,20 @@
 
 package decrypter
 
-func Chromium(key, encryptPass []byte) ([]byte, error) {
-	if len(encryptPass) < 3 {
-		return nil, errPasswordIsEmpty
+// Chromium decrypts a v10 or v11 blob with the key of its prefix
+func Chromium(keys Keys, encryptPass []byte) ([]byte, error) {
+	key, err := chromiumBlobKey(keys, encryptPass)
+	if err != nil {
+		return nil, err
 	}
-
-	chromeIV := []byte{32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32, 32}
-	return aes128CBCDecrypt(key, chromeIV, encryptPass[3:])
+	// 32 bytes key comes from a Windows profile analysed offline
+	if len(key) == aes256KeySize {
+		return chromiumGCMDecrypt(key, encryptPass)
+	}
+	return chromiumCBCDecrypt(key, encryptPass)
 }
 
+// DPAPI is Windows only, blobs of other OSes are never encrypted with it
 func DPAPI(data []byte) ([]byte, error) {
-	return nil, nil
+	return nil, errNoDPAPI
 }
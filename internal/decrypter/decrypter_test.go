@@ -0,0 +1,425 @@
+package decrypter
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"runtime"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	testCBCKey  = []byte("0123456789abcdef")
+	testGCMKey  = []byte("0123456789abcdef0123456789abcdef")
+	test3DESKey = []byte("0123456789abcdef01234567")
+	chromiumIV  = bytes.Repeat([]byte{' '}, aes.BlockSize)
+)
+
+func pad(src []byte, blockSize int) []byte {
+	n := blockSize - len(src)%blockSize
+	return append(append([]byte{}, src...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func encryptCBC(t testing.TB, block cipher.Block, iv, plaintext []byte) []byte {
+	t.Helper()
+	src := pad(plaintext, block.BlockSize())
+	dst := make([]byte, len(src))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(dst, src)
+	return dst
+}
+
+func chromiumCBCBlob(t testing.TB, key, plaintext []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return append([]byte(PrefixV10), encryptCBC(t, block, chromiumIV, plaintext)...)
+}
+
+func chromiumGCMBlob(t testing.TB, key, plaintext []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcmNonceSize)
+	return append(append([]byte(PrefixV10), nonce...), gcm.Seal(nil, nonce, plaintext, nil)...)
+}
+
+var (
+	testGlobalSalt = bytes.Repeat([]byte{9}, 20)
+	oidAES192CBC   = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+)
+
+func marshal(t testing.TB, v any) []byte {
+	t.Helper()
+	b, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func algorithm(t testing.TB, oid asn1.ObjectIdentifier, params any) algorithmIdentifier {
+	t.Helper()
+	return algorithmIdentifier{Algorithm: oid, Parameters: asn1.RawValue{FullBytes: marshal(t, params)}}
+}
+
+// cbcCipher returns the block cipher of oid
+func cbcCipher(t testing.TB, oid asn1.ObjectIdentifier, key []byte) cipher.Block {
+	t.Helper()
+	var (
+		block cipher.Block
+		err   error
+	)
+	if oid.Equal(oidDESEDE3CBC) {
+		block, err = des.NewTripleDESCipher(key)
+	} else {
+		block, err = aes.NewCipher(key)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return block
+}
+
+// encryptScheme encrypts plaintext with key, iv is stored as is, NSS
+// stores 14 bytes of an AES IV and the DER header is the rest of it
+func encryptScheme(t testing.TB, oid asn1.ObjectIdentifier, key, iv, plaintext []byte) (algorithmIdentifier, []byte) {
+	t.Helper()
+	block := cbcCipher(t, oid, key)
+	alg := algorithm(t, oid, iv)
+	realIV := iv
+	if len(iv) != block.BlockSize() {
+		realIV = alg.Parameters.FullBytes
+	}
+	return alg, encryptCBC(t, block, realIV, plaintext)
+}
+
+// pbes2Blob encrypts plaintext like an entry of key4.db since Firefox 75,
+// prf is left out if nil
+func pbes2Blob(t testing.TB, prf, oid asn1.ObjectIdentifier, keySize int, iv, password, plaintext []byte) []byte {
+	t.Helper()
+	h := sha1.New
+	if prf.Equal(oidHMACWithSHA256) {
+		h = sha256.New
+	}
+	salt := bytes.Repeat([]byte{1}, 32)
+	k := sha1.Sum(saltedPassword(testGlobalSalt, password))
+	enc, encrypted := encryptScheme(t, oid, pbkdf2.Key(k[:], salt, 1, keySize, h), iv, plaintext)
+	kdf := pbkdf2Params{Salt: salt, Iterations: 1, KeyLength: keySize, PRF: algorithmIdentifier{Algorithm: prf}}
+	params := pbes2Params{KeyDerivationFunc: algorithm(t, oidPBKDF2, kdf), EncryptionScheme: enc}
+	return marshal(t, encryptedData{Algorithm: algorithm(t, oidPBES2, params), Encrypted: encrypted})
+}
+
+// pkcs12Blob encrypts plaintext like an entry of key4.db before Firefox 75
+func pkcs12Blob(t testing.TB, password, plaintext []byte) []byte {
+	t.Helper()
+	p := pkcs12PBE{oid: oidPBEWithSHA1And3DES}
+	p.params.EntrySalt = bytes.Repeat([]byte{4}, 20)
+	p.params.Iterations = 1
+	key, iv := p.key(testGlobalSalt, password)
+	encrypted := encryptCBC(t, cbcCipher(t, oidDESEDE3CBC, key), iv, plaintext)
+	return marshal(t, encryptedData{Algorithm: algorithm(t, p.oid, p.params), Encrypted: encrypted})
+}
+
+// loginBlob encrypts plaintext like a field of logins.json
+func loginBlob(t testing.TB, oid asn1.ObjectIdentifier, key, plaintext []byte) []byte {
+	t.Helper()
+	iv := bytes.Repeat([]byte{3}, cbcCipher(t, oid, key).BlockSize())
+	alg, encrypted := encryptScheme(t, oid, key, iv, plaintext)
+	return marshal(t, loginData{KeyID: bytes.Repeat([]byte{0xf8}, 16), Algorithm: alg, Encrypted: encrypted})
+}
+
+func TestPKCS7Unpad(t *testing.T) {
+	block := bytes.Repeat([]byte{'a'}, 12)
+	tests := []struct {
+		name string
+		src  []byte
+		want []byte
+		err  error
+	}{
+		{name: "valid", src: append(append([]byte{}, block...), 4, 4, 4, 4), want: block},
+		{name: "full block", src: bytes.Repeat([]byte{16}, 16), want: []byte{}},
+		{name: "zero", src: append(append([]byte{}, block...), 4, 4, 4, 0), err: ErrWrongKey},
+		{name: "larger than block", src: append(append([]byte{}, block...), 4, 4, 4, 17), err: ErrWrongKey},
+		{name: "inconsistent", src: append(append([]byte{}, block...), 4, 3, 4, 4), err: ErrWrongKey},
+		{name: "empty", src: nil, err: ErrCorruptBlob},
+		{name: "partial block", src: []byte{1, 1, 1}, err: ErrCorruptBlob},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pkcs7Unpad(tt.src, 16)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("pkcs7Unpad() error = %v, want %v", err, tt.err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("pkcs7Unpad() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChromiumErrors(t *testing.T) {
+	cbc := chromiumCBCBlob(t, testCBCKey, []byte("secret"))
+	gcm := chromiumGCMBlob(t, testGCMKey, []byte("secret"))
+	v11 := append([]byte(PrefixV11), cbc[prefixLen:]...)
+	wrongKey := []byte("fedcba9876543210")
+	tests := []struct {
+		name   string
+		keys   Keys
+		blob   []byte
+		err    error
+		status Status
+	}{
+		{name: "cbc", keys: NewKeys(testCBCKey), blob: cbc, status: StatusOK},
+		{name: "gcm", keys: NewKeys(testGCMKey), blob: gcm, status: StatusOK},
+		{name: "cbc wrong key", keys: NewKeys(wrongKey), blob: cbc, err: ErrWrongKey, status: StatusWrongKey},
+		{name: "gcm wrong key", keys: NewKeys([]byte("fedcba9876543210fedcba9876543210")), blob: gcm, err: ErrWrongKey, status: StatusWrongKey},
+		{name: "cbc partial block", keys: NewKeys(testCBCKey), blob: cbc[:len(cbc)-1], err: ErrCorruptBlob, status: StatusCorruptBlob},
+		{name: "cbc no ciphertext", keys: NewKeys(testCBCKey), blob: []byte(PrefixV10), err: ErrCorruptBlob, status: StatusCorruptBlob},
+		{name: "gcm no tag", keys: NewKeys(testGCMKey), blob: gcm[:prefixLen+gcmNonceSize+4], err: ErrCorruptBlob, status: StatusCorruptBlob},
+		{name: "gcm no nonce", keys: NewKeys(testGCMKey), blob: gcm[:prefixLen+4], err: ErrCorruptBlob, status: StatusCorruptBlob},
+		{name: "short", keys: NewKeys(testCBCKey), blob: []byte("v1"), err: ErrCorruptBlob, status: StatusCorruptBlob},
+		{name: "app bound", keys: NewKeys(testGCMKey), blob: append([]byte("v20"), gcm[prefixLen:]...), err: ErrUnsupportedPrefix, status: StatusUnsupportedPrefix},
+		{name: "v10 and v11 keys", keys: Keys{PrefixV10: wrongKey, PrefixV11: testCBCKey}, blob: v11, status: StatusOK},
+		{name: "no key of prefix", keys: Keys{PrefixV11: testCBCKey}, blob: cbc, err: ErrWrongKey, status: StatusWrongKey},
+		{name: "no keys", blob: cbc, err: ErrWrongKey, status: StatusWrongKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Chromium(tt.keys, tt.blob)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("Chromium() error = %v, want %v", err, tt.err)
+			}
+			if status := StatusOf(err); status != tt.status {
+				t.Errorf("StatusOf() = %s, want %s", status, tt.status)
+			}
+			if err == nil && string(got) != "secret" {
+				t.Errorf("Chromium() = %q, want secret", got)
+			}
+			if err != nil && got != nil {
+				t.Errorf("Chromium() = %q with error, want nil", got)
+			}
+		})
+	}
+}
+
+func TestDPAPI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("DPAPI blobs are decrypted on Windows")
+	}
+	got, err := DPAPI([]byte("\x01\x00\x00\x00\xd0\x8c\x9d\xdf"))
+	if !errors.Is(err, ErrUnsupportedPrefix) || got != nil {
+		t.Errorf("DPAPI() = %q, %v, want %v", got, err, ErrUnsupportedPrefix)
+	}
+	if status := StatusOf(err); status != StatusUnsupportedPrefix {
+		t.Errorf("StatusOf() = %s, want %s", status, StatusUnsupportedPrefix)
+	}
+}
+
+func TestFirefoxOSKeyStore(t *testing.T) {
+	blob := chromiumGCMBlob(t, testGCMKey, []byte("4000056655665556"))[prefixLen:]
+	tests := []struct {
+		name string
+		key  []byte
+		blob []byte
+		err  error
+	}{
+		{name: "ok", key: testGCMKey, blob: blob},
+		{name: "wrong key", key: []byte("fedcba9876543210fedcba9876543210"), blob: blob, err: ErrWrongKey},
+		{name: "short key", key: testCBCKey, blob: blob, err: ErrWrongKey},
+		{name: "no tag", key: testGCMKey, blob: blob[:gcmNonceSize+4], err: ErrCorruptBlob},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FirefoxOSKeyStore(tt.key, tt.blob)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("FirefoxOSKeyStore() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && string(got) != "4000056655665556" {
+				t.Errorf("FirefoxOSKeyStore() = %q, want 4000056655665556", got)
+			}
+		})
+	}
+}
+
+func TestASN1PBE(t *testing.T) {
+	password := []byte("hunter2")
+	nssIV := bytes.Repeat([]byte{2}, 14)
+	meta := pbes2Blob(t, oidHMACWithSHA256, oidAES256CBC, aes256KeySize, nssIV, password, []byte("password-check"))
+	testAESKey := append(append([]byte{}, test3DESKey...), "89abcdef"...)
+	login := loginBlob(t, oidDESEDE3CBC, test3DESKey, []byte("alice"))
+	aesLogin := loginBlob(t, oidAES256CBC, testAESKey, []byte("alice"))
+	tests := []struct {
+		name      string
+		blob      []byte
+		salt      []byte
+		password  []byte
+		want      string
+		algorithm string
+		err       error
+	}{
+		{name: "pbes2 aes256", blob: meta, salt: testGlobalSalt, password: password, want: "password-check", algorithm: "PBES2 PBKDF2 hmacWithSHA256 aes256-CBC"},
+		{name: "pbes2 wrong password", blob: meta, salt: testGlobalSalt, password: []byte("hunter3"), err: ErrWrongKey},
+		{
+			name:      "pbes2 default prf aes128",
+			blob:      pbes2Blob(t, nil, oidAES128CBC, aes128KeySize, bytes.Repeat([]byte{2}, 16), nil, []byte("password-check")),
+			salt:      testGlobalSalt,
+			want:      "password-check",
+			algorithm: "PBES2 PBKDF2 hmacWithSHA1 aes128-CBC",
+		},
+		{
+			name:      "pbes2 3des",
+			blob:      pbes2Blob(t, oidHMACWithSHA1, oidDESEDE3CBC, des3KeySize, bytes.Repeat([]byte{2}, 8), nil, []byte("key")),
+			salt:      testGlobalSalt,
+			want:      "key",
+			algorithm: "PBES2 PBKDF2 hmacWithSHA1 des-ede3-cbc",
+		},
+		{
+			name: "pbes2 key length mismatch",
+			blob: pbes2Blob(t, oidHMACWithSHA256, oidAES256CBC, aes128KeySize, nssIV, nil, []byte("password-check")),
+			salt: testGlobalSalt,
+			err:  ErrCorruptBlob,
+		},
+		{
+			name: "pbes2 unsupported prf",
+			blob: pbes2Blob(t, asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}, oidAES256CBC, aes256KeySize, nssIV, nil, nil),
+			err:  ErrUnsupportedOID,
+		},
+		{name: "pkcs12", blob: pkcs12Blob(t, password, []byte("key")), salt: testGlobalSalt, password: password, want: "key", algorithm: "pbeWithSha1AndTripleDES-CBC"},
+		{name: "pkcs12 wrong password", blob: pkcs12Blob(t, password, []byte("key")), salt: testGlobalSalt, err: ErrWrongKey},
+		{name: "login 3des", blob: login, salt: test3DESKey, want: "alice", algorithm: "des-ede3-cbc"},
+		{name: "login 3des long key", blob: login, salt: testAESKey, want: "alice"},
+		{name: "login wrong key", blob: login, salt: []byte("76543210fedcba9876543210"), err: ErrWrongKey},
+		{name: "login aes256", blob: aesLogin, salt: testAESKey, want: "alice", algorithm: "aes256-CBC"},
+		{name: "login aes256 short key", blob: aesLogin, salt: test3DESKey, err: ErrWrongKey},
+		{name: "login unsupported cipher", blob: loginBlob(t, oidAES192CBC, test3DESKey, []byte("alice")), err: ErrUnsupportedOID},
+		{
+			name: "unsupported algorithm",
+			blob: marshal(t, encryptedData{Algorithm: algorithm(t, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}, 1), Encrypted: []byte{1}}),
+			err:  ErrUnsupportedOID,
+		},
+		{name: "garbage", blob: []byte{0x30, 0x03, 0x02, 0x01}, err: ErrCorruptBlob},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pbe, err := NewASN1PBE(tt.blob)
+			if err == nil {
+				if tt.algorithm != "" && pbe.Params().Algorithm != tt.algorithm {
+					t.Errorf("Params().Algorithm = %q, want %q", pbe.Params().Algorithm, tt.algorithm)
+				}
+				var got []byte
+				got, err = pbe.Decrypt(tt.salt, tt.password)
+				if err == nil && string(got) != tt.want {
+					t.Errorf("Decrypt() = %q, want %q", got, tt.want)
+				}
+			}
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestUnsupportedOID(t *testing.T) {
+	_, err := NewASN1PBE(loginBlob(t, oidAES192CBC, test3DESKey, []byte("alice")))
+	if want := "unsupported OID 2.16.840.1.101.3.4.1.22"; err == nil || err.Error() != want {
+		t.Errorf("NewASN1PBE() error = %v, want %s", err, want)
+	}
+	if status := StatusOf(err); status != StatusUnsupportedAlgorithm {
+		t.Errorf("StatusOf() = %s, want %s", status, StatusUnsupportedAlgorithm)
+	}
+}
+
+// the fuzz tests only require the routines not to panic and to return
+// either plaintext or an error, run with go test -fuzz=FuzzName
+
+func FuzzNewASN1PBE(f *testing.F) {
+	f.Add(pbes2Blob(f, oidHMACWithSHA256, oidAES256CBC, aes256KeySize, bytes.Repeat([]byte{2}, 14), nil, []byte("password-check")))
+	f.Add(pbes2Blob(f, nil, oidDESEDE3CBC, des3KeySize, bytes.Repeat([]byte{2}, 8), nil, []byte("key")))
+	f.Add(pkcs12Blob(f, nil, []byte("key")))
+	f.Add(loginBlob(f, oidDESEDE3CBC, test3DESKey, []byte("alice")))
+	f.Add(loginBlob(f, oidAES256CBC, testGCMKey, []byte("alice")))
+	f.Add([]byte{0x30, 0x00})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		pbe, err := NewASN1PBE(b)
+		if err != nil {
+			return
+		}
+		pbe.Params()
+		for _, salt := range [][]byte{testGlobalSalt, test3DESKey, testGCMKey} {
+			if got, err := pbe.Decrypt(salt, nil); err != nil && got != nil {
+				t.Errorf("Decrypt() = %q with error %v", got, err)
+			}
+		}
+	})
+}
+
+func FuzzChromium(f *testing.F) {
+	f.Add(testCBCKey, chromiumCBCBlob(f, testCBCKey, []byte("secret")))
+	f.Add(testGCMKey, chromiumGCMBlob(f, testGCMKey, []byte("secret")))
+	f.Add(testCBCKey, []byte("v11"))
+	f.Fuzz(func(t *testing.T, key, blob []byte) {
+		for _, keys := range []Keys{NewKeys(key), {PrefixV10: key}} {
+			got, err := Chromium(keys, blob)
+			if err != nil && got != nil {
+				t.Errorf("Chromium() = %q with error %v", got, err)
+			}
+			if err == nil && len(got) >= len(blob) {
+				t.Errorf("Chromium() = %d bytes of a %d bytes blob", len(got), len(blob))
+			}
+		}
+	})
+}
+
+func FuzzCBCDecrypt(f *testing.F) {
+	block, err := aes.NewCipher(testCBCKey)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(testCBCKey, chromiumIV, encryptCBC(f, block, chromiumIV, []byte("secret")))
+	f.Add(test3DESKey, chromiumIV[:des.BlockSize], []byte("12345678"))
+	f.Fuzz(func(t *testing.T, key, iv, src []byte) {
+		if got, err := aes128CBCDecrypt(key, iv, src); err == nil && len(got) >= len(src) {
+			t.Errorf("aes128CBCDecrypt() = %d bytes of %d", len(got), len(src))
+		}
+		if got, err := des3Decrypt(key, iv, src); err == nil && len(got) >= len(src) {
+			t.Errorf("des3Decrypt() = %d bytes of %d", len(got), len(src))
+		}
+	})
+}
+
+func FuzzAESGCMDecrypt(f *testing.F) {
+	blob := chromiumGCMBlob(f, testGCMKey, []byte("secret"))
+	f.Add(testGCMKey, blob[prefixLen:prefixLen+gcmNonceSize], blob[prefixLen+gcmNonceSize:])
+	f.Add(testGCMKey, []byte{}, []byte{})
+	f.Fuzz(func(t *testing.T, key, nonce, src []byte) {
+		if got, err := aesGCMDecrypt(src, key, nonce); err != nil && got != nil {
+			t.Errorf("aesGCMDecrypt() = %q with error %v", got, err)
+		}
+	})
+}
+
+func FuzzPKCS7Unpad(f *testing.F) {
+	f.Add(pad([]byte("secret"), aes.BlockSize))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, src []byte) {
+		got, err := pkcs7Unpad(src, aes.BlockSize)
+		if err == nil && (len(src)-len(got) < 1 || len(src)-len(got) > aes.BlockSize || !bytes.HasPrefix(src, got)) {
+			t.Errorf("pkcs7Unpad() = %q of %q", got, src)
+		}
+	})
+}
@@ -3,23 +3,27 @@
 package decrypter
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"fmt"
 	"syscall"
 	"unsafe"
 )
 
-func Chromium(key, encryptPass []byte) ([]byte, error) {
-	if len(encryptPass) < 3 {
-		return nil, errPasswordIsEmpty
+// Chromium decrypts a v10 or v11 blob with the key of its prefix
+func Chromium(keys Keys, encryptPass []byte) ([]byte, error) {
+	key, err := chromiumBlobKey(keys, encryptPass)
+	if err != nil {
+		return nil, err
 	}
-
-	return aesGCMDecrypt(encryptPass[15:], key, encryptPass[3:15])
+	// 16 bytes key comes from a macOS or Linux profile analysed offline
+	if len(key) == aes128KeySize {
+		return chromiumCBCDecrypt(key, encryptPass)
+	}
+	return chromiumGCMDecrypt(key, encryptPass)
 }
 
 func ChromiumForYandex(key, encryptPass []byte) ([]byte, error) {
-	if len(encryptPass) < 3 {
-		return nil, errPasswordIsEmpty
+	if len(encryptPass) < gcmNonceSize+gcmTagSize {
+		return nil, fmt.Errorf("%w: blob of %d bytes", ErrCorruptBlob, len(encryptPass))
 	}
 	// remove Prefix 'v10'
 	// gcmBlockSize         = 16
@@ -29,23 +33,6 @@ func ChromiumForYandex(key, encryptPass []byte) ([]byte, error) {
 	return aesGCMDecrypt(encryptPass[12:], key, encryptPass[0:12])
 }
 
-// chromium > 80 https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_win.cc
-func aesGCMDecrypt(crypted, key, nounce []byte) ([]byte, error) {
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
-	blockMode, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-	origData, err := blockMode.Open(nil, nounce, crypted, nil)
-	if err != nil {
-		return nil, err
-	}
-	return origData, nil
-}
-
 type dataBlob struct {
 	cbData uint32
 	pbData *byte
@@ -0,0 +1,55 @@
+package decrypter
+
+import (
+	"fmt"
+)
+
+// prefixes of chromium blobs, Linux encrypts v10 blobs with the key of
+// the hardcoded "peanuts" secret and v11 blobs with the key of the keyring
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/sync/os_crypt_linux.cc
+const (
+	PrefixV10 = "v10"
+	PrefixV11 = "v11"
+
+	prefixLen = 3
+)
+
+// AnyPrefix is the prefix of a key opening blobs of every prefix, it's the
+// single key of chromium on Windows and macOS and of firefox
+const AnyPrefix = ""
+
+// OSKeyStore is the key firefox keeps in the OS key store, its blobs have
+// no prefix and it's never the key of AnyPrefix
+const OSKeyStore = "oskeystore"
+
+// Keys are the master keys of a profile by the prefix of the blobs they
+// open, a Linux profile mixing v10 and v11 blobs is decrypted fully this way.
+type Keys map[string][]byte
+
+// NewKeys returns the Keys of a single key opening blobs of every prefix,
+// it's nil if key is empty
+func NewKeys(key []byte) Keys {
+	if len(key) == 0 {
+		return nil
+	}
+	return Keys{AnyPrefix: key}
+}
+
+// Key returns the key of blobs of prefix, or the key of AnyPrefix if
+// there's none of prefix
+func (k Keys) Key(prefix string) []byte {
+	if key, ok := k[prefix]; ok {
+		return key
+	}
+	return k[AnyPrefix]
+}
+
+// blobKey returns the key of blob by its prefix
+func (k Keys) blobKey(blob []byte) ([]byte, error) {
+	prefix := string(blob[:prefixLen])
+	key := k.Key(prefix)
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%w: no key for %q blobs", ErrWrongKey, prefix)
+	}
+	return key, nil
+}
@@ -0,0 +1,366 @@
+package decrypter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrUnsupportedOID means an algorithm of a firefox blob is unknown
+var ErrUnsupportedOID = errors.New("unsupported OID")
+
+type ASN1PBE interface {
+	Decrypt(globalSalt, masterPwd []byte) (key []byte, err error)
+	// Params describes how the data is protected without decrypting it
+	Params() PBEParams
+}
+
+// PBEParams is the protection scheme of a key4.db entry
+type PBEParams struct {
+	Algorithm  string
+	OID        string
+	Iterations int
+	KeySize    int
+	// Legacy is true for PKCS#12 SHA1 and 3DES used before Firefox 75
+	Legacy bool
+}
+
+var (
+	oidPBEWithSHA1And3DES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 5, 1, 3}
+	oidPBES2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1       = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256     = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES256CBC          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC         = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// oidNames contains OIDs found in key4.db and logins.json
+var oidNames = map[string]string{
+	oidPBEWithSHA1And3DES.String(): "pbeWithSha1AndTripleDES-CBC",
+	oidPBES2.String():              "PBES2",
+	oidPBKDF2.String():             "PBKDF2",
+	oidHMACWithSHA1.String():       "hmacWithSHA1",
+	oidHMACWithSHA256.String():     "hmacWithSHA256",
+	oidAES128CBC.String():          "aes128-CBC",
+	oidAES256CBC.String():          "aes256-CBC",
+	oidDESEDE3CBC.String():         "des-ede3-cbc",
+}
+
+func oidName(oid asn1.ObjectIdentifier) string {
+	if name, ok := oidNames[oid.String()]; ok {
+		return name
+	}
+	return oid.String()
+}
+
+func unsupportedOID(oid asn1.ObjectIdentifier) error {
+	return fmt.Errorf("%w %s", ErrUnsupportedOID, oid)
+}
+
+// algorithmIdentifier is the AlgorithmIdentifier of RFC 5280, the
+// parameters are decoded once the algorithm is known
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+// encryptedData is an entry of key4.db
+//
+//	SEQUENCE (2 elem)
+//		AlgorithmIdentifier
+//		OCTET STRING
+type encryptedData struct {
+	Algorithm algorithmIdentifier
+	Encrypted []byte
+}
+
+// loginData is an encrypted username or password of logins.json
+//
+//	SEQUENCE (3 elem)
+//		OCTET STRING (16 byte) key id
+//		AlgorithmIdentifier
+//		OCTET STRING
+type loginData struct {
+	KeyID     []byte
+	Algorithm algorithmIdentifier
+	Encrypted []byte
+}
+
+// NewASN1PBE decodes b by the OID of its algorithm, key4.db entries use
+// PKCS#12 SHA1 and 3DES or PBES2, logins are encrypted with a cipher only.
+func NewASN1PBE(b []byte) (pbe ASN1PBE, err error) {
+	var (
+		e encryptedData
+		l loginData
+	)
+	if _, err := asn1.Unmarshal(b, &e); err == nil {
+		return newPBE(e.Algorithm, e.Encrypted)
+	}
+	if _, err := asn1.Unmarshal(b, &l); err == nil {
+		return newPBE(l.Algorithm, l.Encrypted)
+	}
+	return nil, errDecodeASN1Failed
+}
+
+func newPBE(alg algorithmIdentifier, encrypted []byte) (ASN1PBE, error) {
+	switch {
+	case alg.Algorithm.Equal(oidPBEWithSHA1And3DES):
+		return newPKCS12PBE(alg, encrypted)
+	case alg.Algorithm.Equal(oidPBES2):
+		return newPBES2(alg, encrypted)
+	default:
+		c, err := newCBCScheme(alg)
+		if err != nil {
+			return nil, err
+		}
+		return cipherPBE{cipher: c, encrypted: encrypted}, nil
+	}
+}
+
+// unmarshalParams decodes the parameters of alg into v
+func unmarshalParams(alg algorithmIdentifier, v any) error {
+	rest, err := asn1.Unmarshal(alg.Parameters.FullBytes, v)
+	if err != nil || len(rest) > 0 {
+		return fmt.Errorf("%w: parameters of %s", errDecodeASN1Failed, oidName(alg.Algorithm))
+	}
+	return nil
+}
+
+// cbcScheme is a block cipher in CBC mode with its IV
+type cbcScheme struct {
+	oid       asn1.ObjectIdentifier
+	keySize   int
+	newCipher func(key []byte) (cipher.Block, error)
+	iv        []byte
+}
+
+func newCBCScheme(alg algorithmIdentifier) (*cbcScheme, error) {
+	c := &cbcScheme{oid: alg.Algorithm}
+	blockSize := aes.BlockSize
+	switch {
+	case alg.Algorithm.Equal(oidAES128CBC):
+		c.keySize, c.newCipher = aes128KeySize, aes.NewCipher
+	case alg.Algorithm.Equal(oidAES256CBC):
+		c.keySize, c.newCipher = aes256KeySize, aes.NewCipher
+	case alg.Algorithm.Equal(oidDESEDE3CBC):
+		c.keySize, c.newCipher, blockSize = des3KeySize, des.NewTripleDESCipher, des.BlockSize
+	default:
+		return nil, unsupportedOID(alg.Algorithm)
+	}
+	if err := unmarshalParams(alg, &c.iv); err != nil {
+		return nil, err
+	}
+	// NSS keeps 14 bytes of the AES IV in key4.db, the DER header of the
+	// octet string makes up the first two bytes of it
+	if len(c.iv) != blockSize && len(alg.Parameters.FullBytes) == blockSize {
+		c.iv = alg.Parameters.FullBytes
+	}
+	return c, nil
+}
+
+func (c *cbcScheme) decrypt(key, src []byte) ([]byte, error) {
+	if len(key) != c.keySize {
+		return nil, fmt.Errorf("%w: %d byte key for %s", ErrWrongKey, len(key), oidName(c.oid))
+	}
+	block, err := c.newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cbcDecrypt(block, c.iv, src)
+}
+
+// pkcs12PBE is pbeWithSha1AndTripleDES-CBC used by key4.db before Firefox 75
+//
+//	SEQUENCE (2 elem)
+//		OCTET STRING (20 byte)
+//		INTEGER 1
+type pkcs12PBE struct {
+	oid    asn1.ObjectIdentifier
+	params struct {
+		EntrySalt  []byte
+		Iterations int
+	}
+	encrypted []byte
+}
+
+func newPKCS12PBE(alg algorithmIdentifier, encrypted []byte) (ASN1PBE, error) {
+	p := pkcs12PBE{oid: alg.Algorithm, encrypted: encrypted}
+	if err := unmarshalParams(alg, &p.params); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewPKCS12PBE returns the pbeWithSha1AndTripleDES-CBC entry of entrySalt,
+// key3.db keeps the password-check without its AlgorithmIdentifier.
+func NewPKCS12PBE(entrySalt, encrypted []byte) ASN1PBE {
+	p := pkcs12PBE{oid: oidPBEWithSHA1And3DES, encrypted: encrypted}
+	p.params.EntrySalt = entrySalt
+	p.params.Iterations = 1
+	return p
+}
+
+func (p pkcs12PBE) Decrypt(globalSalt, masterPwd []byte) (key []byte, err error) {
+	k, iv := p.key(globalSalt, masterPwd)
+	return des3Decrypt(k, iv, p.encrypted)
+}
+
+// key derives the 3DES key and IV as NSS does, the iterations are ignored
+func (p pkcs12PBE) key(globalSalt, masterPwd []byte) (key, iv []byte) {
+	entrySalt := p.params.EntrySalt
+	hp := sha1.Sum(saltedPassword(globalSalt, masterPwd))
+	s := append(hp[:], entrySalt...)
+	chp := sha1.Sum(s)
+	pes := paddingZero(append([]byte{}, entrySalt...), 20)
+	tk := hmac.New(sha1.New, chp[:])
+	tk.Write(pes)
+	pes = append(pes, entrySalt...)
+	k1 := hmac.New(sha1.New, chp[:])
+	k1.Write(pes)
+	tkPlus := append(tk.Sum(nil), entrySalt...)
+	k2 := hmac.New(sha1.New, chp[:])
+	k2.Write(tkPlus)
+	k := append(k1.Sum(nil), k2.Sum(nil)...)
+	return k[:des3KeySize], k[len(k)-8:]
+}
+
+func (p pkcs12PBE) Params() PBEParams {
+	return PBEParams{
+		Algorithm:  oidName(p.oid),
+		OID:        p.oid.String(),
+		Iterations: p.params.Iterations,
+		KeySize:    des3KeySize,
+		Legacy:     true,
+	}
+}
+
+// pbes2 is PBES2 of RFC 8018 used by key4.db since Firefox 75
+//
+//	SEQUENCE (2 elem)
+//		AlgorithmIdentifier PBKDF2
+//			SEQUENCE
+//				OCTET STRING salt
+//				INTEGER iterations
+//				INTEGER key length OPTIONAL
+//				AlgorithmIdentifier prf DEFAULT hmacWithSHA1
+//		AlgorithmIdentifier cipher
+type pbes2 struct {
+	kdf        asn1.ObjectIdentifier
+	prf        asn1.ObjectIdentifier
+	hash       func() hash.Hash
+	salt       []byte
+	iterations int
+	keyLength  int
+	cipher     *cbcScheme
+	encrypted  []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                 `asn1:"optional"`
+	PRF        algorithmIdentifier `asn1:"optional"`
+}
+
+func newPBES2(alg algorithmIdentifier, encrypted []byte) (ASN1PBE, error) {
+	var params pbes2Params
+	if err := unmarshalParams(alg, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, unsupportedOID(params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if err := unmarshalParams(params.KeyDerivationFunc, &kdf); err != nil {
+		return nil, err
+	}
+	p := pbes2{
+		kdf:        params.KeyDerivationFunc.Algorithm,
+		prf:        kdf.PRF.Algorithm,
+		salt:       kdf.Salt,
+		iterations: kdf.Iterations,
+		keyLength:  kdf.KeyLength,
+		encrypted:  encrypted,
+	}
+	switch {
+	case len(p.prf) == 0:
+		p.prf, p.hash = oidHMACWithSHA1, sha1.New
+	case p.prf.Equal(oidHMACWithSHA1):
+		p.hash = sha1.New
+	case p.prf.Equal(oidHMACWithSHA256):
+		p.hash = sha256.New
+	default:
+		return nil, unsupportedOID(p.prf)
+	}
+	c, err := newCBCScheme(params.EncryptionScheme)
+	if err != nil {
+		return nil, err
+	}
+	p.cipher = c
+	return p, nil
+}
+
+// maxIterations bounds the PBKDF2 iterations of key4.db, firefox uses 10000
+const maxIterations = 1 << 20
+
+func (p pbes2) Decrypt(globalSalt, masterPwd []byte) (key []byte, err error) {
+	if p.iterations < 1 || p.iterations > maxIterations {
+		return nil, fmt.Errorf("%w: %d PBKDF2 iterations", ErrCorruptBlob, p.iterations)
+	}
+	if p.keyLength != 0 && p.keyLength != p.cipher.keySize {
+		return nil, fmt.Errorf("%w: PBKDF2 key length %d for %s", ErrCorruptBlob, p.keyLength, oidName(p.cipher.oid))
+	}
+	k := sha1.Sum(saltedPassword(globalSalt, masterPwd))
+	return p.cipher.decrypt(pbkdf2.Key(k[:], p.salt, p.iterations, p.cipher.keySize, p.hash), p.encrypted)
+}
+
+func (p pbes2) Params() PBEParams {
+	return PBEParams{
+		Algorithm:  strings.Join([]string{oidName(oidPBES2), oidName(p.kdf), oidName(p.prf), oidName(p.cipher.oid)}, " "),
+		OID:        oidPBES2.String(),
+		Iterations: p.iterations,
+		KeySize:    p.cipher.keySize,
+	}
+}
+
+// cipherPBE is a login of logins.json, encrypted with the key of key4.db
+// by 3DES or, in newer Firefox, AES-256
+type cipherPBE struct {
+	cipher    *cbcScheme
+	encrypted []byte
+}
+
+// Decrypt decrypts the login with key, which is passed as globalSalt.
+// The key of key4.db may be longer than the cipher needs, 3DES logins
+// take the first 24 bytes of it.
+func (c cipherPBE) Decrypt(key, _ []byte) ([]byte, error) {
+	if len(key) > c.cipher.keySize {
+		key = key[:c.cipher.keySize]
+	}
+	return c.cipher.decrypt(key, c.encrypted)
+}
+
+func (c cipherPBE) Params() PBEParams {
+	return PBEParams{
+		Algorithm: oidName(c.cipher.oid),
+		OID:       c.cipher.oid.String(),
+		KeySize:   c.cipher.keySize,
+	}
+}
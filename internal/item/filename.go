@@ -2,25 +2,36 @@ package item
 
 // item's default filename
 const (
-	fileChromiumKey          = "Local State"
-	fileChromiumCredit       = "Web Data"
-	fileChromiumPassword     = "Login Data"
-	fileChromiumHistory      = "History"
-	fileChromiumDownload     = "History"
-	fileChromiumCookie       = "Cookies"
-	fileChromiumBookmark     = "Bookmarks"
-	fileChromiumLocalStorage = "Local Storage/leveldb"
-	fileChromiumExtension    = "Extensions"
+	fileChromiumKey            = "Local State"
+	fileChromiumCredit         = "Web Data"
+	fileChromiumAddress        = "Web Data"
+	fileChromiumAutofill       = "Web Data"
+	fileChromiumPassword       = "Login Data"
+	fileChromiumHistory        = "History"
+	fileChromiumDownload       = "History"
+	fileChromiumCookie         = "Cookies"
+	fileChromiumBookmark       = "Bookmarks"
+	fileChromiumLocalStorage   = "Local Storage/leveldb"
+	fileChromiumSessionStorage = "Session Storage"
+	fileChromiumIndexedDB      = "IndexedDB"
+	fileChromiumExtension      = "Extensions"
+	fileChromiumSession        = "Sessions"
 
 	fileYandexPassword = "Ya Passman Data"
 	fileYandexCredit   = "Ya Credit Cards"
 
 	fileFirefoxKey4         = "key4.db"
+	fileFirefoxKey3         = "key3.db"
+	fileFirefoxSignons      = "signons.sqlite"
 	fileFirefoxCookie       = "cookies.sqlite"
 	fileFirefoxPassword     = "logins.json"
 	fileFirefoxData         = "places.sqlite"
-	fileFirefoxLocalStorage = "webappsstore.sqlite"
+	fileFirefoxStorage      = "storage/default"
+	fileFirefoxWebappsStore = "webappsstore.sqlite"
 	fileFirefoxExtension    = "extensions.json"
+	fileFirefoxAutofill     = "autofill-profiles.json"
+	fileFirefoxSession      = "sessionstore-backups"
+	fileFirefoxFormHistory  = "formhistory.sqlite"
 )
 
 const (
@@ -28,27 +39,44 @@ const (
 	UnsupportedItem = "unsupported item"
 )
 
+// item's name, it's also the filename of the item copied into the private
+// workspace of a run, so it's unique among items of a browser
 const (
-	TempChromiumKey          = "chromiumKey"
-	TempChromiumPassword     = "password"
-	TempChromiumCookie       = "cookie"
-	TempChromiumBookmark     = "bookmark"
-	TempChromiumHistory      = "history"
-	TempChromiumDownload     = "download"
-	TempChromiumCreditCard   = "creditCard"
-	TempChromiumLocalStorage = "localStorage"
-	TempChromiumExtension    = "extension"
+	nameChromiumKey            = "chromiumKey"
+	nameChromiumPassword       = "password"
+	nameChromiumCookie         = "cookie"
+	nameChromiumBookmark       = "bookmark"
+	nameChromiumHistory        = "history"
+	nameChromiumDownload       = "download"
+	nameChromiumCreditCard     = "creditCard"
+	nameChromiumAddress        = "address"
+	nameChromiumAutofill       = "autofill"
+	nameChromiumSession        = "session"
+	nameChromiumSearchTerm     = "searchTerm"
+	nameChromiumLocalStorage   = "localStorage"
+	nameChromiumSessionStorage = "sessionStorage"
+	nameChromiumIndexedDB      = "indexedDB"
+	nameChromiumExtension      = "extension"
 
-	TempYandexPassword   = "yandexPassword"
-	TempYandexCreditCard = "yandexCreditCard"
+	nameYandexPassword   = "yandexPassword"
+	nameYandexCreditCard = "yandexCreditCard"
 
-	TempFirefoxKey4         = "firefoxKey4"
-	TempFirefoxPassword     = "firefoxPassword"
-	TempFirefoxCookie       = "firefoxCookie"
-	TempFirefoxBookmark     = "firefoxBookmark"
-	TempFirefoxHistory      = "firefoxHistory"
-	TempFirefoxDownload     = "firefoxDownload"
-	TempFirefoxLocalStorage = "firefoxLocalStorage"
-	TempFirefoxCreditCard   = ""
-	TempFirefoxExtension    = "firefoxExtension"
+	nameFirefoxKey4         = "firefoxKey4"
+	nameFirefoxKey3         = "firefoxKey3"
+	nameFirefoxSignons      = "firefoxSignons"
+	nameFirefoxPassword     = "firefoxPassword"
+	nameFirefoxCookie       = "firefoxCookie"
+	nameFirefoxBookmark     = "firefoxBookmark"
+	nameFirefoxHistory      = "firefoxHistory"
+	nameFirefoxDownload     = "firefoxDownload"
+	nameFirefoxLocalStorage = "firefoxLocalStorage"
+	nameFirefoxCreditCard   = "firefoxCreditCard"
+	nameFirefoxAddress      = "firefoxAddress"
+	nameFirefoxSession      = "firefoxSession"
+	nameFirefoxFormHistory  = "firefoxFormHistory"
+	nameFirefoxSearchTerm   = "firefoxSearchTerm"
+	nameFirefoxExtension    = "firefoxExtension"
+	nameFirefoxPosture      = "firefoxPosture"
+	nameFirefoxWebappsStore = "firefoxWebappsStore"
+	nameFirefoxIndexedDB    = "firefoxIndexedDB"
 )
@@ -25,6 +25,27 @@ const (
 	FirefoxCreditCard
 	FirefoxLocalStorage
 	FirefoxExtension
+	FirefoxPosture
+	// FirefoxKey3 and FirefoxSignons are the key and logins of Firefox 57
+	// and earlier, FirefoxPassword is read from signons.sqlite if the
+	// profile has no logins.json
+	FirefoxKey3
+	FirefoxSignons
+
+	ChromiumAddress
+	FirefoxAddress
+	ChromiumAutofill
+	ChromiumSession
+	FirefoxSession
+	FirefoxFormHistory
+	ChromiumSearchTerm
+	FirefoxSearchTerm
+	ChromiumSessionStorage
+	ChromiumIndexedDB
+	// FirefoxWebappsStore is the local storage of Firefox 91 and earlier,
+	// FirefoxLocalStorage reads it besides storage/default
+	FirefoxWebappsStore
+	FirefoxIndexedDB
 )
 
 func (i Item) FileName() string {
@@ -62,13 +83,43 @@ func (i Item) FileName() string {
 	case FirefoxDownload:
 		return fileFirefoxData
 	case FirefoxLocalStorage:
-		return fileFirefoxLocalStorage
+		return fileFirefoxStorage
 	case FirefoxHistory:
 		return fileFirefoxData
 	case FirefoxExtension:
 		return fileFirefoxExtension
+	case FirefoxPosture:
+		return fileFirefoxKey4
+	case FirefoxKey3:
+		return fileFirefoxKey3
+	case FirefoxSignons:
+		return fileFirefoxSignons
 	case FirefoxCreditCard:
-		return UnsupportedItem
+		return fileFirefoxAutofill
+	case ChromiumAddress:
+		return fileChromiumAddress
+	case FirefoxAddress:
+		return fileFirefoxAutofill
+	case ChromiumAutofill:
+		return fileChromiumAutofill
+	case ChromiumSession:
+		return fileChromiumSession
+	case FirefoxSession:
+		return fileFirefoxSession
+	case FirefoxFormHistory:
+		return fileFirefoxFormHistory
+	case ChromiumSearchTerm:
+		return fileChromiumHistory
+	case FirefoxSearchTerm:
+		return fileFirefoxData
+	case ChromiumSessionStorage:
+		return fileChromiumSessionStorage
+	case ChromiumIndexedDB:
+		return fileChromiumIndexedDB
+	case FirefoxWebappsStore:
+		return fileFirefoxWebappsStore
+	case FirefoxIndexedDB:
+		return fileFirefoxStorage
 	default:
 		return UnknownItem
 	}
@@ -77,45 +128,75 @@ func (i Item) FileName() string {
 func (i Item) String() string {
 	switch i {
 	case ChromiumKey:
-		return TempChromiumKey
+		return nameChromiumKey
 	case ChromiumPassword:
-		return TempChromiumPassword
+		return nameChromiumPassword
 	case ChromiumCookie:
-		return TempChromiumCookie
+		return nameChromiumCookie
 	case ChromiumBookmark:
-		return TempChromiumBookmark
+		return nameChromiumBookmark
 	case ChromiumDownload:
-		return TempChromiumDownload
+		return nameChromiumDownload
 	case ChromiumLocalStorage:
-		return TempChromiumLocalStorage
+		return nameChromiumLocalStorage
 	case ChromiumCreditCard:
-		return TempChromiumCreditCard
+		return nameChromiumCreditCard
 	case ChromiumExtension:
-		return TempChromiumExtension
+		return nameChromiumExtension
 	case ChromiumHistory:
-		return TempChromiumHistory
+		return nameChromiumHistory
 	case YandexPassword:
-		return TempYandexPassword
+		return nameYandexPassword
 	case YandexCreditCard:
-		return TempYandexCreditCard
+		return nameYandexCreditCard
 	case FirefoxKey4:
-		return TempFirefoxKey4
+		return nameFirefoxKey4
 	case FirefoxPassword:
-		return TempFirefoxPassword
+		return nameFirefoxPassword
 	case FirefoxCookie:
-		return TempFirefoxCookie
+		return nameFirefoxCookie
 	case FirefoxBookmark:
-		return TempFirefoxBookmark
+		return nameFirefoxBookmark
 	case FirefoxDownload:
-		return TempFirefoxDownload
+		return nameFirefoxDownload
 	case FirefoxHistory:
-		return TempFirefoxHistory
+		return nameFirefoxHistory
 	case FirefoxLocalStorage:
-		return TempFirefoxLocalStorage
+		return nameFirefoxLocalStorage
 	case FirefoxCreditCard:
-		return UnsupportedItem
+		return nameFirefoxCreditCard
 	case FirefoxExtension:
-		return TempFirefoxExtension
+		return nameFirefoxExtension
+	case FirefoxPosture:
+		return nameFirefoxPosture
+	case FirefoxKey3:
+		return nameFirefoxKey3
+	case FirefoxSignons:
+		return nameFirefoxSignons
+	case ChromiumAddress:
+		return nameChromiumAddress
+	case FirefoxAddress:
+		return nameFirefoxAddress
+	case ChromiumAutofill:
+		return nameChromiumAutofill
+	case ChromiumSession:
+		return nameChromiumSession
+	case FirefoxSession:
+		return nameFirefoxSession
+	case FirefoxFormHistory:
+		return nameFirefoxFormHistory
+	case ChromiumSearchTerm:
+		return nameChromiumSearchTerm
+	case FirefoxSearchTerm:
+		return nameFirefoxSearchTerm
+	case ChromiumSessionStorage:
+		return nameChromiumSessionStorage
+	case ChromiumIndexedDB:
+		return nameChromiumIndexedDB
+	case FirefoxWebappsStore:
+		return nameFirefoxWebappsStore
+	case FirefoxIndexedDB:
+		return nameFirefoxIndexedDB
 	default:
 		return UnknownItem
 	}
@@ -123,14 +204,23 @@ func (i Item) String() string {
 
 var DefaultFirefox = []Item{
 	FirefoxKey4,
+	FirefoxKey3,
 	FirefoxPassword,
+	FirefoxSignons,
 	FirefoxCookie,
 	FirefoxBookmark,
 	FirefoxHistory,
+	FirefoxSearchTerm,
 	FirefoxDownload,
+	FirefoxFormHistory,
 	FirefoxCreditCard,
+	FirefoxAddress,
 	FirefoxLocalStorage,
+	FirefoxWebappsStore,
+	FirefoxIndexedDB,
 	FirefoxExtension,
+	FirefoxPosture,
+	FirefoxSession,
 }
 
 var DefaultYandex = []Item{
@@ -151,8 +241,14 @@ var DefaultChromium = []Item{
 	ChromiumCookie,
 	ChromiumBookmark,
 	ChromiumHistory,
+	ChromiumSearchTerm,
 	ChromiumDownload,
 	ChromiumCreditCard,
+	ChromiumAddress,
+	ChromiumAutofill,
 	ChromiumLocalStorage,
+	ChromiumSessionStorage,
+	ChromiumIndexedDB,
 	ChromiumExtension,
+	ChromiumSession,
 }
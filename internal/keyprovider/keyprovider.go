@@ -0,0 +1,294 @@
+// Package keyprovider supplies the keys of browser profiles. Keys come from
+// a chain of providers, like the OS keystore, key material given by the user
+// or an interactive prompt, the first provider having the key wins.
+package keyprovider
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/masterkey"
+)
+
+// Engine is the browser engine a key is asked for
+type Engine string
+
+const (
+	Chromium Engine = "chromium"
+	Firefox  Engine = "firefox"
+	// FirefoxOSKeyStore is the key firefox keeps in the OS key store, it's
+	// the key of decrypter.OSKeyStore
+	FirefoxOSKeyStore Engine = "firefox-oskeystore"
+)
+
+// Target is the browser profile a key is asked for
+type Target struct {
+	Engine Engine
+	// Browser is the name of the browser profile
+	Browser string
+	// Storage is the name chromium keeps its secret under in the OS
+	// keystore, like "Chrome Safe Storage", or the one of the key of
+	// FirefoxOSKeyStore. It's empty for copied profiles.
+	Storage string
+	// LocalState is the path of chromium's Local State, it holds the
+	// encrypted key on Windows
+	LocalState string
+}
+
+// Provider supplies keys, the master keys handed to decrypter.Chromium for
+// chromium and the primary password of key4.db for firefox, which is the
+// key of decrypter.AnyPrefix.
+type Provider interface {
+	Name() string
+	// Key returns the keys of t, ErrNoKey if the provider has none for it
+	Key(t Target) (decrypter.Keys, error)
+}
+
+// ErrNoKey means no provider has a key of the target
+var ErrNoKey = errors.New("no key")
+
+// names of the providers, in the order of DefaultProviders
+const (
+	NameStatic   = "static"
+	NameFile     = "file"
+	NameEnv      = "env"
+	NameKeystore = "keystore"
+	NameBasic    = "basic"
+	NamePrompt   = "prompt"
+)
+
+// DefaultProviders are tried if Config has none, the key material of the
+// user goes before the OS keystore. The prompt is never used unless asked.
+var DefaultProviders = []string{NameStatic, NameFile, NameEnv, NameKeystore, NameBasic}
+
+var errOfflineKeystore = errors.New("keystore provider is not available offline")
+
+// Chain is a list of providers tried in order
+type Chain []Provider
+
+// Key returns the first key of t accepted by check and the name of its
+// provider, check may be nil to accept any key. ErrNoKey is returned if
+// no provider has a key, other errors if some key was rejected or failed.
+func (c Chain) Key(t Target, check func(keys decrypter.Keys) error) (decrypter.Keys, string, error) {
+	var errs []string
+	for _, p := range c {
+		key, err := p.Key(t)
+		if err == nil && check != nil {
+			err = check(key)
+		}
+		if err == nil {
+			return key, p.Name(), nil
+		}
+		log.Debugf("%s key of %s error: %s", t.Browser, p.Name(), err)
+		if !errors.Is(err, ErrNoKey) {
+			errs = append(errs, fmt.Sprintf("%s: %s", p.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, "", fmt.Errorf("key of %s failed, %s", t.Browser, strings.Join(errs, "; "))
+	}
+	return nil, "", fmt.Errorf("%w of %s from %s", ErrNoKey, t.Browser, strings.Join(c.Names(), ", "))
+}
+
+// Names returns the names of the providers in order
+func (c Chain) Names() []string {
+	names := make([]string, 0, len(c))
+	for _, p := range c {
+		names = append(names, p.Name())
+	}
+	return names
+}
+
+// Config selects and configures the providers of New
+type Config struct {
+	// Providers are the names of providers in the order they're tried,
+	// DefaultProviders if empty
+	Providers []string
+	// Keys is the key material given by the user, read by the static provider
+	Keys *masterkey.Material
+	// KeyFile is the key material json read by the file provider
+	KeyFile string
+	// Offline leaves the OS keystore out of the default chain, the profiles
+	// come from another machine, asking for it is an error
+	Offline bool
+}
+
+// New returns the chain of cfg, key files and environment variables are read here.
+func New(cfg Config) (Chain, error) {
+	names := cfg.Providers
+	if len(names) == 0 {
+		for _, name := range DefaultProviders {
+			if name != NameKeystore || !cfg.Offline {
+				names = append(names, name)
+			}
+		}
+	}
+	keys := cfg.Keys
+	if keys == nil {
+		keys = &masterkey.Material{}
+	}
+	var fileKeys *masterkey.Material
+	if cfg.KeyFile != "" {
+		var err error
+		if fileKeys, err = masterkey.LoadFile(cfg.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	// the password store and profile os of the key file apply to the other providers
+	passwordStore, profileOS := keys.PasswordStore, keys.ProfileOS
+	if fileKeys != nil {
+		if passwordStore == "" {
+			passwordStore = fileKeys.PasswordStore
+		}
+		if profileOS == "" {
+			profileOS = fileKeys.ProfileOS
+		}
+		fileKeys.ProfileOS = profileOS
+	}
+	static := *keys
+	static.ProfileOS = profileOS
+	chain := make(Chain, 0, len(names))
+	for _, name := range names {
+		var (
+			p   Provider
+			err error
+		)
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+			continue
+		case NameStatic:
+			p = Static(&static)
+		case NameFile:
+			if fileKeys == nil {
+				log.Debugf("no key file given, %s provider is skipped", NameFile)
+				continue
+			}
+			p = &material{name: NameFile, keys: fileKeys}
+		case NameEnv:
+			p, err = Env(profileOS)
+		case NameKeystore:
+			if cfg.Offline {
+				return nil, errOfflineKeystore
+			}
+			p, err = Keystore(passwordStore)
+		case NameBasic:
+			p = Basic(profileOS)
+		case NamePrompt:
+			p = Prompt(profileOS)
+		default:
+			return nil, fmt.Errorf("unsupported key provider %s, available: %s|%s|%s|%s|%s|%s",
+				name, NameStatic, NameFile, NameEnv, NameKeystore, NameBasic, NamePrompt)
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, p)
+	}
+	return chain, nil
+}
+
+// material supplies keys from key material
+type material struct {
+	name string
+	keys *masterkey.Material
+}
+
+// Static returns the provider of key material given by the user
+func Static(keys *masterkey.Material) Provider {
+	return &material{name: NameStatic, keys: keys}
+}
+
+// environment variables read by the env provider, the chromium key is hex encoded
+const (
+	EnvChromiumKey     = "HBD_CHROMIUM_KEY"
+	EnvSafeStorage     = "HBD_SAFE_STORAGE"
+	EnvFirefoxPassword = "HBD_FIREFOX_PASSWORD"
+	// EnvFirefoxOSKey is base64 encoded like the secret of the OS key store
+	EnvFirefoxOSKey = "HBD_FIREFOX_OS_KEY"
+)
+
+// Env returns the provider of key material in environment variables, the
+// safe storage secret is derived as a secret of profileOS.
+func Env(profileOS string) (Provider, error) {
+	keys := &masterkey.Material{
+		SafeStorage:     []byte(os.Getenv(EnvSafeStorage)),
+		ProfileOS:       profileOS,
+		FirefoxPassword: []byte(os.Getenv(EnvFirefoxPassword)),
+	}
+	if err := keys.SetChromiumKey(os.Getenv(EnvChromiumKey)); err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvChromiumKey, err)
+	}
+	if err := keys.SetFirefoxOSKey(os.Getenv(EnvFirefoxOSKey)); err != nil {
+		return nil, fmt.Errorf("%s: %w", EnvFirefoxOSKey, err)
+	}
+	return &material{name: NameEnv, keys: keys}, nil
+}
+
+func (m *material) Name() string {
+	return m.name
+}
+
+func (m *material) Key(t Target) (decrypter.Keys, error) {
+	switch t.Engine {
+	case Firefox:
+		if len(m.keys.FirefoxPassword) == 0 {
+			return nil, ErrNoKey
+		}
+		return decrypter.NewKeys(m.keys.FirefoxPassword), nil
+	case FirefoxOSKeyStore:
+		return osKeyStoreKeys(m.keys.FirefoxOSKey)
+	}
+	if len(m.keys.ChromiumKey) == 0 && len(m.keys.SafeStorage) == 0 {
+		return nil, ErrNoKey
+	}
+	return m.keys.ChromiumMasterKey()
+}
+
+// basic supplies the key of the hardcoded secret chromium uses on Linux
+// without a keyring, only v10 blobs are encrypted with it.
+type basic struct {
+	profileOS string
+}
+
+// Basic returns the provider of the hardcoded key of Linux profiles, it
+// has no key for profiles of other OSes.
+func Basic(profileOS string) Provider {
+	if profileOS == "" {
+		profileOS = runtime.GOOS
+	}
+	return &basic{profileOS: profileOS}
+}
+
+func (b *basic) Name() string {
+	return NameBasic
+}
+
+func (b *basic) Key(t Target) (decrypter.Keys, error) {
+	if t.Engine != Chromium || b.profileOS != "linux" {
+		return nil, ErrNoKey
+	}
+	return masterkey.LinuxKeys(nil), nil
+}
+
+// osKeyStoreKeys returns the Keys of the firefox OS key store key, ErrNoKey
+// if key is empty
+func osKeyStoreKeys(key []byte) (decrypter.Keys, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	return decrypter.Keys{decrypter.OSKeyStore: key}, nil
+}
+
+// firefoxOSKey decodes the base64 secret firefox keeps in the OS key store
+func firefoxOSKey(secret []byte) (decrypter.Keys, error) {
+	keys := &masterkey.Material{}
+	if err := keys.SetFirefoxOSKey(string(secret)); err != nil {
+		return nil, err
+	}
+	return osKeyStoreKeys(keys.FirefoxOSKey)
+}
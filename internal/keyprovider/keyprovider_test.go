@@ -0,0 +1,131 @@
+package keyprovider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/masterkey"
+)
+
+func TestNew(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keyFile, []byte(`{"firefox_password": "from file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvFirefoxPassword, "from env")
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		names    []string
+		password string
+		provider string
+		err      bool
+	}{
+		{
+			name:     "default offline",
+			cfg:      Config{KeyFile: keyFile, Offline: true},
+			names:    []string{NameStatic, NameFile, NameEnv, NameBasic},
+			password: "from file",
+			provider: NameFile,
+		},
+		{
+			name:     "static first",
+			cfg:      Config{Keys: &masterkey.Material{FirefoxPassword: []byte("from flag")}, KeyFile: keyFile, Offline: true},
+			names:    []string{NameStatic, NameFile, NameEnv, NameBasic},
+			password: "from flag",
+			provider: NameStatic,
+		},
+		{
+			name:     "env only",
+			cfg:      Config{Providers: []string{"env"}, KeyFile: keyFile},
+			names:    []string{NameEnv},
+			password: "from env",
+			provider: NameEnv,
+		},
+		{
+			name:  "file without key file",
+			cfg:   Config{Providers: []string{"file", "basic"}},
+			names: []string{NameBasic},
+		},
+		{name: "keystore offline", cfg: Config{Providers: []string{"keystore"}, Offline: true}, err: true},
+		{name: "unsupported", cfg: Config{Providers: []string{"vault"}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := New(tt.cfg)
+			if (err != nil) != tt.err {
+				t.Fatalf("New() error = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(chain.Names(), tt.names) {
+				t.Errorf("Names() = %v, want %v", chain.Names(), tt.names)
+			}
+			keys, provider, err := chain.Key(Target{Engine: Firefox, Browser: "firefox"}, nil)
+			if tt.password == "" {
+				if !errors.Is(err, ErrNoKey) {
+					t.Errorf("Key() error = %v, want %v", err, ErrNoKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if password := keys.Key(decrypter.AnyPrefix); string(password) != tt.password || provider != tt.provider {
+				t.Errorf("Key() = %s from %s, want %s from %s", password, provider, tt.password, tt.provider)
+			}
+		})
+	}
+}
+
+func TestChainKeyCheck(t *testing.T) {
+	chain := Chain{
+		Static(&masterkey.Material{FirefoxPassword: []byte("wrong")}),
+		Static(&masterkey.Material{FirefoxPassword: []byte("right")}),
+	}
+	check := func(keys decrypter.Keys) error {
+		if string(keys.Key(decrypter.AnyPrefix)) != "right" {
+			return errors.New("wrong password")
+		}
+		return nil
+	}
+	keys, _, err := chain.Key(Target{Engine: Firefox}, check)
+	if key := keys.Key(decrypter.AnyPrefix); err != nil || string(key) != "right" {
+		t.Errorf("Key() = %s, %v, want right", key, err)
+	}
+	_, _, err = chain[:1].Key(Target{Engine: Firefox}, check)
+	if err == nil || errors.Is(err, ErrNoKey) {
+		t.Errorf("Key() error = %v, want the error of check", err)
+	}
+}
+
+func TestFirefoxOSKey(t *testing.T) {
+	key := []byte("fedcba9876543210fedcba9876543210")
+	t.Setenv(EnvFirefoxOSKey, base64.StdEncoding.EncodeToString(key))
+	target := Target{Engine: FirefoxOSKeyStore, Browser: "firefox"}
+	env, err := Env("linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chain := range []Chain{{Static(&masterkey.Material{FirefoxOSKey: key})}, {env}} {
+		keys, provider, err := chain.Key(target, nil)
+		if err != nil || !bytes.Equal(keys[decrypter.OSKeyStore], key) {
+			t.Errorf("Key() from %s = %v, %v, want the OS key", provider, keys, err)
+		}
+		if keys.Key(decrypter.AnyPrefix) != nil {
+			t.Errorf("Key() from %s has a login key, want only the OS key", provider)
+		}
+	}
+	_, _, err = Chain{Static(&masterkey.Material{FirefoxPassword: []byte("hunter2")})}.Key(target, nil)
+	if !errors.Is(err, ErrNoKey) {
+		t.Errorf("Key() error = %v, want %v", err, ErrNoKey)
+	}
+}
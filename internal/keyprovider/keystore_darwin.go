@@ -0,0 +1,90 @@
+//go:build darwin
+
+package keyprovider
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+)
+
+var (
+	errWrongSecurityCommand   = errors.New("wrong security command")
+	errCouldNotFindInKeychain = errors.New("could not be find in keychain")
+)
+
+// keystore supplies the key derived from the secret in the keychain
+type keystore struct{}
+
+// Keystore returns the provider of the OS keystore, passwordStore is
+// only used on Linux.
+func Keystore(passwordStore string) (Provider, error) {
+	return &keystore{}, nil
+}
+
+func (k *keystore) Name() string {
+	return NameKeystore
+}
+
+func (k *keystore) Key(t Target) (decrypter.Keys, error) {
+	if t.Storage == "" {
+		return nil, ErrNoKey
+	}
+	switch t.Engine {
+	case Chromium:
+	case FirefoxOSKeyStore:
+		// firefox keeps its key as the service of a generic password
+		// $ security find-generic-password -ws 'Firefox Encrypted Storage'
+		secret, err := findGenericPassword("-ws", t.Storage)
+		if err != nil {
+			return nil, err
+		}
+		return firefoxOSKey(secret)
+	default:
+		return nil, ErrNoKey
+	}
+	// Get the master key from the keychain
+	// $ security find-generic-password -wa 'Chrome'
+	chromeSecret, err := findGenericPassword("-wa", t.Storage)
+	if err != nil {
+		return nil, err
+	}
+	chromeSalt := []byte("saltysalt")
+	// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_mac.mm;l=157
+	key := pbkdf2.Key(chromeSecret, chromeSalt, 1003, 16, sha1.New)
+	if key == nil {
+		return nil, errWrongSecurityCommand
+	}
+	log.Infof("%s initialized master key success", t.Browser)
+	return decrypter.NewKeys(key), nil
+}
+
+// findGenericPassword returns the password of the keychain item named
+// storage, flag tells if storage is its account (-wa) or service (-ws)
+func findGenericPassword(flag, storage string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", flag, strings.TrimSpace(storage)) //nolint:gosec
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	if stderr.Len() > 0 {
+		if strings.Contains(stderr.String(), "could not be found") {
+			return nil, errCouldNotFindInKeychain
+		}
+		return nil, errors.New(stderr.String())
+	}
+	secret := bytes.TrimSpace(stdout.Bytes())
+	if len(secret) == 0 {
+		return nil, errWrongSecurityCommand
+	}
+	return secret, nil
+}
@@ -0,0 +1,210 @@
+//go:build linux
+
+package keyprovider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	keyring "github.com/ppacher/go-dbus-keyring"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/masterkey"
+)
+
+// keystore supplies the keys of v10 and v11 blobs, the secret of v11 is
+// looked up in the password stores, v10 always uses the hardcoded one.
+type keystore struct {
+	sources []secretSource
+	bus     func() (*dbus.Conn, error)
+}
+
+// Keystore returns the provider of the OS keystore, passwordStore picks
+// the stores the secret is looked up in like chromium's --password-store.
+func Keystore(passwordStore string) (Provider, error) {
+	sources, err := secretSources(passwordStore, dbus.SessionBus)
+	if err != nil {
+		return nil, err
+	}
+	return &keystore{sources: sources, bus: dbus.SessionBus}, nil
+}
+
+func (k *keystore) Name() string {
+	return NameKeystore
+}
+
+func (k *keystore) Key(t Target) (decrypter.Keys, error) {
+	if t.Storage == "" {
+		return nil, ErrNoKey
+	}
+	switch t.Engine {
+	case Chromium:
+	case FirefoxOSKeyStore:
+		// firefox keeps its key in the secret service only, labelled by storage
+		secret, err := secretService{k.bus}.Secret(t.Storage)
+		if err != nil {
+			return nil, err
+		}
+		if len(secret) == 0 {
+			return nil, ErrNoKey
+		}
+		return firefoxOSKey(secret)
+	default:
+		return nil, ErrNoKey
+	}
+	// without a secret the next provider is tried, basic has the key of v10 blobs
+	secret, source := findSecret(k.sources, t.Storage)
+	if secret == nil {
+		log.Warnf("%s no secret of %s found in the password stores", t.Browser, t.Storage)
+		return nil, ErrNoKey
+	}
+	log.Infof("%s secret of %s found in %s", t.Browser, t.Storage, source)
+	return masterkey.LinuxKeys(secret), nil
+}
+
+// secretSource is a store chromium may keep its safe storage secret in, the
+// key of v11 blobs is derived from the secret.
+type secretSource interface {
+	Name() string
+	// Secret returns the secret named storage, nil if the store doesn't have it
+	Secret(storage string) ([]byte, error)
+}
+
+var errPasswordStore = errors.New("unsupported password store, available: basic|gnome-libsecret|kwallet5|kwallet6")
+
+// secretSources returns the sources tried for store, like the --password-store
+// switch of chromium. The basic store has no secret, only its hardcoded key
+// of v10 blobs is used.
+func secretSources(store string, bus func() (*dbus.Conn, error)) ([]secretSource, error) {
+	switch strings.ToLower(store) {
+	case "", "auto":
+		return []secretSource{secretService{bus}, kwallet{bus, 6}, kwallet{bus, 5}}, nil
+	case "basic":
+		return nil, nil
+	case "gnome", "gnome-libsecret", "gnome-keyring":
+		return []secretSource{secretService{bus}}, nil
+	case "kwallet", "kwallet5":
+		return []secretSource{kwallet{bus, 5}}, nil
+	case "kwallet6":
+		return []secretSource{kwallet{bus, 6}}, nil
+	default:
+		return nil, errPasswordStore
+	}
+}
+
+// findSecret returns the first secret found by sources and the name of
+// its source, unavailable stores are skipped.
+func findSecret(sources []secretSource, storage string) ([]byte, string) {
+	for _, s := range sources {
+		secret, err := s.Secret(storage)
+		if err != nil {
+			log.Debugf("%s of %s error: %s", storage, s.Name(), err)
+			continue
+		}
+		if len(secret) > 0 {
+			return secret, s.Name()
+		}
+	}
+	return nil, ""
+}
+
+// secretService is the freedesktop Secret Service of gnome-keyring and
+// the like, what is d-bus @https://dbus.freedesktop.org/
+type secretService struct {
+	bus func() (*dbus.Conn, error)
+}
+
+func (s secretService) Name() string {
+	return "gnome-libsecret"
+}
+
+func (s secretService) Secret(storage string) ([]byte, error) {
+	conn, err := s.bus()
+	if err != nil {
+		return nil, err
+	}
+	svc, err := keyring.GetSecretService(conn)
+	if err != nil {
+		return nil, err
+	}
+	session, err := svc.OpenSession()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := session.Close(); err != nil {
+			log.Errorf("close session failed: %v", err)
+		}
+	}()
+	collections, err := svc.GetAllCollections()
+	if err != nil {
+		return nil, err
+	}
+	for _, col := range collections {
+		items, err := col.GetAllItems()
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range items {
+			label, err := i.GetLabel()
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			if label == storage {
+				se, err := i.GetSecret(session.Path())
+				if err != nil {
+					return nil, errors.New("get storage from dbus error:" + err.Error())
+				}
+				return se.Value, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// kwallet is kwalletd of KDE, chromium keeps the secret in the folder
+// "Chrome Keys" of the network wallet for "Chrome Safe Storage"
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/sync/key_storage_kwallet.cc
+type kwallet struct {
+	bus     func() (*dbus.Conn, error)
+	version int
+}
+
+const (
+	kwalletInterface = "org.kde.KWallet"
+	kwalletAppID     = "hack-browser-data"
+)
+
+func (k kwallet) Name() string {
+	return fmt.Sprintf("kwallet%d", k.version)
+}
+
+func (k kwallet) Secret(storage string) ([]byte, error) {
+	conn, err := k.bus()
+	if err != nil {
+		return nil, err
+	}
+	obj := conn.Object(fmt.Sprintf("org.kde.kwalletd%d", k.version), dbus.ObjectPath(fmt.Sprintf("/modules/kwalletd%d", k.version)))
+	var wallet string
+	if err := obj.Call(kwalletInterface+".networkWallet", 0).Store(&wallet); err != nil {
+		return nil, err
+	}
+	var handle int32
+	if err := obj.Call(kwalletInterface+".open", 0, wallet, int64(0), kwalletAppID).Store(&handle); err != nil {
+		return nil, err
+	}
+	if handle < 0 {
+		return nil, fmt.Errorf("open wallet %s failed", wallet)
+	}
+	defer obj.Call(kwalletInterface+".close", 0, handle, false, kwalletAppID)
+	folder := strings.Replace(storage, "Safe Storage", "Keys", 1)
+	var password string
+	if err := obj.Call(kwalletInterface+".readPassword", 0, handle, folder, storage, kwalletAppID).Store(&password); err != nil {
+		return nil, err
+	}
+	return []byte(password), nil
+}
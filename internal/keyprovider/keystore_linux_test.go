@@ -0,0 +1,212 @@
+//go:build linux
+
+package keyprovider
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"database/sql"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/crypto/pbkdf2"
+
+	"hack-browser-data/internal/browingdata/password"
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/masterkey"
+	"hack-browser-data/internal/testfixture"
+)
+
+const busConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:dir=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*"/>
+    <allow receive_sender="*"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>`
+
+// privateBus starts a dbus-daemon only this test talks to and returns its address
+func privateBus(t *testing.T) string {
+	t.Helper()
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+	dir := t.TempDir()
+	config := filepath.Join(dir, "bus.conf")
+	if err := os.WriteFile(config, []byte(strings.Replace(busConfig, "%s", dir, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(daemon, "--config-file="+config, "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// fakeKWallet serves org.kde.KWallet with a single password
+type fakeKWallet struct {
+	folder, key, password string
+}
+
+func (f *fakeKWallet) NetworkWallet() (string, *dbus.Error) {
+	return "kdewallet", nil
+}
+
+func (f *fakeKWallet) Open(wallet string, wID int64, appID string) (int32, *dbus.Error) {
+	return 7, nil
+}
+
+func (f *fakeKWallet) ReadPassword(handle int32, folder, key, appID string) (string, *dbus.Error) {
+	if handle != 7 || folder != f.folder || key != f.key {
+		return "", nil
+	}
+	return f.password, nil
+}
+
+func (f *fakeKWallet) Close(handle int32, force bool, appID string) (int32, *dbus.Error) {
+	return 0, nil
+}
+
+func serveKWallet(t *testing.T, address string, wallet *fakeKWallet) {
+	t.Helper()
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	methods := map[string]string{
+		"NetworkWallet": "networkWallet",
+		"Open":          "open",
+		"ReadPassword":  "readPassword",
+		"Close":         "close",
+	}
+	if err := conn.ExportWithMap(wallet, methods, "/modules/kwalletd6", kwalletInterface); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName("org.kde.kwalletd6", dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("request name: %v %v", reply, err)
+	}
+}
+
+// loginData writes a Login Data with a v10 blob of the hardcoded key and
+// a v11 blob of the key derived from secret
+func loginData(t *testing.T, secret string) string {
+	t.Helper()
+	v10, err := testfixture.EncryptChromium(testfixture.ChromiumLinuxKey, []byte("from v10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v11, err := testfixture.EncryptChromium(pbkdf2.Key([]byte(secret), []byte("saltysalt"), 1, 16, sha1.New), []byte("from v11"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(v11, decrypter.PrefixV11)
+	path := filepath.Join(t.TempDir(), "Login Data")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE logins (origin_url VARCHAR, username_value VARCHAR, password_value BLOB, date_created INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO logins VALUES ('https://a.com/', 'v10', ?, 13317102245000000), ('https://b.com/', 'v11', ?, 13317102246000000)`, v10, v11); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeystorePasswordStores(t *testing.T) {
+	address := privateBus(t)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", address)
+	serveKWallet(t, address, &fakeKWallet{folder: "Chrome Keys", key: "Chrome Safe Storage", password: "kde-secret"})
+	path := loginData(t, "kde-secret")
+
+	tests := []struct {
+		name     string
+		store    string
+		secret   string
+		provider string
+		want     map[string]string
+	}{
+		{name: "kwallet6", store: "kwallet6", provider: NameKeystore, want: map[string]string{"v10": "from v10", "v11": "from v11"}},
+		{name: "user secret", store: "basic", secret: "kde-secret", provider: NameStatic, want: map[string]string{"v10": "from v10", "v11": "from v11"}},
+		{name: "basic", store: "basic", provider: NameBasic, want: map[string]string{"v10": "from v10", "v11": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keystore, err := Keystore(tt.store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chain := Chain{Static(&masterkey.Material{SafeStorage: []byte(tt.secret), ProfileOS: "linux"}), keystore, Basic("linux")}
+			target := Target{Engine: Chromium, Browser: "chrome", Storage: "Chrome Safe Storage"}
+			key, provider, err := chain.Key(target, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if provider != tt.provider {
+				t.Errorf("key provider = %s, want %s", provider, tt.provider)
+			}
+			var logins password.ChromiumPassword
+			err = logins.Parse(key, path)
+			if tt.want["v11"] == "" && !errors.Is(err, decrypter.ErrDecryptFailed) {
+				t.Errorf("Parse() error = %v, want %v", err, decrypter.ErrDecryptFailed)
+			} else if tt.want["v11"] != "" && err != nil {
+				t.Fatal(err)
+			}
+			for _, l := range logins {
+				if l.Password != tt.want[l.UserName] {
+					t.Errorf("password of %s = %q, want %q", l.UserName, l.Password, tt.want[l.UserName])
+				}
+			}
+		})
+	}
+}
+
+// emptySource is a password store without the secret
+type emptySource struct{}
+
+func (emptySource) Name() string {
+	return "empty"
+}
+
+func (emptySource) Secret(string) ([]byte, error) {
+	return nil, nil
+}
+
+func TestKeystoreWithoutSecret(t *testing.T) {
+	chain := Chain{&keystore{sources: []secretSource{emptySource{}}}, Basic("linux")}
+	target := Target{Engine: Chromium, Browser: "chrome", Storage: "Chrome Safe Storage"}
+	_, provider, err := chain.Key(target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider != NameBasic {
+		t.Errorf("key provider = %s, want %s", provider, NameBasic)
+	}
+}
@@ -0,0 +1,110 @@
+//go:build windows
+
+package keyprovider
+
+import (
+	"encoding/base64"
+	"errors"
+	"syscall"
+	"unsafe"
+
+	"github.com/tidwall/gjson"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/utils/fileutil"
+)
+
+var errDecodeMasterKeyFailed = errors.New("decode master key failed")
+
+// keystore supplies the key of Local State protected by DPAPI
+type keystore struct{}
+
+// Keystore returns the provider of the OS keystore, passwordStore is
+// only used on Linux.
+func Keystore(passwordStore string) (Provider, error) {
+	return &keystore{}, nil
+}
+
+func (k *keystore) Name() string {
+	return NameKeystore
+}
+
+func (k *keystore) Key(t Target) (decrypter.Keys, error) {
+	if t.Engine == FirefoxOSKeyStore && t.Storage != "" {
+		secret, err := credRead(t.Storage)
+		if err != nil {
+			return nil, err
+		}
+		return firefoxOSKey(secret)
+	}
+	if t.Engine != Chromium || t.LocalState == "" {
+		return nil, ErrNoKey
+	}
+	keyFile, err := fileutil.ReadFile(t.LocalState)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey := gjson.Get(keyFile, "os_crypt.encrypted_key")
+	if !encryptedKey.Exists() {
+		return nil, ErrNoKey
+	}
+	pureKey, err := base64.StdEncoding.DecodeString(encryptedKey.String())
+	if err != nil || len(pureKey) < 5 {
+		return nil, errDecodeMasterKeyFailed
+	}
+	key, err := decrypter.DPAPI(pureKey[5:])
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("%s initialized master key success", t.Browser)
+	return decrypter.NewKeys(key), nil
+}
+
+// credential is the CREDENTIALW of the credential manager
+type credential struct {
+	flags              uint32
+	credType           uint32
+	targetName         *uint16
+	comment            *uint16
+	lastWritten        syscall.Filetime
+	credentialBlobSize uint32
+	credentialBlob     *byte
+	persist            uint32
+	attributeCount     uint32
+	attributes         uintptr
+	targetAlias        *uint16
+	userName           *uint16
+}
+
+const credTypeGeneric = 1
+
+// credRead returns the blob of the generic credential target of the
+// credential manager, firefox keeps the key of its OS key store there
+// @https://searchfox.org/mozilla-central/source/security/manager/ssl/CredentialManagerSecret.cpp
+func credRead(target string) ([]byte, error) {
+	dll := syscall.NewLazyDLL("Advapi32.dll")
+	procCredRead := dll.NewProc("CredReadW")
+	procCredFree := dll.NewProc("CredFree")
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, syscall.Errno(errorNotFound)) {
+			return nil, ErrNoKey
+		}
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	blob := make([]byte, cred.credentialBlobSize)
+	if cred.credentialBlobSize > 0 {
+		copy(blob, unsafe.Slice(cred.credentialBlob, cred.credentialBlobSize))
+	}
+	return blob, nil
+}
+
+// errorNotFound is the ERROR_NOT_FOUND of CredReadW without the credential
+const errorNotFound = 1168
@@ -0,0 +1,86 @@
+package keyprovider
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/masterkey"
+)
+
+// prompt asks the user for the chromium safe storage secret or the firefox
+// primary password, answers are kept so a browser is asked only once.
+type prompt struct {
+	profileOS string
+
+	mu      sync.Mutex
+	answers map[string][]byte
+}
+
+// Prompt returns the provider asking on the terminal, the safe storage
+// secret is derived as a secret of profileOS.
+func Prompt(profileOS string) Provider {
+	return &prompt{profileOS: profileOS, answers: make(map[string][]byte)}
+}
+
+func (p *prompt) Name() string {
+	return NamePrompt
+}
+
+func (p *prompt) Key(t Target) (decrypter.Keys, error) {
+	// the key of the OS key store is random, there is no secret to ask for
+	if t.Engine == FirefoxOSKeyStore {
+		return nil, ErrNoKey
+	}
+	// profiles of a chromium browser share the secret, firefox profiles don't
+	question := fmt.Sprintf("primary password of %s", t.Browser)
+	if t.Engine == Chromium {
+		question = fmt.Sprintf("safe storage secret of %s", t.Browser)
+		if t.Storage != "" {
+			question = fmt.Sprintf("secret of %s", t.Storage)
+		}
+	}
+	p.mu.Lock()
+	answer, ok := p.answers[question]
+	if !ok {
+		var err error
+		if answer, err = ask(question); err != nil {
+			p.mu.Unlock()
+			return nil, err
+		}
+		p.answers[question] = answer
+	}
+	p.mu.Unlock()
+	if len(answer) == 0 {
+		return nil, ErrNoKey
+	}
+	if t.Engine == Firefox {
+		return decrypter.NewKeys(answer), nil
+	}
+	keys := &masterkey.Material{SafeStorage: answer, ProfileOS: p.profileOS}
+	return keys.ChromiumMasterKey()
+}
+
+// stdin is shared by the questions, piped answers are read line by line
+var stdin = bufio.NewReader(os.Stdin)
+
+// ask reads the answer from stdin, without echo if it's a terminal
+func ask(question string) ([]byte, error) {
+	fmt.Fprintf(os.Stderr, "%s (empty to skip): ", question)
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		answer, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return answer, err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return nil, ErrNoKey
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
@@ -2,12 +2,17 @@ package log
 
 import (
 	"os"
+	"sync"
 
 	"github.com/gookit/color"
 	"github.com/gookit/slog"
 )
 
-var std = &slog.SugaredLogger{}
+// mu serializes logging, slog formatter reuses its buffer once a record is formatted
+var mu sync.Mutex
+
+// std logs at notice level until Init is called, so the library can log without the CLI
+var std = newStdLogger(slog.NoticeLevel)
 
 func Init(l string) {
 	if l == "debug" {
@@ -33,51 +38,71 @@ func newStdLogger(level slog.Level) *slog.SugaredLogger {
 
 // Trace logs a message at level Trace
 func Trace(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.TraceLevel, args...)
 }
 
 // Tracef logs a message at level Trace
 func Tracef(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.TraceLevel, format, args...)
 }
 
 // Info logs a message at level Info
 func Info(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.InfoLevel, args...)
 }
 
 // Infof logs a message at level Info
 func Infof(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.InfoLevel, format, args...)
 }
 
 // Notice logs a message at level Notice
 func Notice(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.NoticeLevel, args...)
 }
 
 // Noticef logs a message at level Notice
 func Noticef(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.NoticeLevel, format, args...)
 }
 
 // Warn logs a message at level Warn
 func Warn(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.WarnLevel, args...)
 }
 
 // Warnf logs a message at level Warn
 func Warnf(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.WarnLevel, format, args...)
 }
 
 // Error logs a message at level Error
 func Error(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.ErrorLevel, args...)
 }
 
 // ErrorT logs a error type at level Error
 func ErrorT(err error) {
+	mu.Lock()
+	defer mu.Unlock()
 	if err != nil {
 		std.Log(slog.ErrorLevel, err)
 	}
@@ -85,35 +110,49 @@ func ErrorT(err error) {
 
 // Errorf logs a message at level Error
 func Errorf(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.ErrorLevel, format, args...)
 }
 
 // Debug logs a message at level Debug
 func Debug(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.DebugLevel, args...)
 }
 
 // Debugf logs a message at level Debug
 func Debugf(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.DebugLevel, format, args...)
 }
 
 // Fatal logs a message at level Fatal
 func Fatal(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.FatalLevel, args...)
 }
 
 // Fatalf logs a message at level Fatal
 func Fatalf(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.FatalLevel, format, args...)
 }
 
 // Panic logs a message at level Panic
 func Panic(args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Log(slog.PanicLevel, args...)
 }
 
 // Panicf logs a message at level Panic
 func Panicf(format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
 	std.Logf(slog.PanicLevel, format, args...)
 }
@@ -0,0 +1,165 @@
+package masterkey
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"golang.org/x/crypto/pbkdf2"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/utils/fileutil"
+)
+
+var (
+	errNoChromiumKey      = errors.New("no chromium key or safe storage secret supplied")
+	errChromiumKeyLength  = errors.New("chromium key must be 16 (macOS/Linux) or 32 (Windows) bytes")
+	errFirefoxOSKeyLength = errors.New("firefox os key must be 32 bytes")
+	errUnsupportedProfile = errors.New("unsupported profile os, available: windows|darwin|linux")
+)
+
+// Material is the key material supplied by the user, read by the static,
+// file and env key providers. Nothing here ever talks to D-Bus, the
+// keychain or DPAPI, so it works for profiles copied from another machine.
+type Material struct {
+	// ChromiumKey is the raw AES key of chromium, 16 bytes on macOS and Linux,
+	// 32 bytes on Windows (the DPAPI decrypted os_crypt.encrypted_key). On
+	// Linux it's the key of v11 blobs, v10 blobs use the hardcoded key.
+	ChromiumKey []byte
+	// SafeStorage is the secret stored in the keychain or secret service
+	// as "Chrome Safe Storage" and the like, the key is derived from it.
+	SafeStorage []byte
+	// ProfileOS is the OS the profiles were copied from, it selects how the
+	// chromium key is derived from SafeStorage.
+	ProfileOS string
+	// PasswordStore is the store chromium keeps its secret in on Linux, like
+	// its --password-store switch: basic, gnome-libsecret, kwallet5 or
+	// kwallet6, every store is tried if it's empty.
+	PasswordStore string
+	// FirefoxPassword is the primary password of firefox profiles.
+	FirefoxPassword []byte
+	// FirefoxOSKey is the key firefox keeps in the OS key store as
+	// "Firefox Encrypted Storage", it encrypts credit card numbers.
+	FirefoxOSKey []byte
+}
+
+// LoadFile reads key material from a JSON file, hex encoded chromium_key,
+// safe_storage, profile_os, password_store, firefox_password and base64
+// encoded firefox_os_key are all optional.
+func LoadFile(filename string) (*Material, error) {
+	s, err := fileutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if !gjson.Valid(s) {
+		return nil, fmt.Errorf("key file %s is not valid json", filename)
+	}
+	m := &Material{}
+	j := gjson.Parse(s)
+	if err := m.SetChromiumKey(j.Get("chromium_key").String()); err != nil {
+		return nil, err
+	}
+	if v := j.Get("safe_storage"); v.Exists() {
+		m.SafeStorage = []byte(v.String())
+	}
+	if v := j.Get("firefox_password"); v.Exists() {
+		m.FirefoxPassword = []byte(v.String())
+	}
+	if err := m.SetFirefoxOSKey(j.Get("firefox_os_key").String()); err != nil {
+		return nil, err
+	}
+	m.ProfileOS = j.Get("profile_os").String()
+	m.PasswordStore = j.Get("password_store").String()
+	return m, nil
+}
+
+// SetChromiumKey sets the raw chromium key from its hex encoding,
+// an empty string leaves the key unchanged.
+func (m *Material) SetChromiumKey(s string) error {
+	if s == "" {
+		return nil
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("decode chromium key error: %w", err)
+	}
+	if len(key) != 16 && len(key) != 32 {
+		return errChromiumKeyLength
+	}
+	m.ChromiumKey = key
+	return nil
+}
+
+// SetFirefoxOSKey sets FirefoxOSKey from the base64 secret of the OS key
+// store, an empty string is ignored
+func (m *Material) SetFirefoxOSKey(s string) error {
+	if s == "" {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("decode firefox os key error: %w", err)
+	}
+	if len(key) != 32 {
+		return errFirefoxOSKeyLength
+	}
+	m.FirefoxOSKey = key
+	return nil
+}
+
+// ChromiumMasterKey returns the keys used by decrypter.Chromium for the copied profiles.
+func (m *Material) ChromiumMasterKey() (decrypter.Keys, error) {
+	profileOS := m.ProfileOS
+	if profileOS == "" {
+		profileOS = runtime.GOOS
+	}
+	if len(m.ChromiumKey) > 0 {
+		if profileOS == "linux" {
+			// only v11 blobs are encrypted with the key of the keyring
+			keys := LinuxKeys(nil)
+			keys[decrypter.PrefixV11] = m.ChromiumKey
+			return keys, nil
+		}
+		return decrypter.NewKeys(m.ChromiumKey), nil
+	}
+	salt := []byte("saltysalt")
+	switch profileOS {
+	case "darwin":
+		if len(m.SafeStorage) == 0 {
+			return nil, errNoChromiumKey
+		}
+		// @https://source.chromium.org/chromium/chromium/src/+/master:components/os_crypt/os_crypt_mac.mm;l=157
+		return decrypter.NewKeys(pbkdf2.Key(m.SafeStorage, salt, 1003, 16, sha1.New)), nil
+	case "linux":
+		return LinuxKeys(m.SafeStorage), nil
+	case "windows":
+		// the key of windows is protected by DPAPI of the original user,
+		// it can't be derived from a secret
+		return nil, errNoChromiumKey
+	default:
+		return nil, errUnsupportedProfile
+	}
+}
+
+// LinuxKeys returns the keys of a Linux chromium profile, v10 blobs are
+// encrypted with the key of the hardcoded "peanuts" secret, v11 blobs with
+// the key of secret kept in the keyring, which is nil if none was found.
+// @https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/sync/os_crypt_linux.cc
+func LinuxKeys(secret []byte) decrypter.Keys {
+	keys := decrypter.Keys{
+		decrypter.PrefixV10: linuxKey([]byte("peanuts")),
+	}
+	if len(secret) > 0 {
+		keys[decrypter.PrefixV11] = linuxKey(secret)
+	}
+	return keys
+}
+
+func linuxKey(secret []byte) []byte {
+	return pbkdf2.Key(secret, []byte("saltysalt"), 1, 16, sha1.New)
+}
@@ -0,0 +1,48 @@
+package masterkey
+
+import (
+	"testing"
+
+	"hack-browser-data/internal/decrypter"
+	"hack-browser-data/internal/testfixture"
+)
+
+func TestChromiumMasterKey(t *testing.T) {
+	rawKey := []byte("0123456789abcdef")
+	v10, err := testfixture.EncryptChromium(testfixture.ChromiumLinuxKey, []byte("v10 secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v11, err := testfixture.EncryptChromium(rawKey, []byte("v11 secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v11 = append([]byte(decrypter.PrefixV11), v11[3:]...)
+	darwin, err := testfixture.EncryptChromium(rawKey, []byte("darwin secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		keys Material
+		blob []byte
+		want string
+	}{
+		{name: "linux raw key v10", keys: Material{ChromiumKey: rawKey, ProfileOS: "linux"}, blob: v10, want: "v10 secret"},
+		{name: "linux raw key v11", keys: Material{ChromiumKey: rawKey, ProfileOS: "linux"}, blob: v11, want: "v11 secret"},
+		{name: "linux safe storage v10", keys: Material{SafeStorage: []byte("secret"), ProfileOS: "linux"}, blob: v10, want: "v10 secret"},
+		{name: "darwin raw key", keys: Material{ChromiumKey: rawKey, ProfileOS: "darwin"}, blob: darwin, want: "darwin secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := tt.keys.ChromiumMasterKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := decrypter.Chromium(keys, tt.blob)
+			if err != nil || string(got) != tt.want {
+				t.Errorf("Chromium() = %q, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
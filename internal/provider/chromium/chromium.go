@@ -2,32 +2,43 @@ package chromium
 
 import (
 	"io/fs"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"hack-browser-data/internal/browingdata"
+	"hack-browser-data/internal/browingdata/session"
 	"hack-browser-data/internal/browser"
+	"hack-browser-data/internal/decrypter"
 	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/keyprovider"
+	"hack-browser-data/internal/log"
 	"hack-browser-data/internal/utils/fileutil"
-	"hack-browser-data/internal/utils/typeutil"
+
+	"golang.org/x/exp/slices"
 )
 
 type chromium struct {
 	name        string
 	storage     string
 	profilePath string
-	masterKey   []byte
+	masterKey   decrypter.Keys
 	items       []item.Item
 	itemPaths   map[item.Item]string
+	// keys supplies the master key, copied profiles have no storage
+	// and their keys never come from the OS keystore
+	keys keyprovider.Chain
 }
 
 // New create instance of chromium browser, fill item's path if item is existed.
-func New(name, storage, profilePath string, items []item.Item) ([]browser.Browser, error) {
+// storage is the name of its secret in the OS keystore, empty for copied profiles.
+func New(name, storage, profilePath string, items []item.Item, keys keyprovider.Chain) ([]browser.Browser, error) {
 	c := &chromium{
 		name:        name,
 		storage:     storage,
 		profilePath: profilePath,
 		items:       items,
+		keys:        keys,
 	}
 	multiItemPaths, err := c.getMultiItemPath(c.profilePath, c.items)
 	if err != nil {
@@ -36,10 +47,12 @@ func New(name, storage, profilePath string, items []item.Item) ([]browser.Browse
 	chromiumList := make([]browser.Browser, 0, len(multiItemPaths))
 	for user, itemPaths := range multiItemPaths {
 		chromiumList = append(chromiumList, &chromium{
-			name:      fileutil.BrowserName(name, user),
-			items:     typeutil.Keys(itemPaths),
-			itemPaths: itemPaths,
-			storage:   storage,
+			name:        fileutil.BrowserName(c.name, user),
+			profilePath: filepath.Join(fileutil.ParentDir(c.profilePath), user),
+			items:       c.items,
+			itemPaths:   itemPaths,
+			storage:     c.storage,
+			keys:        c.keys,
 		})
 	}
 	return chromiumList, nil
@@ -49,45 +62,87 @@ func (c *chromium) Name() string {
 	return c.name
 }
 
-func (c *chromium) BrowsingData() (*browingdata.Data, error) {
-	b := browingdata.New(c.items)
+func (c *chromium) Profile() string {
+	return c.profilePath
+}
+
+func (c *chromium) BrowsingData(sink browingdata.Sink) (*browingdata.Data, error) {
+	b := browingdata.New(c.items, sink)
 
-	if err := c.copyItemToLocal(); err != nil {
+	// every run copies items into its own dir, it's removed on return
+	workDir, removeWorkDir, err := fileutil.MkdirWork()
+	if err != nil {
 		return nil, err
 	}
+	defer removeWorkDir()
 
-	masterKey, err := c.GetMasterKey()
+	localPaths, err := c.copyItemToLocal(workDir)
 	if err != nil {
 		return nil, err
 	}
 
+	// items without secrets are still parsed if the master key is unavailable
+	masterKey, provider, keyErr := c.keys.Key(keyprovider.Target{
+		Engine:     keyprovider.Chromium,
+		Browser:    c.name,
+		Storage:    c.storage,
+		LocalState: c.itemPaths[item.ChromiumKey],
+	}, nil)
+	var keyless []item.Item
+	if keyErr != nil {
+		log.Errorf("%s get master key error: %s", c.name, keyErr)
+		for _, i := range keyItems {
+			if _, ok := localPaths[i]; ok {
+				keyless = append(keyless, i)
+				delete(localPaths, i)
+			}
+		}
+	}
+
 	c.masterKey = masterKey
-	if err := b.Recovery(c.masterKey); err != nil {
-		return nil, err
+	b.SetKeyProvider(provider)
+	// failed items are kept in the report of b, the others are still usable
+	if err := b.Recovery(c.masterKey, localPaths); err != nil {
+		log.Warnf("%s recovery: %s", c.name, err)
+	}
+	for _, i := range keyless {
+		b.SetStatus(i, browingdata.StatusDecryptFailed, keyErr)
 	}
 	return b, nil
 }
 
-func (c *chromium) copyItemToLocal() error {
+// keyItems are the items encrypted with the master key, they are not
+// parsed without it
+var keyItems = []item.Item{
+	item.ChromiumPassword, item.ChromiumCookie, item.ChromiumCreditCard,
+	item.YandexPassword, item.YandexCreditCard,
+}
+
+// copyItemToLocal copies databases locked by a running browser into workDir
+// and returns the path each item is parsed from.
+func (c *chromium) copyItemToLocal(workDir string) (map[item.Item]string, error) {
+	localPaths := make(map[item.Item]string, len(c.itemPaths))
 	for i, path := range c.itemPaths {
-		filename := i.String()
+		filename := filepath.Join(workDir, i.String())
 		var err error
 		switch {
+		case i == item.ChromiumKey, i == item.ChromiumBookmark, i == item.ChromiumExtension:
+			// json files are read in place
+			filename = path
+		case i == item.ChromiumSession && filepath.Base(path) != i.FileName():
+			err = copyLegacySessions(path, filename)
 		case fileutil.FolderExists(path):
-			if i == item.ChromiumLocalStorage {
-				err = fileutil.CopyDir(path, filename, "lock")
-			}
-			if i == item.ChromiumExtension {
-				err = fileutil.CopyDirHasSuffix(path, filename, "manifest.json")
-			}
+			// blobs of IndexedDB are left out, they may be large media
+			err = fileutil.CopyDir(path, filename, "lock", ".blob")
 		default:
 			err = fileutil.CopyFile(path, filename)
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
+		localPaths[i] = filename
 	}
-	return nil
+	return localPaths, nil
 }
 
 func (c *chromium) getMultiItemPath(profilePath string, items []item.Item) (map[string]map[item.Item]string, error) {
@@ -115,8 +170,12 @@ func (c *chromium) getMultiItemPath(profilePath string, items []item.Item) (map[
 			continue
 		}
 		t[userDir] = v
-		t[userDir][item.ChromiumKey] = keyPath
-		fillLocalStoragePath(t[userDir], item.ChromiumLocalStorage)
+		// copied profiles may come without Local State
+		if keyPath != "" {
+			t[userDir][item.ChromiumKey] = keyPath
+		}
+		fillStoragePaths(t[userDir], items)
+		fillLegacySessionPath(t[userDir], items)
 	}
 	return t, nil
 }
@@ -124,7 +183,7 @@ func (c *chromium) getMultiItemPath(profilePath string, items []item.Item) (map[
 func chromiumWalkFunc(items []item.Item, multiItemPaths map[string]map[item.Item]string) filepath.WalkFunc {
 	return func(path string, info fs.FileInfo, err error) error {
 		for _, v := range items {
-			if info.Name() == v.FileName() {
+			if info.Name() == v.FileName() && !slices.Contains(storageItems, v) {
 				if strings.Contains(path, "System Profile") {
 					continue
 				}
@@ -143,11 +202,58 @@ func chromiumWalkFunc(items []item.Item, multiItemPaths map[string]map[item.Item
 	}
 }
 
-func fillLocalStoragePath(itemPaths map[item.Item]string, storage item.Item) {
-	if p, ok := itemPaths[item.ChromiumHistory]; ok {
-		lsp := filepath.Join(filepath.Dir(p), storage.FileName())
-		if fileutil.FolderExists(lsp) {
-			itemPaths[item.ChromiumLocalStorage] = lsp
+// storageItems are dirs of a profile, extensions keep dirs of the same
+// names in their own storage, their paths are filled from the one of History
+var storageItems = []item.Item{item.ChromiumLocalStorage, item.ChromiumSessionStorage, item.ChromiumIndexedDB}
+
+func fillStoragePaths(itemPaths map[item.Item]string, items []item.Item) {
+	p, ok := itemPaths[item.ChromiumHistory]
+	if !ok {
+		return
+	}
+	for _, storage := range storageItems {
+		if !slices.Contains(items, storage) {
+			continue
+		}
+		sp := filepath.Join(filepath.Dir(p), storage.FileName())
+		if fileutil.FolderExists(sp) {
+			itemPaths[storage] = sp
+		}
+	}
+}
+
+// fillLegacySessionPath sets the session path of chromium 99 and earlier
+// to the profile dir, it has session files instead of a Sessions dir
+func fillLegacySessionPath(itemPaths map[item.Item]string, items []item.Item) {
+	if _, ok := itemPaths[item.ChromiumSession]; ok || !slices.Contains(items, item.ChromiumSession) {
+		return
+	}
+	p, ok := itemPaths[item.ChromiumHistory]
+	if !ok {
+		return
+	}
+	for _, name := range session.ChromiumLegacyFiles {
+		if fileutil.FileExists(filepath.Join(filepath.Dir(p), name)) {
+			itemPaths[item.ChromiumSession] = filepath.Dir(p)
+			return
+		}
+	}
+}
+
+// copyLegacySessions copies the legacy session files of the profile dir
+// into dst, the rest of the profile is left out
+func copyLegacySessions(profile, dst string) error {
+	if err := os.MkdirAll(dst, 0o750); err != nil {
+		return err
+	}
+	for _, name := range session.ChromiumLegacyFiles {
+		src := filepath.Join(profile, name)
+		if !fileutil.FileExists(src) {
+			continue
+		}
+		if err := fileutil.CopyFile(src, filepath.Join(dst, name)); err != nil {
+			return err
 		}
 	}
+	return nil
 }
@@ -4,13 +4,21 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"hack-browser-data/internal/browingdata"
+	"hack-browser-data/internal/browingdata/password"
+	"hack-browser-data/internal/browingdata/session"
 	"hack-browser-data/internal/browser"
+	"hack-browser-data/internal/decrypter"
 	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/keyprovider"
+	"hack-browser-data/internal/log"
 	"hack-browser-data/internal/utils/fileutil"
-	"hack-browser-data/internal/utils/typeutil"
+
+	"golang.org/x/exp/slices"
 )
 
 type firefox struct {
@@ -20,17 +28,26 @@ type firefox struct {
 	masterKey   []byte
 	items       []item.Item
 	itemPaths   map[item.Item]string
+	// keys supplies the primary password of key4.db if the user set one
+	keys keyprovider.Chain
 }
 
-var ErrProfilePathNotFound = errors.New("profile path not found")
+var (
+	ErrProfilePathNotFound = errors.New("profile path not found")
+	errKeyDBNotFound       = errors.New("key4.db or key3.db not found")
+)
+
+// noPrimaryPassword is the key provider of profiles without primary password
+const noPrimaryPassword = "none"
 
-// New returns a new firefox instance.
-func New(name, storage, profilePath string, items []item.Item) ([]browser.Browser, error) {
+// New returns firefox instances of the profiles under profilePath.
+func New(name, storage, profilePath string, items []item.Item, keys keyprovider.Chain) ([]browser.Browser, error) {
 	f := &firefox{
 		name:        name,
 		storage:     storage,
 		profilePath: profilePath,
 		items:       items,
+		keys:        keys,
 	}
 	multiItemPaths, err := f.getMultiItemPath(f.profilePath, f.items)
 	if err != nil {
@@ -39,36 +56,99 @@ func New(name, storage, profilePath string, items []item.Item) ([]browser.Browse
 
 	firefoxList := make([]browser.Browser, 0, len(multiItemPaths))
 	for name, itemPaths := range multiItemPaths {
+		profile := profileDir(itemPaths)
+		fillSessionPath(itemPaths, profile, f.items)
 		firefoxList = append(firefoxList, &firefox{
-			name:      fmt.Sprintf("firefox-%s", name),
-			items:     typeutil.Keys(itemPaths),
-			itemPaths: itemPaths,
+			name:        fmt.Sprintf("firefox-%s", name),
+			profilePath: profile,
+			items:       f.items,
+			itemPaths:   itemPaths,
+			storage:     f.storage,
+			keys:        f.keys,
 		})
 	}
 	return firefoxList, nil
 }
 
+// profileDir returns the dir holding the items, they're all in the profile dir
+func profileDir(itemPaths map[item.Item]string) string {
+	for i, p := range itemPaths {
+		profile, _ := itemProfile(p, i.FileName())
+		return profile
+	}
+	return ""
+}
+
+// fillSessionPath sets the session path to the profile dir, which holds
+// sessionstore.jsonlz4 with or without the sessionstore-backups dir
+func fillSessionPath(itemPaths map[item.Item]string, profile string, items []item.Item) {
+	if !slices.Contains(items, item.FirefoxSession) {
+		return
+	}
+	delete(itemPaths, item.FirefoxSession)
+	for _, name := range session.FirefoxFiles {
+		if fileutil.FileExists(filepath.Join(profile, name)) {
+			itemPaths[item.FirefoxSession] = profile
+			return
+		}
+	}
+}
+
+// itemProfile returns the profile dir of path if it's the file of an item
+// named name, names like storage/default are paths in the profile dir
+func itemProfile(path, name string) (string, bool) {
+	suffix := string(filepath.Separator) + filepath.FromSlash(name)
+	if !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(path, suffix), true
+}
+
 func (f *firefox) getMultiItemPath(profilePath string, items []item.Item) (map[string]map[item.Item]string, error) {
 	multiItemPaths := make(map[string]map[item.Item]string)
 	err := filepath.Walk(profilePath, firefoxWalkFunc(items, multiItemPaths))
 	return multiItemPaths, err
 }
 
-func (f *firefox) copyItemToLocal() error {
+// originStores are the databases of origins in storage/default of items
+// kept there, caches and files of values aren't copied
+var originStores = map[item.Item]string{
+	item.FirefoxLocalStorage: "*/ls/data.sqlite",
+	item.FirefoxIndexedDB:    "*/idb/*.sqlite",
+}
+
+// copyItemToLocal copies databases locked by a running browser into workDir
+// and returns the path each item is parsed from.
+func (f *firefox) copyItemToLocal(workDir string) (map[item.Item]string, error) {
+	localPaths := make(map[item.Item]string, len(f.itemPaths))
 	for i, path := range f.itemPaths {
-		filename := i.String()
+		switch i {
+		case item.FirefoxPassword, item.FirefoxExtension, item.FirefoxCreditCard, item.FirefoxAddress, item.FirefoxSession:
+			// json files are read in place, Firefox replaces them as a whole
+			localPaths[i] = path
+			continue
+		}
+		filename := filepath.Join(workDir, i.String())
+		if pattern, ok := originStores[i]; ok {
+			if err := fileutil.CopyDirMatch(path, filename, pattern); err != nil {
+				return nil, err
+			}
+			localPaths[i] = filename
+			continue
+		}
 		if err := fileutil.CopyFile(path, filename); err != nil {
-			return err
+			return nil, err
 		}
+		localPaths[i] = filename
 	}
-	return nil
+	return localPaths, nil
 }
 
 func firefoxWalkFunc(items []item.Item, multiItemPaths map[string]map[item.Item]string) filepath.WalkFunc {
 	return func(path string, info fs.FileInfo, err error) error {
 		for _, v := range items {
-			if info.Name() == v.FileName() {
-				parentBaseDir := fileutil.ParentBaseDir(path)
+			if profile, ok := itemProfile(path, v.FileName()); ok {
+				parentBaseDir := filepath.Base(profile)
 				if _, exist := multiItemPaths[parentBaseDir]; exist {
 					multiItemPaths[parentBaseDir][v] = path
 				} else {
@@ -80,29 +160,121 @@ func firefoxWalkFunc(items []item.Item, multiItemPaths map[string]map[item.Item]
 	}
 }
 
-func (f *firefox) GetMasterKey() ([]byte, error) {
-	return f.masterKey, nil
+// keyDB returns the key database of the profile, key3.db of Firefox 57
+// and earlier is only used without key4.db, which replaced it.
+func keyDB(localPaths map[item.Item]string) item.Item {
+	if _, ok := localPaths[item.FirefoxKey3]; ok {
+		if _, ok := localPaths[item.FirefoxKey4]; !ok {
+			return item.FirefoxKey3
+		}
+	}
+	return item.FirefoxKey4
+}
+
+// GetMasterKey returns the key of logins derived from keyFile, the key4.db
+// or key3.db of keyItem, and the name of the provider of the primary
+// password. An empty password is tried first, it's the one of profiles
+// without primary password.
+func (f *firefox) GetMasterKey(keyItem item.Item, keyFile string) ([]byte, string, error) {
+	if keyFile == "" {
+		return nil, "", errKeyDBNotFound
+	}
+	masterKey := password.FirefoxMasterKey
+	if keyItem == item.FirefoxKey3 {
+		masterKey = password.FirefoxKey3MasterKey
+	}
+	key, err := masterKey(keyFile, nil)
+	if err == nil {
+		return key, noPrimaryPassword, nil
+	}
+	target := keyprovider.Target{Engine: keyprovider.Firefox, Browser: f.name}
+	_, provider, chainErr := f.keys.Key(target, func(keys decrypter.Keys) error {
+		key, err = masterKey(keyFile, keys.Key(decrypter.AnyPrefix))
+		return err
+	})
+	if errors.Is(chainErr, keyprovider.ErrNoKey) {
+		return nil, "", err
+	}
+	if chainErr != nil {
+		return nil, "", chainErr
+	}
+	return key, provider, nil
+}
+
+// osKeyStoreKey adds the key of the OS key store to keys, newer Firefox
+// encrypts credit card numbers with it. Without it the numbers are left
+// encrypted, the key of logins is still used for older numbers.
+func (f *firefox) osKeyStoreKey(keys decrypter.Keys) decrypter.Keys {
+	target := keyprovider.Target{Engine: keyprovider.FirefoxOSKeyStore, Browser: f.name, Storage: f.storage}
+	osKey, _, err := f.keys.Key(target, nil)
+	if err != nil {
+		log.Debugf("%s OS key store key error: %s", f.name, err)
+		return keys
+	}
+	if keys == nil {
+		keys = decrypter.Keys{}
+	}
+	keys[decrypter.OSKeyStore] = osKey[decrypter.OSKeyStore]
+	return keys
 }
 
 func (f *firefox) Name() string {
 	return f.name
 }
 
-func (f *firefox) BrowsingData() (*browingdata.Data, error) {
-	b := browingdata.New(f.items)
+func (f *firefox) Profile() string {
+	return f.profilePath
+}
 
-	if err := f.copyItemToLocal(); err != nil {
+func (f *firefox) BrowsingData(sink browingdata.Sink) (*browingdata.Data, error) {
+	b := browingdata.New(f.items, sink)
+
+	// every run copies items into its own dir, it's removed on return
+	workDir, removeWorkDir, err := fileutil.MkdirWork()
+	if err != nil {
 		return nil, err
 	}
+	defer removeWorkDir()
 
-	masterKey, err := f.GetMasterKey()
+	localPaths, err := f.copyItemToLocal(workDir)
 	if err != nil {
 		return nil, err
 	}
 
+	// other items are still parsed if the key of logins is unavailable
+	keyItem := keyDB(localPaths)
+	masterKey, provider, keyErr := f.GetMasterKey(keyItem, localPaths[keyItem])
+	if keyErr != nil {
+		log.Errorf("%s get master key error: %s", f.name, keyErr)
+	}
+	b.SetKeyProvider(provider)
+	// logins are in signons.sqlite before Firefox 32
+	if _, ok := localPaths[item.FirefoxPassword]; !ok {
+		if path, ok := localPaths[item.FirefoxSignons]; ok {
+			localPaths[item.FirefoxPassword] = path
+		}
+	}
+	// local storage is in webappsstore.sqlite before Firefox 92, profiles
+	// of Firefox 68 on have storage/default as well, both are read
+	if store, ok := localPaths[item.FirefoxWebappsStore]; ok {
+		if dir, ok := localPaths[item.FirefoxLocalStorage]; !ok {
+			localPaths[item.FirefoxLocalStorage] = store
+		} else if err := os.Rename(store, filepath.Join(dir, item.FirefoxWebappsStore.FileName())); err != nil {
+			log.Warnf("%s move %s error: %s", f.name, item.FirefoxWebappsStore.FileName(), err)
+		}
+	}
+
 	f.masterKey = masterKey
-	if err := b.Recovery(f.masterKey); err != nil {
-		return nil, err
+	keys := decrypter.NewKeys(f.masterKey)
+	if _, ok := localPaths[item.FirefoxCreditCard]; ok {
+		keys = f.osKeyStoreKey(keys)
+	}
+	// failed items are kept in the report of b, the others are still usable
+	if err := b.Recovery(keys, localPaths); err != nil {
+		log.Warnf("%s recovery: %s", f.name, err)
+	}
+	if _, ok := localPaths[keyItem]; ok && keyErr != nil {
+		b.SetStatus(keyItem, browingdata.StatusDecryptFailed, keyErr)
 	}
 	return b, nil
 }
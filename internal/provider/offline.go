@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"hack-browser-data/internal/browser"
+	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/keyprovider"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/provider/chromium"
+	"hack-browser-data/internal/provider/firefox"
+	"hack-browser-data/internal/utils/fileutil"
+)
+
+const (
+	// chromium keeps Local State in the user data dir and Preferences in every profile
+	chromiumLocalState  = "Local State"
+	chromiumPreferences = "Preferences"
+	// firefox keeps prefs.js in every profile
+	firefoxPrefs = "prefs.js"
+)
+
+// PickOfflineBrowsers builds browsers from profiles copied under dir, nothing
+// is read from the home dir, keys should leave the OS keystore out.
+func PickOfflineBrowsers(name, dir string, keys keyprovider.Chain) ([]browser.Browser, error) {
+	if !fileutil.FolderExists(filepath.Clean(dir)) {
+		return nil, fmt.Errorf("offline dir %s does not exist", dir)
+	}
+	chromiumDirs, yandexDirs, firefoxDirs, err := walkOfflineDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var browsers []browser.Browser
+	name = strings.ToLower(name)
+	if name != "firefox" {
+		for _, userDataDir := range chromiumDirs {
+			// chromium.New walks the parent of profile path
+			profilePath := filepath.Join(userDataDir, "Default")
+			items := item.DefaultChromium
+			if _, ok := yandexDirs[userDataDir]; ok {
+				items = item.DefaultYandex
+			}
+			multiChromium, err := chromium.New(offlineName(dir, userDataDir), "", profilePath, items, keys)
+			if err != nil {
+				log.Errorf("new offline chromium %s error: %s", userDataDir, err.Error())
+				continue
+			}
+			for _, b := range multiChromium {
+				log.Noticef("%s %s %s", color.RedString("find browser"), color.RedString(b.Name()), color.RedString("success"))
+				browsers = append(browsers, b)
+			}
+		}
+	}
+	if name == "all" || name == "firefox" {
+		for _, profilesDir := range firefoxDirs {
+			multiFirefox, err := firefox.New("", "", profilesDir, item.DefaultFirefox, keys)
+			if err != nil {
+				log.Errorf("new offline firefox %s error: %s", profilesDir, err.Error())
+				continue
+			}
+			for _, b := range multiFirefox {
+				log.Noticef("%s %s %s", color.RedString("find browser"), color.RedString(b.Name()), color.RedString("success"))
+				browsers = append(browsers, b)
+			}
+		}
+	}
+	return browsers, nil
+}
+
+// walkOfflineDir returns chromium user data dirs, the set of them made by
+// yandex, and dirs holding firefox profiles under root.
+func walkOfflineDir(root string) (chromiumDirs []string, yandexDirs map[string]struct{}, firefoxDirs []string, err error) {
+	chromiumSet := make(map[string]struct{})
+	firefoxSet := make(map[string]struct{})
+	yandexDirs = make(map[string]struct{})
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("walk %s error: %s", path, err)
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch d.Name() {
+		case chromiumLocalState:
+			chromiumSet[filepath.Dir(path)] = struct{}{}
+		case chromiumPreferences:
+			chromiumSet[profileParent(root, filepath.Dir(path))] = struct{}{}
+		case item.YandexPassword.FileName():
+			yandexDirs[profileParent(root, filepath.Dir(path))] = struct{}{}
+		case firefoxPrefs, item.FirefoxKey4.FileName(), item.FirefoxKey3.FileName():
+			firefoxSet[profileParent(root, filepath.Dir(path))] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for k := range chromiumSet {
+		chromiumDirs = append(chromiumDirs, k)
+	}
+	for k := range firefoxSet {
+		firefoxDirs = append(firefoxDirs, k)
+	}
+	sort.Strings(chromiumDirs)
+	sort.Strings(firefoxDirs)
+	return chromiumDirs, yandexDirs, firefoxDirs, nil
+}
+
+// profileParent returns the parent of profile dir, or the profile dir itself
+// if the profile was copied as the offline dir, never walk outside root.
+func profileParent(root, profileDir string) string {
+	parent := fileutil.ParentDir(profileDir)
+	if rel, err := filepath.Rel(root, parent); err != nil || strings.HasPrefix(rel, "..") {
+		return profileDir
+	}
+	return parent
+}
+
+// offlineName names a copied browser by its path relative to the offline dir
+func offlineName(root, dir string) string {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return fileutil.BaseDir(dir)
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "_")
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"hack-browser-data/internal/browser"
+	"hack-browser-data/internal/keyprovider"
 	"hack-browser-data/internal/log"
 	"hack-browser-data/internal/provider/chromium"
 	"hack-browser-data/internal/provider/firefox"
@@ -15,15 +16,16 @@ import (
 	"hack-browser-data/internal/utils/typeutil"
 )
 
-func PickBrowsers(name, profile string) ([]browser.Browser, error) {
+// PickBrowsers returns browsers installed on this machine, their keys come from keys.
+func PickBrowsers(name, profile string, keys keyprovider.Chain) ([]browser.Browser, error) {
 	var browsers []browser.Browser
-	clist := pickChromium(name, profile)
+	clist := pickChromium(name, profile, keys)
 	for _, b := range clist {
 		if b != nil {
 			browsers = append(browsers, b)
 		}
 	}
-	flist := pickFirefox(name, profile)
+	flist := pickFirefox(name, profile, keys)
 	for _, b := range flist {
 		if b != nil {
 			browsers = append(browsers, b)
@@ -32,7 +34,7 @@ func PickBrowsers(name, profile string) ([]browser.Browser, error) {
 	return browsers, nil
 }
 
-func pickChromium(name, profile string) []browser.Browser {
+func pickChromium(name, profile string, keys keyprovider.Chain) []browser.Browser {
 	var browsers []browser.Browser
 	name = strings.ToLower(name)
 	if name == "all" {
@@ -41,7 +43,7 @@ func pickChromium(name, profile string) []browser.Browser {
 				log.Noticef("find browser %s failed, profile folder does not exist", v.name)
 				continue
 			}
-			if multiChromium, err := chromium.New(v.name, v.storage, v.profilePath, v.items); err == nil {
+			if multiChromium, err := chromium.New(v.name, v.storage, v.profilePath, v.items, keys); err == nil {
 				log.Noticef("%s %s %s", color.RedString("find browser"), color.RedString(v.name), color.RedString("success"))
 				for _, b := range multiChromium {
 					log.Noticef("%s %s %s", color.RedString("find browser"), color.RedString(b.Name()), color.RedString("success"))
@@ -59,7 +61,7 @@ func pickChromium(name, profile string) []browser.Browser {
 		if !fileutil.FolderExists(filepath.Clean(profile)) {
 			log.Fatalf("find browser %s failed, profile folder does not exist", c.name)
 		}
-		chromiumList, err := chromium.New(c.name, c.storage, profile, c.items)
+		chromiumList, err := chromium.New(c.name, c.storage, profile, c.items, keys)
 		if err != nil {
 			log.Fatalf("new chromium error: %s", err)
 		}
@@ -71,7 +73,7 @@ func pickChromium(name, profile string) []browser.Browser {
 	return browsers
 }
 
-func pickFirefox(name, profile string) []browser.Browser {
+func pickFirefox(name, profile string, keys keyprovider.Chain) []browser.Browser {
 	var browsers []browser.Browser
 	name = strings.ToLower(name)
 	if name == "all" || name == "firefox" {
@@ -85,7 +87,7 @@ func pickFirefox(name, profile string) []browser.Browser {
 				log.Noticef("find browser firefox %s failed, profile folder does not exist", v.name)
 				continue
 			}
-			if multiFirefox, err := firefox.New(v.name, v.storage, profile, v.items); err == nil {
+			if multiFirefox, err := firefox.New(v.name, v.storage, profile, v.items, keys); err == nil {
 				for _, b := range multiFirefox {
 					log.Noticef("%s %s %s", color.RedString("find browser"), color.RedString(b.Name()), color.RedString("success"))
 					browsers = append(browsers, b)
@@ -127,3 +129,7 @@ const (
 	dcbrowserName  = "dcbrowser"
 	sougouName     = "Sougou"
 )
+
+// firefoxStorageName is the name firefox keeps the key of its OS key store
+// under, on every OS
+const firefoxStorageName = "Firefox Encrypted Storage"
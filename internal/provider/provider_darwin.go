@@ -82,6 +82,7 @@ var (
 	}{
 		"firefox": {
 			name:        firefoxName,
+			storage:     firefoxStorageName,
 			profilePath: firefoxProfilePath,
 			items:       item.DefaultFirefox,
 		},
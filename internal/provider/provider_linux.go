@@ -64,6 +64,7 @@ var (
 	}{
 		"firefox": {
 			name:        firefoxName,
+			storage:     firefoxStorageName,
 			profilePath: firefoxProfilePath,
 			items:       item.DefaultFirefox,
 		},
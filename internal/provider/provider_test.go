@@ -0,0 +1,334 @@
+package provider
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"hack-browser-data/internal/browingdata"
+	"hack-browser-data/internal/browingdata/session"
+	"hack-browser-data/internal/browser"
+	"hack-browser-data/internal/item"
+	"hack-browser-data/internal/keyprovider"
+	"hack-browser-data/internal/masterkey"
+	"hack-browser-data/internal/provider/chromium"
+	"hack-browser-data/internal/provider/firefox"
+	"hack-browser-data/internal/testfixture"
+)
+
+var update = flag.Bool("update", false, "update golden files of testdata")
+
+func TestMain(m *testing.M) {
+	// dates are written in the local zone, golden files are in UTC
+	time.Local = time.UTC
+	os.Exit(m.Run())
+}
+
+func TestBrowsingDataGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		golden   string
+		provider string
+		build    func(dir string) ([]browser.Browser, error)
+	}{
+		{
+			name:     "chromium linux",
+			golden:   "chromium",
+			provider: "basic",
+			build: func(dir string) ([]browser.Browser, error) {
+				if err := testfixture.Chromium(dir, testfixture.ChromiumLinuxKey); err != nil {
+					return nil, err
+				}
+				keys := keyprovider.Chain{keyprovider.Basic("linux")}
+				return chromium.New("chrome", "", filepath.Join(dir, "Default"), item.DefaultChromium, keys)
+			},
+		},
+		{
+			name:     "chromium windows key",
+			golden:   "chromium",
+			provider: "static",
+			build: func(dir string) ([]browser.Browser, error) {
+				if err := testfixture.Chromium(dir, testfixture.ChromiumWindowsKey); err != nil {
+					return nil, err
+				}
+				keys := keyprovider.Chain{keyprovider.Static(&masterkey.Material{ChromiumKey: testfixture.ChromiumWindowsKey, ProfileOS: "windows"})}
+				return chromium.New("chrome", "", filepath.Join(dir, "Default"), item.DefaultChromium, keys)
+			},
+		},
+		{
+			name:     "firefox",
+			golden:   "firefox",
+			provider: "none",
+			build: func(dir string) ([]browser.Browser, error) {
+				if err := testfixture.Firefox(filepath.Join(dir, "abcd1234.default-release"), nil); err != nil {
+					return nil, err
+				}
+				return firefox.New("firefox", "", dir, item.DefaultFirefox, nil)
+			},
+		},
+		{
+			name:     "firefox primary password",
+			golden:   "firefox-primary-password",
+			provider: "static",
+			build: func(dir string) ([]browser.Browser, error) {
+				password := []byte("hunter2")
+				if err := testfixture.Firefox(filepath.Join(dir, "abcd1234.default-release"), password); err != nil {
+					return nil, err
+				}
+				keys := keyprovider.Chain{keyprovider.Static(&masterkey.Material{FirefoxPassword: password, FirefoxOSKey: testfixture.FirefoxOSKey})}
+				return firefox.New("firefox", "", dir, item.DefaultFirefox, keys)
+			},
+		},
+		{
+			name:     "firefox legacy",
+			golden:   "firefox-legacy",
+			provider: "none",
+			build: func(dir string) ([]browser.Browser, error) {
+				if err := testfixture.FirefoxLegacy(filepath.Join(dir, "efgh5678.default"), nil); err != nil {
+					return nil, err
+				}
+				return firefox.New("firefox", "", dir, item.DefaultFirefox, nil)
+			},
+		},
+		{
+			name:     "firefox legacy primary password",
+			golden:   "firefox-legacy",
+			provider: "static",
+			build: func(dir string) ([]browser.Browser, error) {
+				password := []byte("hunter2")
+				if err := testfixture.FirefoxLegacy(filepath.Join(dir, "efgh5678.default"), password); err != nil {
+					return nil, err
+				}
+				keys := keyprovider.Chain{keyprovider.Static(&masterkey.Material{FirefoxPassword: password})}
+				return firefox.New("firefox", "", dir, item.DefaultFirefox, keys)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			browsers, err := tt.build(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(browsers) != 1 {
+				t.Fatalf("found %d browsers, want 1", len(browsers))
+			}
+			data, err := browsers[0].BrowsingData(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p := data.KeyProvider(); p != tt.provider {
+				t.Errorf("key provider = %s, want %s", p, tt.provider)
+			}
+			for _, r := range data.Report() {
+				if r.Failed() {
+					t.Errorf("item %s %s: %s", r.Item, r.Status, r.Error)
+				}
+			}
+			output := browingdata.NewOutPutter("json")
+			if err := output.SetCookieFormats([]string{"netscape", "editor"}); err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			data.Output(dir, browsers[0].Name(), output)
+			compareGolden(t, dir, filepath.Join("testdata", tt.golden))
+		})
+	}
+}
+
+// compareGolden compares files under dir to the golden files, with -update
+// the golden files are replaced instead.
+func compareGolden(t *testing.T, dir, golden string) {
+	t.Helper()
+	if *update {
+		if err := os.RemoveAll(golden); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(golden, 0o750); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadDir(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !*update && len(got) != len(want) {
+		t.Errorf("wrote %d files, want %d", len(got), len(want))
+	}
+	for _, f := range got {
+		b, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		goldenFile := filepath.Join(golden, f.Name())
+		if *update {
+			if err := os.WriteFile(goldenFile, b, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		w, err := os.ReadFile(goldenFile)
+		if err != nil {
+			t.Errorf("unexpected file %s", f.Name())
+			continue
+		}
+		if string(b) != string(w) {
+			t.Errorf("%s differs from golden file\ngot:\n%s\nwant:\n%s", f.Name(), b, w)
+		}
+	}
+}
+
+// TestChromiumLegacySessions reads the sessions of chromium 99 and earlier,
+// they are the ones of the Sessions dir under the legacy file names
+func TestChromiumLegacySessions(t *testing.T) {
+	sessions := func(build func(dir string, key []byte) error) []session.Navigation {
+		t.Helper()
+		dir := t.TempDir()
+		if err := build(dir, testfixture.ChromiumWindowsKey); err != nil {
+			t.Fatal(err)
+		}
+		keys := keyprovider.Chain{keyprovider.Static(&masterkey.Material{ChromiumKey: testfixture.ChromiumWindowsKey, ProfileOS: "windows"})}
+		browsers, err := chromium.New("chrome", "", filepath.Join(dir, "Default"), []item.Item{item.ChromiumHistory, item.ChromiumSession}, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(browsers) != 1 {
+			t.Fatalf("found %d browsers, want 1", len(browsers))
+		}
+		data, err := browsers[0].BrowsingData(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range data.Report() {
+			if r.Item == item.ChromiumSession.String() && r.Status != browingdata.StatusOK {
+				t.Fatalf("item %s %s: %s", r.Item, r.Status, r.Error)
+			}
+		}
+		for _, source := range data.Sources() {
+			if s, ok := source.(*session.ChromiumSession); ok {
+				return *s
+			}
+		}
+		t.Fatal("no session source")
+		return nil
+	}
+	want := sessions(testfixture.Chromium)
+	got := sessions(testfixture.ChromiumLegacy)
+	files := map[string]string{"Session_13317102245000000": "Current Session", "Tabs_13317102245000000": "Current Tabs"}
+	for i := range want {
+		want[i].File = files[want[i].File]
+	}
+	if len(want) == 0 || !reflect.DeepEqual(got, want) {
+		t.Errorf("legacy sessions = %+v, want %+v", got, want)
+	}
+}
+
+// TestChromiumWithoutKey parses the items without secrets of a profile
+// whose master key is unavailable, like a copied profile of Windows
+func TestChromiumWithoutKey(t *testing.T) {
+	dir := t.TempDir()
+	if err := testfixture.Chromium(dir, testfixture.ChromiumWindowsKey); err != nil {
+		t.Fatal(err)
+	}
+	browsers, err := chromium.New("chrome", "", filepath.Join(dir, "Default"), item.DefaultChromium, keyprovider.Chain{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(browsers) != 1 {
+		t.Fatalf("found %d browsers, want 1", len(browsers))
+	}
+	data, err := browsers[0].BrowsingData(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// items are reported by the names of their sources
+	encrypted := map[string]bool{"password": true, "cookie": true, "creditcard": true}
+	var decryptFailed int
+	for _, r := range data.Report() {
+		switch {
+		case encrypted[r.Item]:
+			decryptFailed++
+			if r.Status != browingdata.StatusDecryptFailed || r.Error == "" {
+				t.Errorf("item %s %s: %q, want %s with the key error", r.Item, r.Status, r.Error, browingdata.StatusDecryptFailed)
+			}
+		case r.Failed():
+			t.Errorf("item %s %s: %s", r.Item, r.Status, r.Error)
+		case r.Item == item.ChromiumHistory.String() && r.Rows == 0:
+			t.Errorf("item %s has no rows", r.Item)
+		}
+	}
+	if decryptFailed != len(encrypted) {
+		t.Errorf("reported %d encrypted items, want %d", decryptFailed, len(encrypted))
+	}
+}
+
+// TestFirefoxSessions reads sessionstore.jsonlz4 of a profile without the
+// sessionstore-backups dir, and previous.jsonlz4 next to recovery.jsonlz4
+func TestFirefoxSessions(t *testing.T) {
+	sessions := func(move func(dir string) error) []session.Navigation {
+		t.Helper()
+		dir := t.TempDir()
+		if err := testfixture.Firefox(dir, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := move(dir); err != nil {
+			t.Fatal(err)
+		}
+		browsers, err := firefox.New("firefox", "", dir, []item.Item{item.FirefoxHistory, item.FirefoxSession}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(browsers) != 1 {
+			t.Fatalf("found %d browsers, want 1", len(browsers))
+		}
+		data, err := browsers[0].BrowsingData(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, source := range data.Sources() {
+			if s, ok := source.(*session.FirefoxSession); ok {
+				return *s
+			}
+		}
+		t.Fatal("no session source")
+		return nil
+	}
+	recovery := sessions(func(string) error { return nil })
+	withFile := func(file string) []session.Navigation {
+		l := make([]session.Navigation, len(recovery))
+		for i, n := range recovery {
+			n.File = file
+			l[i] = n
+		}
+		return l
+	}
+	saved := sessions(func(dir string) error {
+		backups := filepath.Join(dir, "sessionstore-backups")
+		if err := os.Rename(filepath.Join(backups, "recovery.jsonlz4"), filepath.Join(dir, "sessionstore.jsonlz4")); err != nil {
+			return err
+		}
+		return os.Remove(backups)
+	})
+	if want := withFile("sessionstore.jsonlz4"); len(want) == 0 || !reflect.DeepEqual(saved, want) {
+		t.Errorf("sessions without sessionstore-backups = %+v, want %+v", saved, want)
+	}
+	previous := sessions(func(dir string) error {
+		backups := filepath.Join(dir, "sessionstore-backups")
+		b, err := os.ReadFile(filepath.Join(backups, "recovery.jsonlz4"))
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(backups, "previous.jsonlz4"), b, 0o600)
+	})
+	if want := append(recovery, withFile("previous.jsonlz4")...); !reflect.DeepEqual(previous, want) {
+		t.Errorf("sessions with previous.jsonlz4 = %+v, want %+v", previous, want)
+	}
+}
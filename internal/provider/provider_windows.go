@@ -92,6 +92,7 @@ var (
 	}{
 		"firefox": {
 			name:        firefoxName,
+			storage:     firefoxStorageName,
 			profilePath: firefoxProfilePath,
 			items:       item.DefaultFirefox,
 		},
@@ -0,0 +1,123 @@
+package testfixture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// bdbBuckets is the number of buckets of BerkeleyDB files
+const bdbBuckets = 2
+
+// BerkeleyDB writes pairs as a Berkeley DB 1.85 hash file of little endian
+// pages of pageSize bytes. Pairs are spread over two buckets regardless of
+// their hash, a bucket holding more than a page continues on overflow pages.
+func BerkeleyDB(path string, pairs map[string][]byte, pageSize int) error {
+	keys := make([]string, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buckets := make([][]string, bdbBuckets)
+	for i, k := range keys {
+		buckets[i%bdbBuckets] = append(buckets[i%bdbBuckets], k)
+	}
+
+	// page 1 is bucket 0, then the overflow bitmap and overflow pages of
+	// split point 0, bucket 1 and its overflow pages of split point 1
+	chain0, err := bdbChain(buckets[0], pairs, pageSize, func(i int) uint16 { return uint16(1 + i) })
+	if err != nil {
+		return err
+	}
+	chain1, err := bdbChain(buckets[1], pairs, pageSize, func(i int) uint16 { return uint16(1<<11 | i) })
+	if err != nil {
+		return err
+	}
+	bitmap := make([]byte, pageSize)
+	pages := append([][]byte{bdbHeader(pageSize, len(keys), len(chain0))}, chain0[0], bitmap)
+	pages = append(append(pages, chain0[1:]...), chain1...)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, p := range pages {
+		if _, err := f.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bdbHeader returns the big endian header page, overflow pages of split
+// point 0 are the bitmap and the overflow pages of bucket 0
+func bdbHeader(pageSize, nKeys, chain0 int) []byte {
+	fields := []int{
+		0x061561, 2, 1234, pageSize, log2(pageSize), 256, 256, 8,
+		1, 0, bdbBuckets - 1, 1, 0, 65536, nKeys, 1, 0,
+	}
+	h := make([]byte, pageSize)
+	for i, v := range fields {
+		binary.BigEndian.PutUint32(h[i*4:], uint32(v))
+	}
+	spares := len(fields) * 4
+	for i := 0; i < 32; i++ {
+		binary.BigEndian.PutUint32(h[spares+i*4:], uint32(chain0))
+	}
+	return h
+}
+
+func log2(n int) int {
+	i := 0
+	for 1<<i < n {
+		i++
+	}
+	return i
+}
+
+// bdbChain returns the pages holding keys, a full page points to the
+// next one by its overflow address oaddr(i), i counts from 1
+func bdbChain(keys []string, pairs map[string][]byte, pageSize int, oaddr func(i int) uint16) ([][]byte, error) {
+	var (
+		pages   [][]byte
+		offsets []uint16
+		off     = pageSize
+		page    = make([]byte, pageSize)
+	)
+	flush := func() {
+		n := len(offsets)
+		binary.LittleEndian.PutUint16(page, uint16(n))
+		for i, o := range offsets {
+			binary.LittleEndian.PutUint16(page[2+i*2:], o)
+		}
+		binary.LittleEndian.PutUint16(page[2+n*2:], uint16(off-(n+3)*2))
+		binary.LittleEndian.PutUint16(page[4+n*2:], uint16(off))
+		pages = append(pages, page)
+	}
+	for _, k := range keys {
+		size := len(k) + len(pairs[k])
+		// the pair, its offsets, an overflow address and the page info must fit
+		if (len(offsets)+7)*2+size > off {
+			if len(offsets) == 0 {
+				return nil, fmt.Errorf("pair %q is larger than a page", k)
+			}
+			offsets = append(offsets, oaddr(len(pages)+1), 0)
+			flush()
+			offsets, off, page = nil, pageSize, make([]byte, pageSize)
+		}
+		off -= len(k)
+		copy(page[off:], k)
+		offsets = append(offsets, uint16(off))
+		off -= len(pairs[k])
+		copy(page[off:], pairs[k])
+		offsets = append(offsets, uint16(off))
+	}
+	flush()
+	return pages, nil
+}
@@ -0,0 +1,223 @@
+package testfixture
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+)
+
+// webkit returns the microseconds since 1601 chromium stores for unix
+func webkit(unix int64) int64 {
+	return (unix + 11644473600) * 1000000
+}
+
+// Chromium writes a user data dir with Local State and a Default profile
+// under dir, secrets of the profile are encrypted with key.
+func Chromium(dir string, key []byte) error {
+	return chromium(dir, key, chromiumSessions)
+}
+
+// ChromiumLegacy is Chromium with the session files of chromium 99 and
+// earlier, Current Session and Current Tabs in the profile dir.
+func ChromiumLegacy(dir string, key []byte) error {
+	return chromium(dir, key, chromiumLegacySessions)
+}
+
+func chromium(dir string, key []byte, sessions func(profile string, key []byte) error) error {
+	profile := filepath.Join(dir, "Default")
+	builders := []func(profile string, key []byte) error{
+		chromiumLogins,
+		chromiumCookies,
+		chromiumHistory,
+		chromiumWebData,
+		chromiumLocalStorage,
+		chromiumSessionStorage,
+		chromiumIndexedDB,
+		sessions,
+	}
+	for _, build := range builders {
+		if err := build(profile, key); err != nil {
+			return err
+		}
+	}
+	files := map[string]string{
+		filepath.Join(dir, "Local State"):     chromiumLocalState,
+		filepath.Join(profile, "Preferences"): `{"profile":{"name":"Person 1"}}`,
+		filepath.Join(profile, "Bookmarks"):   chromiumBookmarks,
+		filepath.Join(profile, "Extensions", "aapocclcgogkmnckokdopfmhonfmgoek", "0.10_0", "manifest.json"): chromiumManifest,
+	}
+	for path, content := range files {
+		if err := writeFile(path, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	chromiumLocalState = `{"os_crypt":{},"profile":{"info_cache":{"Default":{"name":"Person 1"}}}}`
+
+	chromiumBookmarks = `{
+  "roots": {
+    "bookmark_bar": {
+      "children": [
+        {"date_added": "13317102245000000", "id": "5", "name": "Go", "type": "url", "url": "https://go.dev/"},
+        {"children": [
+          {"date_added": "13317188645000000", "id": "7", "name": "SQLite", "type": "url", "url": "https://sqlite.org/"}
+        ], "date_added": "13317015845000000", "id": "6", "name": "Docs", "type": "folder"}
+      ],
+      "date_added": "13316929445000000", "id": "1", "name": "Bookmarks bar", "type": "folder"
+    },
+    "other": {"children": [], "date_added": "13316843045000000", "id": "2", "name": "Other bookmarks", "type": "folder"}
+  },
+  "version": 1
+}`
+
+	chromiumManifest = `{
+  "name": "Slides",
+  "description": "Create and edit presentations",
+  "version": "0.10",
+  "homepage_url": "https://docs.google.com/presentation/"
+}`
+)
+
+func chromiumMeta(version int) []stmt {
+	return []stmt{
+		{query: `CREATE TABLE meta (key LONGVARCHAR NOT NULL UNIQUE PRIMARY KEY, value LONGVARCHAR)`},
+		{query: `INSERT INTO meta VALUES ('version', ?), ('last_compatible_version', ?)`, args: []any{version, version}},
+	}
+}
+
+func chromiumLogins(profile string, key []byte) error {
+	stmts := append(chromiumMeta(35), stmt{query: `CREATE TABLE logins (origin_url VARCHAR NOT NULL, action_url VARCHAR,
+		username_element VARCHAR, username_value VARCHAR, password_element VARCHAR, password_value BLOB,
+		signon_realm VARCHAR NOT NULL, date_created INTEGER NOT NULL, blacklisted_by_user INTEGER NOT NULL,
+		scheme INTEGER NOT NULL, times_used INTEGER, date_last_used INTEGER NOT NULL DEFAULT 0)`})
+	logins := []struct {
+		url, user, password string
+		created             int64
+	}{
+		{"https://accounts.example.com/login", "alice", "correct horse", 1672628645},
+		{"https://shop.example.org/", "bob@example.org", "battery staple", 1675307045},
+	}
+	for _, l := range logins {
+		encrypted, err := EncryptChromium(key, []byte(l.password))
+		if err != nil {
+			return err
+		}
+		stmts = append(stmts, stmt{
+			query: `INSERT INTO logins VALUES (?, ?, 'username', ?, 'password', ?, ?, ?, 0, 0, 1, 0)`,
+			args:  []any{l.url, l.url, l.user, encrypted, l.url, webkit(l.created)},
+		})
+	}
+	return writeDB(filepath.Join(profile, "Login Data"), stmts...)
+}
+
+func chromiumCookies(profile string, key []byte) error {
+	stmts := append(chromiumMeta(24), stmt{query: `CREATE TABLE cookies (creation_utc INTEGER NOT NULL,
+		host_key TEXT NOT NULL, top_frame_site_key TEXT NOT NULL, name TEXT NOT NULL, value TEXT NOT NULL,
+		encrypted_value BLOB NOT NULL, path TEXT NOT NULL, expires_utc INTEGER NOT NULL, is_secure INTEGER NOT NULL,
+		is_httponly INTEGER NOT NULL, last_access_utc INTEGER NOT NULL, has_expires INTEGER NOT NULL,
+		is_persistent INTEGER NOT NULL, priority INTEGER NOT NULL, samesite INTEGER NOT NULL,
+		source_scheme INTEGER NOT NULL, source_port INTEGER NOT NULL, last_update_utc INTEGER NOT NULL)`})
+	cookies := []struct {
+		host, partition, name, value, path string
+		expires                            int64
+		secure, httpOnly, sameSite         int
+	}{
+		{".example.com", "", "sid", "s3cr3t", "/", 1767225600, 1, 1, 1},
+		{"shop.example.org", "", "cart", "42", "/cart", 0, 0, 0, -1},
+		{".cdn.example.net", "https://example.com", "__Host-pt", "p", "/", 1767225600, 1, 0, 0},
+	}
+	for i, c := range cookies {
+		// cookies of version 24 are prefixed with the SHA256 of their host
+		hash := sha256.Sum256([]byte(c.host))
+		encrypted, err := EncryptChromium(key, append(hash[:], c.value...))
+		if err != nil {
+			return err
+		}
+		var expires int64
+		if c.expires > 0 {
+			expires = webkit(c.expires)
+		}
+		created := webkit(1672628645 + int64(i)*3600)
+		stmts = append(stmts, stmt{
+			query: `INSERT INTO cookies VALUES (?, ?, ?, ?, '', ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, 2, 443, ?)`,
+			args: []any{created, c.host, c.partition, c.name, encrypted, c.path, expires, c.secure, c.httpOnly,
+				created, boolInt(expires > 0), boolInt(expires > 0), c.sameSite, created},
+		})
+	}
+	return writeDB(filepath.Join(profile, "Network", "Cookies"), stmts...)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func chromiumHistory(profile string, _ []byte) error {
+	stmts := append(chromiumMeta(66),
+		stmt{query: `CREATE TABLE urls (id INTEGER PRIMARY KEY AUTOINCREMENT, url LONGVARCHAR, title LONGVARCHAR,
+			visit_count INTEGER DEFAULT 0 NOT NULL, typed_count INTEGER DEFAULT 0 NOT NULL,
+			last_visit_time INTEGER NOT NULL, hidden INTEGER DEFAULT 0 NOT NULL)`},
+		stmt{query: `CREATE TABLE visits (id INTEGER PRIMARY KEY, url INTEGER NOT NULL, visit_time INTEGER NOT NULL,
+			from_visit INTEGER, transition INTEGER DEFAULT 0 NOT NULL, segment_id INTEGER,
+			visit_duration INTEGER DEFAULT 0 NOT NULL)`},
+		stmt{query: `CREATE TABLE downloads (id INTEGER PRIMARY KEY, guid VARCHAR NOT NULL, current_path LONGVARCHAR NOT NULL,
+			target_path LONGVARCHAR NOT NULL, start_time INTEGER NOT NULL, received_bytes INTEGER NOT NULL,
+			total_bytes INTEGER NOT NULL, state INTEGER NOT NULL, end_time INTEGER NOT NULL, opened INTEGER NOT NULL,
+			referrer VARCHAR NOT NULL, tab_url VARCHAR NOT NULL, mime_type VARCHAR(255) NOT NULL)`},
+		stmt{query: `INSERT INTO urls VALUES (1, 'https://go.dev/', 'The Go Programming Language', 2, 1, ?, 0),
+			(2, 'https://go.dev/doc/', 'Documentation', 1, 0, ?, 0)`,
+			args: []any{webkit(1672632245), webkit(1672628705)}},
+		// the second visit follows a link of the first one
+		stmt{query: `INSERT INTO visits VALUES (1, 1, ?, 0, 805306369, 0, 60000000), (2, 2, ?, 1, 805306368, 0, 5000000),
+			(3, 1, ?, 0, 805306376, 0, 0)`,
+			args: []any{webkit(1672628645), webkit(1672628705), webkit(1672632245)}},
+		stmt{query: `INSERT INTO downloads VALUES (1, 'a1b2', '/home/alice/Downloads/go1.20.linux-amd64.tar.gz',
+			'/home/alice/Downloads/go1.20.linux-amd64.tar.gz', ?, 99869470, 99869470, 1, ?, 0,
+			'https://go.dev/dl/', 'https://go.dev/dl/', 'application/x-gzip')`,
+			args: []any{webkit(1672628765), webkit(1672628825)}},
+		stmt{query: `CREATE TABLE keyword_search_terms (keyword_id INTEGER NOT NULL, url_id INTEGER NOT NULL,
+			term LONGVARCHAR NOT NULL, normalized_term LONGVARCHAR NOT NULL)`},
+		stmt{query: `INSERT INTO urls VALUES (3, 'https://www.google.com/search?q=Go+Generics', 'Go Generics - Google Search', 1, 1, ?, 0)`,
+			args: []any{webkit(1672628675)}},
+		stmt{query: `INSERT INTO keyword_search_terms VALUES (2, 3, 'Go Generics', 'go generics')`},
+	)
+	return writeDB(filepath.Join(profile, "History"), stmts...)
+}
+
+func chromiumWebData(profile string, key []byte) error {
+	number, err := EncryptChromium(key, []byte("4111111111111111"))
+	if err != nil {
+		return err
+	}
+	stmts := append(chromiumMeta(108),
+		stmt{query: `CREATE TABLE credit_cards (guid VARCHAR PRIMARY KEY, name_on_card VARCHAR,
+			expiration_month INTEGER, expiration_year INTEGER, card_number_encrypted BLOB, date_modified INTEGER NOT NULL DEFAULT 0,
+			origin VARCHAR DEFAULT '', use_count INTEGER NOT NULL DEFAULT 0, use_date INTEGER NOT NULL DEFAULT 0,
+			billing_address_id VARCHAR, nickname VARCHAR)`},
+		stmt{query: `INSERT INTO credit_cards (guid, name_on_card, expiration_month, expiration_year, card_number_encrypted,
+			billing_address_id, nickname) VALUES ('00000000-0000-4000-8000-000000000001', 'Alice Liddell', 12, 2030, ?, '', 'Visa')`,
+			args: []any{number}},
+		stmt{query: `CREATE TABLE autofill_profiles (guid VARCHAR PRIMARY KEY, company_name VARCHAR, street_address VARCHAR,
+			dependent_locality VARCHAR, city VARCHAR, state VARCHAR, zipcode VARCHAR, sorting_code VARCHAR, country_code VARCHAR,
+			date_modified INTEGER NOT NULL DEFAULT 0, origin VARCHAR DEFAULT '', language_code VARCHAR, use_count INTEGER NOT NULL DEFAULT 0,
+			use_date INTEGER NOT NULL DEFAULT 0, label VARCHAR, disallow_settings_visible_updates INTEGER NOT NULL DEFAULT 0)`},
+		stmt{query: `CREATE TABLE autofill_profile_names (guid VARCHAR, first_name VARCHAR, middle_name VARCHAR,
+			last_name VARCHAR, full_name VARCHAR)`},
+		stmt{query: `CREATE TABLE autofill_profile_emails (guid VARCHAR, email VARCHAR)`},
+		stmt{query: `CREATE TABLE autofill_profile_phones (guid VARCHAR, number VARCHAR)`},
+		stmt{query: `INSERT INTO autofill_profiles (guid, company_name, street_address, city, state, zipcode, country_code, date_modified)
+			VALUES ('00000000-0000-4000-8000-000000000002', 'Wonderland Ltd', '1 Rabbit Hole', 'Oxford', 'Oxfordshire', 'OX1 1DP', 'GB', 1672628645)`},
+		stmt{query: `INSERT INTO autofill_profile_names VALUES ('00000000-0000-4000-8000-000000000002', 'Alice', '', 'Liddell', '')`},
+		stmt{query: `INSERT INTO autofill_profile_emails VALUES ('00000000-0000-4000-8000-000000000002', 'alice@example.com')`},
+		stmt{query: `INSERT INTO autofill_profile_phones VALUES ('00000000-0000-4000-8000-000000000002', '+441865000000')`},
+		stmt{query: `CREATE TABLE autofill (name VARCHAR, value VARCHAR, value_lower VARCHAR, date_created INTEGER DEFAULT 0,
+			date_last_used INTEGER DEFAULT 0, count INTEGER DEFAULT 1, PRIMARY KEY (name, value))`},
+		stmt{query: `INSERT INTO autofill VALUES ('email', 'alice@example.com', 'alice@example.com', 1672628645, 1675307045, 3),
+			('q', 'golang generics', 'golang generics', 1672628705, 1672628705, 1)`},
+	)
+	return writeDB(filepath.Join(profile, "Web Data"), stmts...)
+}
@@ -0,0 +1,302 @@
+package testfixture
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+var (
+	// firefoxGlobalSalt is item1 of key4.db, every key is derived with it
+	firefoxGlobalSalt = []byte("hack-browser-data-salt")
+	// firefoxLoginKey is the 3DES key of logins.json kept in nssPrivate
+	firefoxLoginKey = []byte("0123456789abcdefghijklmn")
+	// firefoxKeyID is the CKA_ID of the login key, nssPrivate.a102
+	firefoxKeyID = []byte{248, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
+)
+
+// firefoxIterations is the PBKDF2 iteration count of Firefox 75 and later
+const firefoxIterations = 10000
+
+// pbes2 is an entry of key4.db encrypted with PBKDF2-HMAC-SHA256 and AES-256-CBC
+type pbes2 struct {
+	Algorithm struct {
+		OID    asn1.ObjectIdentifier
+		Params struct {
+			KDF struct {
+				OID    asn1.ObjectIdentifier
+				Params struct {
+					Salt       []byte
+					Iterations int
+					KeySize    int
+					PRF        struct{ OID asn1.ObjectIdentifier }
+				}
+			}
+			Cipher struct {
+				OID asn1.ObjectIdentifier
+				IV  []byte
+			}
+		}
+	}
+	Encrypted []byte
+}
+
+// encryptPBES2 encrypts plaintext as Firefox 75+ does for key4.db, the key
+// is derived from globalSalt and the primary password.
+func encryptPBES2(primaryPassword, entrySalt, plaintext []byte) ([]byte, error) {
+	hp := sha1.Sum(append(append([]byte{}, firefoxGlobalSalt...), primaryPassword...))
+	key := pbkdf2.Key(hp[:], entrySalt, firefoxIterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	// NSS stores 14 bytes of the IV, the first two are the DER header of the octet string
+	iv := bytes.Repeat([]byte{0x11}, 14)
+	padded := pkcs7Pad(plaintext, aes.BlockSize)
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, append([]byte{4, 14}, iv...)).CryptBlocks(encrypted, padded)
+
+	var p pbes2
+	p.Algorithm.OID = oidPBES2
+	p.Algorithm.Params.KDF.OID = oidPBKDF2
+	p.Algorithm.Params.KDF.Params.Salt = entrySalt
+	p.Algorithm.Params.KDF.Params.Iterations = firefoxIterations
+	p.Algorithm.Params.KDF.Params.KeySize = len(key)
+	p.Algorithm.Params.KDF.Params.PRF.OID = oidHMACWithSHA256
+	p.Algorithm.Params.Cipher.OID = oidAES256CBC
+	p.Algorithm.Params.Cipher.IV = iv
+	p.Encrypted = encrypted
+	return asn1.Marshal(p)
+}
+
+// loginBlob is an encrypted username or password of logins.json
+type loginBlob struct {
+	KeyID  []byte
+	Cipher struct {
+		OID asn1.ObjectIdentifier
+		IV  []byte
+	}
+	Encrypted []byte
+}
+
+func encryptLogin(plaintext []byte) (string, error) {
+	block, err := des.NewTripleDESCipher(firefoxLoginKey)
+	if err != nil {
+		return "", err
+	}
+	var l loginBlob
+	l.KeyID = firefoxKeyID
+	l.Cipher.OID = oidDESEDE3CBC
+	l.Cipher.IV = bytes.Repeat([]byte{0x22}, des.BlockSize)
+	padded := pkcs7Pad(plaintext, des.BlockSize)
+	l.Encrypted = make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, l.Cipher.IV).CryptBlocks(l.Encrypted, padded)
+	b, err := asn1.Marshal(l)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Firefox writes a profile dir at dir, key4.db is protected by
+// primaryPassword, which is empty if the user never set one.
+func Firefox(dir string, primaryPassword []byte) error {
+	builders := []func(dir string, primaryPassword []byte) error{
+		firefoxKey4,
+		firefoxLogins,
+		firefoxPlaces,
+		firefoxCookies,
+		firefoxStorage,
+		firefoxAutofill,
+		firefoxSession,
+		firefoxFormHistory,
+		firefoxWebappsStore,
+	}
+	for _, build := range builders {
+		if err := build(dir, primaryPassword); err != nil {
+			return err
+		}
+	}
+	files := map[string]string{
+		filepath.Join(dir, "prefs.js"):        `user_pref("browser.startup.homepage", "https://go.dev/");` + "\n",
+		filepath.Join(dir, "extensions.json"): firefoxExtensions,
+	}
+	for path, content := range files {
+		if err := writeFile(path, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const firefoxExtensions = `{"schemaVersion":35,"addons":[{"id":"uBlock0@raymondhill.net","version":"1.46.0",
+"defaultLocale":{"name":"uBlock Origin","description":"Finally, an efficient blocker.","homepageURL":"https://github.com/gorhill/uBlock"}}]}`
+
+func firefoxKey4(dir string, primaryPassword []byte) error {
+	check, err := encryptPBES2(primaryPassword, bytes.Repeat([]byte{0x33}, 32), []byte("password-check"))
+	if err != nil {
+		return err
+	}
+	loginKey, err := encryptPBES2(primaryPassword, bytes.Repeat([]byte{0x44}, 32), firefoxLoginKey)
+	if err != nil {
+		return err
+	}
+	return writeDB(filepath.Join(dir, "key4.db"),
+		stmt{query: `CREATE TABLE metaData (id PRIMARY KEY UNIQUE ON CONFLICT REPLACE, item1, item2)`},
+		stmt{query: `INSERT INTO metaData VALUES ('password', ?, ?)`, args: []any{firefoxGlobalSalt, check}},
+		stmt{query: `CREATE TABLE nssPrivate (id PRIMARY KEY UNIQUE ON CONFLICT ABORT, a0, a1, a2, a3, a10, a11, a12, a102)`},
+		stmt{query: `INSERT INTO nssPrivate (id, a0, a11, a102) VALUES (1, 3, ?, ?)`, args: []any{loginKey, firefoxKeyID}},
+	)
+}
+
+// firefoxLoginRecords are the logins of logins.json and signons.sqlite
+var firefoxLoginRecords = []struct {
+	url, user, password string
+	created             int64
+}{
+	{"https://accounts.example.com", "alice", "correct horse", 1672628645000},
+	{"https://shop.example.org", "bob@example.org", "battery staple", 1675307045000},
+}
+
+func firefoxLogins(dir string, _ []byte) error {
+	type login struct {
+		ID                int    `json:"id"`
+		Hostname          string `json:"hostname"`
+		FormSubmitURL     string `json:"formSubmitURL"`
+		EncryptedUsername string `json:"encryptedUsername"`
+		EncryptedPassword string `json:"encryptedPassword"`
+		TimeCreated       int64  `json:"timeCreated"`
+	}
+	var l []login
+	for i, v := range firefoxLoginRecords {
+		user, err := encryptLogin([]byte(v.user))
+		if err != nil {
+			return err
+		}
+		password, err := encryptLogin([]byte(v.password))
+		if err != nil {
+			return err
+		}
+		l = append(l, login{
+			ID:                i + 1,
+			Hostname:          v.url,
+			FormSubmitURL:     v.url,
+			EncryptedUsername: user,
+			EncryptedPassword: password,
+			TimeCreated:       v.created,
+		})
+	}
+	b, err := json.Marshal(map[string]any{"nextId": len(l) + 1, "logins": l, "version": 3})
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "logins.json"), string(b))
+}
+
+// firefoxAutofill writes an address and a credit card, the number is
+// encrypted like logins
+func firefoxAutofill(dir string, _ []byte) error {
+	number, err := encryptLogin([]byte("5555555555554444"))
+	if err != nil {
+		return err
+	}
+	// newer Firefox encrypts numbers with the key of the OS key store
+	osNumber, err := EncryptFirefoxOSKeyStore([]byte("4000056655665556"))
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(map[string]any{
+		"version": 1,
+		"addresses": []map[string]any{{
+			"guid": "a1b2c3d4e5f6", "given-name": "Bob", "family-name": "Builder", "organization": "Example Org",
+			"street-address": "2 Example Street", "address-level2": "Springfield", "address-level1": "IL",
+			"postal-code": "62701", "country": "US", "tel": "+12175550100", "email": "bob@example.org",
+			"timeCreated": 1672628645000, "timeLastModified": 1675307045000,
+		}},
+		"creditCards": []map[string]any{{
+			"guid": "f6e5d4c3b2a1", "cc-name": "Bob Builder", "cc-exp-month": 4, "cc-exp-year": 2031,
+			"cc-number-encrypted": number, "billingAddressGUID": "a1b2c3d4e5f6",
+			"timeCreated": 1672628645000, "timeLastModified": 1675307045000,
+		}, {
+			"guid": "0a1b2c3d4e5f", "cc-name": "Bob Builder", "cc-exp-month": 9, "cc-exp-year": 2032,
+			"cc-number-encrypted": osNumber, "timeCreated": 1675307045000, "timeLastModified": 1675307045000,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "autofill-profiles.json"), string(b))
+}
+
+func firefoxPlaces(dir string, _ []byte) error {
+	return writeDB(filepath.Join(dir, "places.sqlite"),
+		stmt{query: `CREATE TABLE moz_places (id INTEGER PRIMARY KEY, url LONGVARCHAR, title LONGVARCHAR,
+			rev_host LONGVARCHAR, visit_count INTEGER DEFAULT 0, hidden INTEGER DEFAULT 0 NOT NULL,
+			typed INTEGER DEFAULT 0 NOT NULL, frecency INTEGER DEFAULT -1 NOT NULL, last_visit_date INTEGER,
+			guid TEXT, foreign_count INTEGER DEFAULT 0 NOT NULL, url_hash INTEGER DEFAULT 0 NOT NULL)`},
+		stmt{query: `CREATE TABLE moz_historyvisits (id INTEGER PRIMARY KEY, from_visit INTEGER, place_id INTEGER,
+			visit_date INTEGER, visit_type INTEGER, session INTEGER)`},
+		stmt{query: `CREATE TABLE moz_bookmarks (id INTEGER PRIMARY KEY, type INTEGER, fk INTEGER DEFAULT NULL,
+			parent INTEGER, position INTEGER, title LONGVARCHAR, keyword_id INTEGER, folder_type TEXT,
+			dateAdded INTEGER, lastModified INTEGER, guid TEXT)`},
+		stmt{query: `CREATE TABLE moz_annos (id INTEGER PRIMARY KEY, place_id INTEGER NOT NULL, anno_attribute_id INTEGER,
+			content LONGVARCHAR, flags INTEGER DEFAULT 0, expiration INTEGER DEFAULT 0, type INTEGER DEFAULT 0,
+			dateAdded INTEGER DEFAULT 0, lastModified INTEGER DEFAULT 0)`},
+		stmt{query: `INSERT INTO moz_places (id, url, title, rev_host, visit_count, typed, last_visit_date, guid) VALUES
+			(1, 'https://www.mozilla.org/', 'Mozilla', 'gro.allizom.www.', 2, 1, 1672632245000000, 'p1'),
+			(2, 'https://www.mozilla.org/firefox/', 'Firefox', 'gro.allizom.www.', 1, 0, 1672628705000000, 'p2'),
+			(3, 'https://ftp.mozilla.org/firefox-110.0.tar.bz2', NULL, 'gro.allizom.ptf.', 0, 0, NULL, 'p3'),
+			(4, 'https://duckduckgo.com/?q=firefox+profile&t=ffab', 'firefox profile at DuckDuckGo', 'moc.ogkcudkcud.', 1, 0, 1672628675000000, 'p4'),
+			(5, 'https://www.google.com/maps', 'Google Maps', 'moc.elgoog.www.', 1, 0, 1672628685000000, 'p5'),
+			(6, 'https://www.google.co.uk/search?q=sqlite+wal', 'sqlite wal - Google Search', 'ku.oc.elgoog.www.', 1, 0, 1672628695000000, 'p6'),
+			(7, 'https://google.example.com/search?q=lookalike', 'Example', 'moc.elpmaxe.elgoog.', 1, 0, 1672628665000000, 'p7')`},
+		stmt{query: `INSERT INTO moz_historyvisits VALUES (1, 0, 1, 1672628645000000, 1, 0), (2, 1, 2, 1672628705000000, 1, 0),
+			(3, 0, 1, 1672632245000000, 2, 0)`},
+		stmt{query: `INSERT INTO moz_bookmarks VALUES (1, 2, NULL, 0, 0, '', NULL, NULL, 1672542245000000, 1672542245000000, 'root________'),
+			(2, 1, 1, 1, 0, 'Mozilla', NULL, NULL, 1672628645000000, 1672628645000000, 'b1'),
+			(3, 1, 2, 1, 1, 'Firefox', NULL, NULL, 1672715045000000, 1672715045000000, 'b2')`},
+		stmt{query: `INSERT INTO moz_annos (place_id, anno_attribute_id, content, dateAdded) VALUES
+			(3, 1, 'file:///home/alice/Downloads/firefox-110.0.tar.bz2', 1672628765000000),
+			(3, 2, '{"state":1,"endTime":1672628825000,"fileSize":81240832}', 1672628765000000)`},
+	)
+}
+
+func firefoxFormHistory(dir string, _ []byte) error {
+	return writeDB(filepath.Join(dir, "formhistory.sqlite"),
+		stmt{query: `CREATE TABLE moz_formhistory (id INTEGER PRIMARY KEY, fieldname TEXT NOT NULL, value TEXT NOT NULL,
+			timesUsed INTEGER, firstUsed INTEGER, lastUsed INTEGER, guid TEXT)`},
+		stmt{query: `INSERT INTO moz_formhistory VALUES (1, 'searchbar-history', 'firefox profile', 2, 1672628675000000, 1675307045000000, 'f1'),
+			(2, 'email', 'bob@example.org', 1, 1672628645000000, 1672628645000000, 'f2')`},
+	)
+}
+
+func firefoxCookies(dir string, _ []byte) error {
+	return writeDB(filepath.Join(dir, "cookies.sqlite"),
+		stmt{query: `PRAGMA user_version = 12`},
+		stmt{query: `CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, originAttributes TEXT NOT NULL DEFAULT '',
+			name TEXT, value TEXT, host TEXT, path TEXT, expiry INTEGER, lastAccessed INTEGER, creationTime INTEGER,
+			isSecure INTEGER, isHttpOnly INTEGER, inBrowserElement INTEGER DEFAULT 0, sameSite INTEGER DEFAULT 0,
+			rawSameSite INTEGER DEFAULT 0, schemeMap INTEGER DEFAULT 0)`},
+		stmt{query: `INSERT INTO moz_cookies (id, originAttributes, name, value, host, path, expiry, lastAccessed, creationTime,
+			isSecure, isHttpOnly, sameSite) VALUES
+			(1, '', 'sid', 's3cr3t', '.mozilla.org', '/', 1767225600, 1672632245000000, 1672628645000000, 1, 1, 1),
+			(2, '^partitionKey=%28https%2Cexample.com%29', 'embed', '1', 'player.example.net', '/', 1767225600, 1672628705000000, 1672628705000000, 1, 0, 0)`},
+	)
+}
@@ -0,0 +1,168 @@
+package testfixture
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/asn1"
+	"math/big"
+	"path/filepath"
+)
+
+var (
+	oidPBEWithSHA1And3DES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 5, 1, 3}
+	oidRSAEncryption      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+)
+
+// encryptPKCS12 encrypts plaintext with pbeWithSha1AndTripleDES-CBC as NSS
+// does in key3.db, the key is derived from globalSalt and the primary password.
+func encryptPKCS12(primaryPassword, entrySalt, plaintext []byte) ([]byte, error) {
+	hp := sha1.Sum(append(append([]byte{}, firefoxGlobalSalt...), primaryPassword...))
+	chp := sha1.Sum(append(hp[:], entrySalt...))
+	pes := append(append([]byte{}, entrySalt...), make([]byte, 20-len(entrySalt))...)
+	mac := func(data ...[]byte) []byte {
+		h := hmac.New(sha1.New, chp[:])
+		for _, d := range data {
+			h.Write(d)
+		}
+		return h.Sum(nil)
+	}
+	k := append(mac(pes, entrySalt), mac(mac(pes), entrySalt)...)
+	block, err := des.NewTripleDESCipher(k[:24])
+	if err != nil {
+		return nil, err
+	}
+	padded := pkcs7Pad(plaintext, des.BlockSize)
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, k[len(k)-8:]).CryptBlocks(encrypted, padded)
+	return encrypted, nil
+}
+
+// key3Entry is a version, the lengths of salt and name, salt, name and data
+func key3Entry(salt, name, data []byte) []byte {
+	entry := append([]byte{3, byte(len(salt)), byte(len(name))}, salt...)
+	return append(append(entry, name...), data...)
+}
+
+// FirefoxLegacy writes a profile dir of Firefox 31 at dir, logins are in
+// signons.sqlite and their key in key3.db, protected by primaryPassword,
+// local storage is in webappsstore.sqlite.
+func FirefoxLegacy(dir string, primaryPassword []byte) error {
+	builders := []func(dir string, primaryPassword []byte) error{
+		firefoxKey3,
+		firefoxSignons,
+		firefoxWebappsStore,
+	}
+	for _, build := range builders {
+		if err := build(dir, primaryPassword); err != nil {
+			return err
+		}
+	}
+	return writeFile(filepath.Join(dir, "prefs.js"), `user_pref("browser.startup.homepage", "https://go.dev/");`+"\n")
+}
+
+func firefoxKey3(dir string, primaryPassword []byte) error {
+	checkSalt := bytes.Repeat([]byte{0x55}, 16)
+	check, err := encryptPKCS12(primaryPassword, checkSalt, []byte("password-check"))
+	if err != nil {
+		return err
+	}
+	oid, err := asn1.Marshal(oidPBEWithSHA1And3DES)
+	if err != nil {
+		return err
+	}
+
+	// NSS keeps the 3DES key of logins as the private exponent of an RSA key
+	var rsaKey struct {
+		Version                                  int
+		Modulus, PublicExponent, PrivateExponent *big.Int
+	}
+	rsaKey.Modulus = new(big.Int).SetBytes(firefoxKeyID)
+	rsaKey.PublicExponent = big.NewInt(1)
+	rsaKey.PrivateExponent = new(big.Int).SetBytes(firefoxLoginKey)
+	var info struct {
+		Version   int
+		Algorithm struct {
+			OID        asn1.ObjectIdentifier
+			Parameters asn1.RawValue
+		}
+		PrivateKey []byte
+	}
+	info.Algorithm.OID = oidRSAEncryption
+	info.Algorithm.Parameters = asn1.NullRawValue
+	if info.PrivateKey, err = asn1.Marshal(rsaKey); err != nil {
+		return err
+	}
+	privateKeyInfo, err := asn1.Marshal(info)
+	if err != nil {
+		return err
+	}
+	keySalt := bytes.Repeat([]byte{0x66}, 20)
+	var encryptedKey struct {
+		Algorithm struct {
+			OID    asn1.ObjectIdentifier
+			Params struct {
+				Salt       []byte
+				Iterations int
+			}
+		}
+		Encrypted []byte
+	}
+	encryptedKey.Algorithm.OID = oidPBEWithSHA1And3DES
+	encryptedKey.Algorithm.Params.Salt = keySalt
+	encryptedKey.Algorithm.Params.Iterations = 1
+	if encryptedKey.Encrypted, err = encryptPKCS12(primaryPassword, keySalt, privateKeyInfo); err != nil {
+		return err
+	}
+	key, err := asn1.Marshal(encryptedKey)
+	if err != nil {
+		return err
+	}
+
+	return BerkeleyDB(filepath.Join(dir, "key3.db"), map[string][]byte{
+		"Version":            {3},
+		"global-salt":        firefoxGlobalSalt,
+		"password-check":     key3Entry(checkSalt, oid, check),
+		string(firefoxKeyID): key3Entry(keySalt, []byte("Software Security Device"), key),
+	}, 4096)
+}
+
+func firefoxSignons(dir string, _ []byte) error {
+	stmts := []stmt{
+		{query: `PRAGMA user_version = 5`},
+		{query: `CREATE TABLE moz_logins (id INTEGER PRIMARY KEY, hostname TEXT NOT NULL, httpRealm TEXT,
+			formSubmitURL TEXT, usernameField TEXT NOT NULL, passwordField TEXT NOT NULL,
+			encryptedUsername TEXT NOT NULL, encryptedPassword TEXT NOT NULL, guid TEXT, encType INTEGER,
+			timeCreated INTEGER, timeLastUsed INTEGER, timePasswordChanged INTEGER, timesUsed INTEGER)`},
+	}
+	for i, v := range firefoxLoginRecords {
+		user, err := encryptLogin([]byte(v.user))
+		if err != nil {
+			return err
+		}
+		password, err := encryptLogin([]byte(v.password))
+		if err != nil {
+			return err
+		}
+		// the second login is of HTTP authentication, it has no form
+		var formSubmitURL any = v.url
+		if i == 1 {
+			formSubmitURL = nil
+		}
+		stmts = append(stmts, stmt{
+			query: `INSERT INTO moz_logins (id, hostname, formSubmitURL, usernameField, passwordField,
+				encryptedUsername, encryptedPassword, encType, timeCreated) VALUES (?, ?, ?, '', '', ?, ?, 1, ?)`,
+			args: []any{i + 1, v.url, formSubmitURL, user, password, v.created},
+		})
+	}
+	return writeDB(filepath.Join(dir, "signons.sqlite"), stmts...)
+}
+
+func firefoxWebappsStore(dir string, _ []byte) error {
+	return writeDB(filepath.Join(dir, "webappsstore.sqlite"),
+		stmt{query: `CREATE TABLE webappsstore2 (originAttributes TEXT, originKey TEXT, scope TEXT, key TEXT, value TEXT)`},
+		stmt{query: `INSERT INTO webappsstore2 VALUES ('', 'gro.allizom.www.:https:443', '', 'theme', 'dark')`},
+	)
+}
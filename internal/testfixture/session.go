@@ -0,0 +1,179 @@
+package testfixture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"path/filepath"
+	"unicode/utf16"
+)
+
+// MozLz4 returns content as a mozLz4 file, the LZ4 block has literals only
+func MozLz4(content []byte) []byte {
+	b := []byte("mozLz40\x00")
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(content)))
+	n := len(content)
+	if n < 0xf {
+		b = append(b, byte(n<<4))
+	} else {
+		b = append(b, 0xf0)
+		for n -= 0xf; n >= 0xff; n -= 0xff {
+			b = append(b, 0xff)
+		}
+		b = append(b, byte(n))
+	}
+	return append(b, content...)
+}
+
+// pickle writes a base::Pickle of chromium, fields are aligned to 4 bytes
+type pickle struct {
+	bytes.Buffer
+}
+
+func (p *pickle) int(v int32) {
+	_ = binary.Write(p, binary.LittleEndian, v)
+}
+
+func (p *pickle) int64(v int64) {
+	_ = binary.Write(p, binary.LittleEndian, v)
+}
+
+func (p *pickle) pad() {
+	for p.Len()%4 != 0 {
+		p.WriteByte(0)
+	}
+}
+
+func (p *pickle) string(s string) {
+	p.int(int32(len(s)))
+	p.WriteString(s)
+	p.pad()
+}
+
+func (p *pickle) string16(s string) {
+	u := utf16.Encode([]rune(s))
+	p.int(int32(len(u)))
+	_ = binary.Write(p, binary.LittleEndian, u)
+	p.pad()
+}
+
+func (p *pickle) payload() []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(p.Len())), p.Bytes()...)
+}
+
+// snss writes the commands of an SNSS file of version 1
+type snss struct {
+	bytes.Buffer
+}
+
+func newSNSS() *snss {
+	s := &snss{}
+	s.WriteString("SNSS")
+	_ = binary.Write(s, binary.LittleEndian, uint32(1))
+	return s
+}
+
+func (s *snss) command(id uint8, payload []byte) {
+	_ = binary.Write(s, binary.LittleEndian, uint16(len(payload)+1))
+	s.WriteByte(id)
+	s.Write(payload)
+}
+
+// idIndex is the payload of commands with a pair of int32
+func idIndex(id, index int32) []byte {
+	return binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, uint32(id)), uint32(index))
+}
+
+// idTime is the payload of commands with an int32 and an int64, aligned
+func idTime(id int32, t int64) []byte {
+	return binary.LittleEndian.AppendUint64(idIndex(id, 0), uint64(t))
+}
+
+func navigation(tabID, index int32, url, title, referrer string, timestamp int64) []byte {
+	var p pickle
+	p.int(tabID)
+	p.int(index)
+	p.string(url)
+	p.string16(title)
+	p.string("")
+	p.int(0)
+	p.int(0)
+	p.string(referrer)
+	p.int(0)
+	p.string(url)
+	p.int(0)
+	p.int64(timestamp)
+	return p.payload()
+}
+
+// chromiumSessions writes a session with an open window of a pinned tab and
+// a closed tab, and a recently closed tab
+func chromiumSessions(profile string, _ []byte) error {
+	dir := filepath.Join(profile, "Sessions")
+	return writeChromiumSessions(filepath.Join(dir, "Session_13317102245000000"), filepath.Join(dir, "Tabs_13317102245000000"))
+}
+
+// chromiumLegacySessions writes the sessions of chromiumSessions to the
+// profile dir as chromium 99 and earlier did
+func chromiumLegacySessions(profile string, _ []byte) error {
+	return writeChromiumSessions(filepath.Join(profile, "Current Session"), filepath.Join(profile, "Current Tabs"))
+}
+
+func writeChromiumSessions(session, closed string) error {
+	s := newSNSS()
+	s.command(0, idIndex(1, 1))
+	s.command(2, idIndex(1, 0))
+	s.command(6, navigation(1, 0, "https://go.dev/", "The Go Programming Language", "", webkit(1672628645)))
+	s.command(6, navigation(1, 1, "https://go.dev/doc/", "Documentation", "https://go.dev/", webkit(1672628705)))
+	s.command(7, idIndex(1, 1))
+	s.command(12, idIndex(1, 1))
+	s.command(21, idTime(1, webkit(1672632245)))
+	s.command(0, idIndex(1, 2))
+	s.command(2, idIndex(2, 1))
+	s.command(6, navigation(2, 0, "https://pkg.go.dev/", "Go Packages", "", webkit(1672628765)))
+	s.command(16, idTime(2, webkit(1672628825)))
+
+	tabs := newSNSS()
+	tabs.command(4, idTime(10, webkit(1672542245)))
+	tabs.command(1, navigation(10, 0, "https://sqlite.org/", "SQLite Home Page", "", webkit(1672542185)))
+
+	if err := writeFile(session, s.String()); err != nil {
+		return err
+	}
+	return writeFile(closed, tabs.String())
+}
+
+// firefoxSession writes recovery.jsonlz4 with a window of two tabs, one
+// of them closed, and a closed window
+func firefoxSession(dir string, _ []byte) error {
+	entry := func(url, title string) map[string]any {
+		return map[string]any{"url": url, "title": title}
+	}
+	session := map[string]any{
+		"version": []any{"sessionrestore", 1},
+		"windows": []any{map[string]any{
+			"tabs": []any{map[string]any{
+				"entries": []any{
+					entry("https://www.mozilla.org/", "Mozilla"),
+					entry("https://www.mozilla.org/firefox/", "Firefox"),
+				},
+				"index": 2, "lastAccessed": 1672632245000, "pinned": true,
+			}},
+			"selected": 1,
+			"_closedTabs": []any{map[string]any{
+				"state":    map[string]any{"entries": []any{entry("https://addons.mozilla.org/", "Add-ons")}, "index": 1, "lastAccessed": 1672628705000},
+				"closedAt": 1672628765000, "pos": 1, "closedId": 3,
+			}},
+		}},
+		"_closedWindows": []any{map[string]any{
+			"tabs":     []any{map[string]any{"entries": []any{entry("https://developer.mozilla.org/", "MDN")}, "index": 1, "lastAccessed": 1672542245000}},
+			"closedAt": 1672542305000,
+		}},
+		"session": map[string]any{"lastUpdate": 1672632245000, "startTime": 1672628645000},
+	}
+	b, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(dir, "sessionstore-backups", "recovery.jsonlz4"), string(MozLz4(b)))
+}
@@ -0,0 +1,207 @@
+package testfixture
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"unicode/utf16"
+
+	"github.com/golang/snappy"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// writeLevelDB writes entries into a new leveldb at dir
+func writeLevelDB(dir string, o *opt.Options, entries map[string]string) error {
+	db, err := leveldb.OpenFile(dir, o)
+	if err != nil {
+		return err
+	}
+	for k, v := range entries {
+		if err := db.Put([]byte(k), []byte(v), nil); err != nil {
+			db.Close()
+			return fmt.Errorf("put %q into %s: %w", k, dir, err)
+		}
+	}
+	return db.Close()
+}
+
+// utf16LE returns s in utf-16 little endian
+func utf16LE(s string) string {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return string(b)
+}
+
+// chromiumLocalStorage writes leveldb keys as chromium does, META:origin
+// and _origin\x00key, keys and values are prefixed with their encoding,
+// 0 for utf-16 and 1 for latin-1.
+func chromiumLocalStorage(profile string, _ []byte) error {
+	return writeLevelDB(filepath.Join(profile, "Local Storage", "leveldb"), nil, map[string]string{
+		"VERSION":                                 "1",
+		"META:https://go.dev":                     "\x08\x80\x80\x80\x80\x80\x80\x80\x01\x10\x0a",
+		"METAACCESS:https://go.dev":               "\x08\x80\x80\x80\x80\x80\x80\x80\x01",
+		"_https://go.dev\x00\x01theme":            "\x01dark",
+		"_https://go.dev\x00\x01visited":          "\x01true",
+		"_https://go.dev\x00\x01cafe":             "\x01caf\xe9",
+		"_https://go.dev\x00\x00" + utf16LE("言語"): "\x00" + utf16LE("日本語"),
+	})
+}
+
+// chromiumSessionStorage writes two tabs sharing the storage map of go.dev,
+// keys of maps are utf-8 and values utf-16
+func chromiumSessionStorage(profile string, _ []byte) error {
+	return writeLevelDB(filepath.Join(profile, "Session Storage"), nil, map[string]string{
+		"version":     "1",
+		"next-map-id": "1",
+		"namespace-0e6e2d5a_47c8_4e2b_9c0e_4f3c8e2c1a11-https://go.dev/": "0",
+		"namespace-5b1f7c0d_9a2e_4c3b_8d4f_1e2a3b4c5d6e-https://go.dev/": "0",
+		"map-0-tour-page": utf16LE("welcome/1"),
+	})
+}
+
+// idbComparer names the comparator of chromium's IndexedDB, goleveldb won't
+// open the leveldb with another one
+type idbComparer struct {
+	comparer.Comparer
+}
+
+func (idbComparer) Name() string {
+	return "idb_cmp1"
+}
+
+// v8 values of the fixture written by v8.serialize of node, the blink
+// envelope is added to them
+const (
+	// {title:"Groceries",done:false,tags:["milk","日本"],created:new Date(1672628645000)}
+	v8Groceries = "ff0f6f22057469746c65220947726f6365726965732204646f6e6546220474616773410222046d696c6b6304e5652c67240002220763726561746564440080c8fa065778427b04"
+	// {body:"half written"}
+	v8Draft = "ff0f6f2204626f6479220c68616c66207772697474656e7b01"
+)
+
+// idbString returns s as a StringWithLength of IndexedDB keys, its length in
+// utf-16 units followed by the units in big endian
+func idbString(s string) string {
+	u := utf16BE(s)
+	return string(binary.AppendUvarint(nil, uint64(len(u)/2))) + u
+}
+
+// chromiumIndexedDB writes the notes database of go.dev, its object store
+// notes holds a record of a number key, a compressed record of a string key
+// and a record kept in a blob.
+func chromiumIndexedDB(profile string, _ []byte) error {
+	v8 := func(s string) string {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			panic(err)
+		}
+		// blink version 21 with its trailer offset and size
+		return "\xff\x15\xfe" + string(make([]byte, 12)) + string(b)
+	}
+	number := make([]byte, 8)
+	binary.LittleEndian.PutUint64(number, 0x3ff0000000000000) // 1.0
+	entries := map[string]string{
+		// schema version and the name of database 1
+		"\x00\x00\x00\x00\x00": "\x05",
+		"\x00\x00\x00\x00\xc9" + idbString("https_go.dev_0@1") + idbString("notes"): "\x01",
+		// name of object store 1 of database 1
+		"\x00\x01\x00\x00\x32\x01\x00": utf16BE("notes"),
+		// records of object store 1, values are prefixed with their version
+		"\x00\x01\x01\x01\x03" + string(number):         "\x01" + v8(v8Groceries),
+		"\x00\x01\x01\x01\x01" + idbString("draft"):     "\x01\xff\x11\x02" + string(snappy.Encode(nil, []byte(v8(v8Draft)))),
+		"\x00\x01\x01\x01\x01" + idbString("recording"): "\x01\xff\x11\x01\x80\x80\x04\x00",
+	}
+	dir := filepath.Join(profile, "IndexedDB", "https_go.dev_0.indexeddb.leveldb")
+	if err := writeLevelDB(dir, &opt.Options{Comparer: idbComparer{comparer.DefaultComparer}}, entries); err != nil {
+		return err
+	}
+	// blobs are left out of the copy
+	return writeFile(filepath.Join(profile, "IndexedDB", "https_go.dev_0.indexeddb.blob", "1", "00", "1"), "blob")
+}
+
+// utf16BE returns s in utf-16 big endian
+func utf16BE(s string) string {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.BigEndian.AppendUint16(b, u)
+	}
+	return string(b)
+}
+
+// structured clones of the fixture of Firefox, pairs of a tag and data in
+// little endian after the header
+const (
+	// {title:"Groceries",done:false,tags:["milk","日本"]}
+	cloneGroceries = "040000000000f1ff000000000800ffff050000800400ffff7469746c65000000090000800400ffff47726f63657269657300000000000000040000800400ffff646f6e6500000000000000000200ffff040000800400ffff7461677300000000020000000700ffff000000000300ffff040000800400ffff6d696c6b00000000010000000300ffff020000000400ffffe5652c6700000000000000001300ffff000000001300ffff"
+	// {body:"half written"}
+	cloneDraft = "040000000000f1ff000000000800ffff040000800400ffff626f6479000000000c0000800400ffff68616c66207772697474656e00000000000000001300ffff"
+)
+
+// firefoxStorage writes storage/default of Firefox 68 and later, go.dev
+// keeps local storage in the current schema, one value is compressed, and
+// the notes database of IndexedDB. www.mozilla.org keeps local storage in
+// the first schema without its origin.
+func firefoxStorage(dir string, _ []byte) error {
+	storage := filepath.Join(dir, "storage", "default")
+	err := writeDB(filepath.Join(storage, "https+++go.dev", "ls", "data.sqlite"),
+		stmt{query: `CREATE TABLE database (origin TEXT NOT NULL, usage INTEGER NOT NULL DEFAULT 0,
+			last_vacuum_time INTEGER NOT NULL DEFAULT 0, last_analyze_time INTEGER NOT NULL DEFAULT 0,
+			last_vacuum_size INTEGER NOT NULL DEFAULT 0)`},
+		stmt{query: `INSERT INTO database (origin) VALUES ('https://go.dev')`},
+		stmt{query: `CREATE TABLE data (key TEXT PRIMARY KEY, utf16_length INTEGER NOT NULL,
+			conversion_type INTEGER NOT NULL, compression_type INTEGER NOT NULL,
+			last_access_time INTEGER NOT NULL DEFAULT 0, value BLOB NOT NULL)`},
+		stmt{
+			query: `INSERT INTO data (key, utf16_length, conversion_type, compression_type, value) VALUES (?, ?, 1, ?, ?)`,
+			args:  []any{"theme", 4, 0, []byte("dark")},
+		},
+		stmt{
+			query: `INSERT INTO data (key, utf16_length, conversion_type, compression_type, value) VALUES (?, ?, 1, ?, ?)`,
+			args:  []any{"tour", 22, 1, snappy.Encode(nil, []byte("welcome/1 welcome/1 日本"))},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	err = writeDB(filepath.Join(storage, "https+++www.mozilla.org", "ls", "data.sqlite"),
+		stmt{query: `CREATE TABLE data (key TEXT PRIMARY KEY, value TEXT NOT NULL, utf16_length INTEGER NOT NULL,
+			compressed INTEGER NOT NULL, last_access_time INTEGER NOT NULL DEFAULT 0)`},
+		stmt{query: `INSERT INTO data (key, value, utf16_length, compressed) VALUES ('locale', 'en-US', 5, 0)`},
+	)
+	if err != nil {
+		return err
+	}
+	clone := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			panic(err)
+		}
+		return snappy.Encode(nil, b)
+	}
+	err = writeDB(filepath.Join(storage, "https+++go.dev", "idb", "3310687404nsoet.sqlite"),
+		stmt{query: `CREATE TABLE database (name TEXT PRIMARY KEY, origin TEXT NOT NULL, version INTEGER NOT NULL DEFAULT 0,
+			last_vacuum_time INTEGER NOT NULL DEFAULT 0, last_analyze_time INTEGER NOT NULL DEFAULT 0,
+			last_vacuum_size INTEGER NOT NULL DEFAULT 0)`},
+		stmt{query: `INSERT INTO database (name, origin, version) VALUES ('notes', 'https://go.dev', 1)`},
+		stmt{query: `CREATE TABLE object_store (id INTEGER PRIMARY KEY, auto_increment INTEGER NOT NULL DEFAULT 0,
+			name TEXT NOT NULL, key_path TEXT)`},
+		stmt{query: `INSERT INTO object_store (id, name) VALUES (1, 'notes')`},
+		stmt{query: `CREATE TABLE object_data (object_store_id INTEGER NOT NULL, key BLOB NOT NULL,
+			index_data_values BLOB DEFAULT NULL, file_ids TEXT, data BLOB NOT NULL,
+			PRIMARY KEY (object_store_id, key))`},
+		// keys are the number 1 and the string draft
+		stmt{
+			query: `INSERT INTO object_data (object_store_id, key, data) VALUES (1, ?, ?), (1, ?, ?)`,
+			args:  []any{[]byte("\x10\xbf\xf0"), clone(cloneGroceries), []byte("\x30esbgu"), clone(cloneDraft)},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	// files of values and caches are left out of the copy
+	return writeFile(filepath.Join(storage, "https+++go.dev", "idb", "3310687404nsoet.files", "1"), "blob")
+}
@@ -0,0 +1,119 @@
+// Package testfixture builds chromium and firefox profiles with known
+// contents and keys from Go code, so parsers are tested end to end without
+// a real browser.
+package testfixture
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/pbkdf2"
+
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ChromiumLinuxKey is the key of chromium on Linux without a keyring,
+// derived from the hardcoded secret "peanuts"
+var ChromiumLinuxKey = pbkdf2.Key([]byte("peanuts"), []byte("saltysalt"), 1, 16, sha1.New)
+
+// ChromiumWindowsKey is a 32 bytes key like the DPAPI decrypted
+// os_crypt.encrypted_key of chromium on Windows
+var ChromiumWindowsKey = []byte("0123456789abcdef0123456789abcdef")
+
+var errKeyLength = errors.New("chromium key must be 16 or 32 bytes")
+
+// EncryptChromium returns the v10 blob of plaintext, AES-128-CBC for the 16
+// bytes key of macOS and Linux, AES-256-GCM for the 32 bytes key of Windows.
+func EncryptChromium(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	blob := []byte("v10")
+	switch len(key) {
+	case 16:
+		iv := bytes.Repeat([]byte{' '}, aes.BlockSize)
+		padded := pkcs7Pad(plaintext, aes.BlockSize)
+		encrypted := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, padded)
+		return append(blob, encrypted...), nil
+	case 32:
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		// a fixed nonce keeps fixtures reproducible, it's never reused with real data
+		nonce := make([]byte, gcm.NonceSize())
+		blob = append(blob, nonce...)
+		return gcm.Seal(blob, nonce, plaintext, nil), nil
+	default:
+		return nil, errKeyLength
+	}
+}
+
+func pkcs7Pad(src []byte, blockSize int) []byte {
+	n := blockSize - len(src)%blockSize
+	return append(append([]byte{}, src...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// stmt is a statement run by writeDB, args bind its placeholders
+type stmt struct {
+	query string
+	args  []any
+}
+
+// writeDB creates the sqlite database at path and runs stmts in it
+func writeDB(path string, stmts ...stmt) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for _, s := range stmts {
+		if _, err := db.Exec(s.query, s.args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0o600)
+}
+
+// FirefoxOSKey is a 32 bytes key like the one firefox keeps in the OS key
+// store, FirefoxOSKeyBase64 is its secret in the key store
+var (
+	FirefoxOSKey       = []byte("fedcba9876543210fedcba9876543210")
+	FirefoxOSKeyBase64 = base64.StdEncoding.EncodeToString(FirefoxOSKey)
+)
+
+// EncryptFirefoxOSKeyStore returns plaintext encrypted with FirefoxOSKey as
+// firefox does, base64 of the IV followed by the AES-256-GCM ciphertext
+func EncryptFirefoxOSKeyStore(plaintext []byte) (string, error) {
+	block, err := aes.NewCipher(FirefoxOSKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	// a fixed nonce keeps fixtures reproducible, it's never reused with real data
+	nonce := make([]byte, gcm.NonceSize())
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
@@ -0,0 +1,186 @@
+// Package bdbutil reads hash files of Berkeley DB 1.85, the format of
+// key3.db kept by Firefox 57 and earlier.
+// @https://github.com/nss-dev/nss/tree/master/lib/dbm/src
+package bdbutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	hashMagic   = 0x061561
+	hashVersion = 2
+	// headerSize is the size of the fixed fields of the header, followed
+	// by the spares of split points
+	headerSize = 68
+	nCached    = 32
+
+	bigEndian = 4321
+
+	// a data offset below realKey marks an overflow page or a big pair
+	ovflPage = 0
+	realKey  = 4
+
+	splitShift = 11
+	splitMask  = 0x7ff
+)
+
+var (
+	errNotHash     = errors.New("not a Berkeley DB 1.85 hash file")
+	errCorruptPage = errors.New("corrupt page")
+	// errBigPair means a pair is larger than a page, key3.db never has one
+	errBigPair = errors.New("big key/data pairs are not supported")
+)
+
+// header is the part of HASHHDR needed to walk the buckets
+type header struct {
+	order      binary.ByteOrder
+	bsize      int
+	maxBucket  int
+	hdrPages   int
+	spares     [nCached]int
+	pageCount  int
+	pageLength int
+}
+
+// ReadHash returns the key/data pairs of the hash file at path
+func ReadHash(path string) (map[string][]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHash(b)
+}
+
+// ParseHash returns the key/data pairs of the hash file b
+func ParseHash(b []byte) (map[string][]byte, error) {
+	h, err := parseHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make(map[string][]byte)
+	// a bucket page is at least its number, buckets past the file were never written
+	for bucket := 0; bucket <= h.maxBucket && bucket < h.pageCount; bucket++ {
+		if err := h.readBucket(b, bucket, pairs); err != nil {
+			return nil, fmt.Errorf("bucket %d: %w", bucket, err)
+		}
+	}
+	return pairs, nil
+}
+
+// parseHeader reads the header, it's big endian whatever the byte order
+// of the pages is
+func parseHeader(b []byte) (*header, error) {
+	if len(b) < headerSize+nCached*4 {
+		return nil, errNotHash
+	}
+	field := func(i int) int {
+		return int(int32(binary.BigEndian.Uint32(b[i*4:])))
+	}
+	if field(0) != hashMagic || field(1) != hashVersion {
+		return nil, errNotHash
+	}
+	h := &header{
+		order:     binary.LittleEndian,
+		bsize:     field(3),
+		maxBucket: field(10),
+		hdrPages:  field(15),
+	}
+	if field(2) == bigEndian {
+		h.order = binary.BigEndian
+	}
+	// offsets in a page are 16 bits
+	if h.bsize < 256 || h.bsize > 1<<16 || h.bsize&(h.bsize-1) != 0 {
+		return nil, fmt.Errorf("%w: page size %d", errNotHash, h.bsize)
+	}
+	if h.maxBucket < 0 || h.hdrPages < 1 {
+		return nil, fmt.Errorf("%w: %d buckets after %d header pages", errNotHash, h.maxBucket+1, h.hdrPages)
+	}
+	for i := range h.spares {
+		h.spares[i] = field(headerSize/4 + i)
+	}
+	h.pageCount = (len(b) + h.bsize - 1) / h.bsize
+	h.pageLength = len(b)
+	return h, nil
+}
+
+// bucketPage returns the page of bucket, overflow pages of earlier split
+// points are in between buckets
+func (h *header) bucketPage(bucket int) int {
+	page := bucket + h.hdrPages
+	if bucket > 0 {
+		page += h.spares[log2(bucket+1)-1]
+	}
+	return page
+}
+
+// overflowPage returns the page of the overflow address oaddr
+func (h *header) overflowPage(oaddr uint16) int {
+	split := int(oaddr >> splitShift)
+	return h.bucketPage(1<<split-1) + int(oaddr&splitMask)
+}
+
+// log2 returns the smallest i with 1<<i >= n, as __log2 of Berkeley DB
+func log2(n int) int {
+	i := 0
+	for 1<<i < n {
+		i++
+	}
+	return i
+}
+
+// readBucket adds the pairs of bucket and its overflow pages to pairs
+func (h *header) readBucket(b []byte, bucket int, pairs map[string][]byte) error {
+	visited := make(map[int]bool)
+	for page := h.bucketPage(bucket); page >= 0; {
+		if visited[page] {
+			return fmt.Errorf("%w: overflow pages loop at %d", errCorruptPage, page)
+		}
+		visited[page] = true
+		next, err := h.readPage(b, page, pairs)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+		page = next
+	}
+	return nil
+}
+
+// readPage adds the pairs of page to pairs and returns the overflow page
+// following it, -1 if there is none. Keys and data are stored from the
+// end of the page down, each one ends where the previous one starts:
+//
+//	| n | key off | data off | ... | free space | offset | ... | data | key |
+func (h *header) readPage(b []byte, page int, pairs map[string][]byte) (int, error) {
+	start := page * h.bsize
+	if start < 0 || start >= h.pageLength {
+		return -1, nil
+	}
+	p := make([]byte, h.bsize)
+	copy(p, b[start:])
+	bp := func(i int) int {
+		return int(h.order.Uint16(p[i*2:]))
+	}
+	n := bp(0)
+	if n%2 != 0 || (n+3)*2 > h.bsize {
+		return -1, fmt.Errorf("%w: %d offsets", errCorruptPage, n)
+	}
+	end := h.bsize
+	for i := 1; i < n; i += 2 {
+		keyOff, dataOff := bp(i), bp(i+1)
+		switch {
+		case dataOff == ovflPage:
+			return h.overflowPage(uint16(keyOff)), nil
+		case dataOff < realKey:
+			return -1, errBigPair
+		case dataOff > keyOff || keyOff > end || dataOff < (n+3)*2:
+			return -1, fmt.Errorf("%w: pair at %d-%d-%d", errCorruptPage, dataOff, keyOff, end)
+		}
+		pairs[string(p[keyOff:end])] = p[dataOff:keyOff]
+		end = dataOff
+	}
+	return -1, nil
+}
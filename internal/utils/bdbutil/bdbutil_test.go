@@ -0,0 +1,83 @@
+package bdbutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"hack-browser-data/internal/testfixture"
+)
+
+func writeHash(t testing.TB, pairs map[string][]byte, pageSize int) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key3.db")
+	if err := testfixture.BerkeleyDB(path, pairs, pageSize); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseHash(t *testing.T) {
+	small := map[string][]byte{
+		"global-salt":    bytes.Repeat([]byte{1}, 20),
+		"password-check": bytes.Repeat([]byte{2}, 51),
+		"Version":        {3},
+	}
+	// more pairs than two pages hold, the buckets continue on overflow pages
+	large := make(map[string][]byte)
+	for i := 0; i < 40; i++ {
+		large[fmt.Sprintf("key-%02d", i)] = bytes.Repeat([]byte{byte(i)}, 30)
+	}
+	for name, pairs := range map[string]map[string][]byte{"small": small, "overflow": large, "empty": {}} {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseHash(writeHash(t, pairs, 256))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, pairs) {
+				t.Errorf("ParseHash() = %v, want %v", got, pairs)
+			}
+		})
+	}
+}
+
+func TestParseHashErrors(t *testing.T) {
+	b := writeHash(t, map[string][]byte{"a": {1}}, 256)
+	loop := append([]byte{}, b...)
+	// bucket 0 points to itself as overflow page 0 of split point 0
+	copy(loop[256:], []byte{4, 0, 255, 0, 254, 0, 0, 0, 0, 0})
+
+	tests := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{name: "sqlite", b: []byte("SQLite format 3\x00"), err: errNotHash},
+		{name: "truncated", b: b[:100], err: errNotHash},
+		{name: "loop", b: loop, err: errCorruptPage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseHash(tt.b); !errors.Is(err, tt.err) {
+				t.Errorf("ParseHash() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// FuzzParseHash only requires ParseHash not to panic, run with
+// go test -fuzz=FuzzParseHash
+func FuzzParseHash(f *testing.F) {
+	f.Add(writeHash(f, map[string][]byte{"global-salt": {1}, "Version": {3}}, 256))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = ParseHash(b)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	cp "github.com/otiai10/copy"
 )
@@ -59,10 +60,15 @@ func ReadFile(filename string) (string, error) {
 }
 
 // CopyDir copies the directory from the source to the destination
-// skip the file if you don't want to copy
-func CopyDir(src, dst, skip string) error {
+// skip the files and dirs with any of the suffixes if you don't want to copy
+func CopyDir(src, dst string, skip ...string) error {
 	s := cp.Options{Skip: func(src string) (bool, error) {
-		return strings.HasSuffix(strings.ToLower(src), skip), nil
+		for _, suffix := range skip {
+			if strings.HasSuffix(strings.ToLower(src), suffix) {
+				return true, nil
+			}
+		}
+		return false, nil
 	}}
 	return cp.Copy(src, dst, s)
 }
@@ -94,6 +100,67 @@ func CopyDirHasSuffix(src, dst, suffix string) error {
 	return nil
 }
 
+// CopyDirMatch copies the files of src whose slash separated path relative
+// to src matches pattern, like */ls/data.sqlite, into the same path of dst
+func CopyDirMatch(src, dst, pattern string) error {
+	if err := os.MkdirAll(dst, 0o700); err != nil {
+		return err
+	}
+	return filepath.Walk(src, func(p string, f os.FileInfo, err error) error {
+		if err != nil || f.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		if ok, _ := path.Match(pattern, filepath.ToSlash(rel)); !ok {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return err
+		}
+		return CopyFile(p, target)
+	})
+}
+
+// workDirs are the dirs of MkdirWork not removed yet
+var workDirs = struct {
+	sync.Mutex
+	dirs map[string]struct{}
+}{dirs: make(map[string]struct{})}
+
+// MkdirWork creates a temp dir for the copies of browser files, remove
+// removes it. Dirs left by a run that is interrupted are removed by
+// RemoveWorkDirs.
+func MkdirWork() (dir string, remove func(), err error) {
+	dir, err = os.MkdirTemp("", "hack-browser-data-")
+	if err != nil {
+		return "", nil, err
+	}
+	workDirs.Lock()
+	workDirs.dirs[dir] = struct{}{}
+	workDirs.Unlock()
+	remove = func() {
+		workDirs.Lock()
+		delete(workDirs.dirs, dir)
+		workDirs.Unlock()
+		os.RemoveAll(dir)
+	}
+	return dir, remove, nil
+}
+
+// RemoveWorkDirs removes the dirs of MkdirWork not removed yet
+func RemoveWorkDirs() {
+	workDirs.Lock()
+	defer workDirs.Unlock()
+	for dir := range workDirs.dirs {
+		os.RemoveAll(dir)
+		delete(workDirs.dirs, dir)
+	}
+}
+
 // CopyFile copies the file from the source to the destination
 func CopyFile(src, dst string) error {
 	s, err := os.ReadFile(src)
@@ -0,0 +1,194 @@
+// Package leveldbutil reads the records of a leveldb dir from its tables and
+// logs, without the manifest and whatever the comparator is. Chromium keeps
+// IndexedDB in leveldb ordered by a comparator of its own, which goleveldb
+// refuses to open.
+package leveldbutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb/journal"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/table"
+)
+
+// Record is a key and its value
+type Record struct {
+	Key   []byte
+	Value []byte
+}
+
+const (
+	// internal keys end with the sequence number and type of the record
+	internalKeyTail = 8
+	batchHeaderSize = 12
+
+	typeDeletion = 0
+	typeValue    = 1
+)
+
+// entry is the latest record of a key, deleted if del is set
+type entry struct {
+	seq   uint64
+	del   bool
+	value []byte
+}
+
+// Read returns the live records of the leveldb at dir ordered bytewise by
+// key, the latest record of a key is the one with the highest sequence
+// number. Files that can't be read are skipped and named in the error.
+func Read(dir string) ([]Record, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]entry)
+	var failed []string
+	for _, f := range files {
+		var read func(path string, entries map[string]entry) error
+		switch filepath.Ext(f.Name()) {
+		case ".ldb", ".sst":
+			read = readTable
+		case ".log":
+			read = readLog
+		default:
+			continue
+		}
+		if err := read(filepath.Join(dir, f.Name()), entries); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", f.Name(), err))
+		}
+	}
+	records := make([]Record, 0, len(entries))
+	for k, e := range entries {
+		if !e.del {
+			records = append(records, Record{Key: []byte(k), Value: e.value})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return bytes.Compare(records[i].Key, records[j].Key) < 0
+	})
+	if len(failed) > 0 {
+		return records, fmt.Errorf("read leveldb %s failed: %s", dir, strings.Join(failed, ", "))
+	}
+	return records, nil
+}
+
+// put keeps the record of key if it's newer than the one in entries
+func put(entries map[string]entry, key []byte, e entry) {
+	if old, ok := entries[string(key)]; ok && old.seq > e.seq {
+		return
+	}
+	entries[string(key)] = e
+}
+
+// readTable adds the records of the table at path, keys of a table are
+// internal keys
+func readTable(path string, entries map[string]entry) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := table.NewReader(f, info.Size(), storage.FileDesc{Type: storage.TypeTable}, nil, nil, &opt.Options{})
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	iter := r.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		ik := iter.Key()
+		if len(ik) < internalKeyTail {
+			continue
+		}
+		n := len(ik) - internalKeyTail
+		num := binary.LittleEndian.Uint64(ik[n:])
+		e := entry{seq: num >> 8, del: num&0xff == typeDeletion}
+		if !e.del {
+			// the iterator reuses its buffers
+			e.value = append([]byte{}, iter.Value()...)
+		}
+		put(entries, ik[:n], e)
+	}
+	return iter.Error()
+}
+
+// readLog adds the records of the write ahead log at path, it's a journal
+// of batches. A torn batch at the end is the one being written when the
+// browser exited, it's left out.
+func readLog(path string, entries map[string]entry) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	jr := journal.NewReader(f, nil, false, true)
+	for {
+		r, err := jr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		batch, err := io.ReadAll(r)
+		if err != nil {
+			return nil
+		}
+		readBatch(batch, entries)
+	}
+}
+
+// readBatch adds the records of batch, a sequence number and a count
+// followed by the records, each one numbered from the sequence number
+func readBatch(batch []byte, entries map[string]entry) {
+	if len(batch) < batchHeaderSize {
+		return
+	}
+	seq := binary.LittleEndian.Uint64(batch)
+	count := binary.LittleEndian.Uint32(batch[8:])
+	b := batch[batchHeaderSize:]
+	for i := uint32(0); i < count && len(b) > 0; i++ {
+		kind := b[0]
+		b = b[1:]
+		key, ok := lengthPrefixed(&b)
+		if !ok {
+			return
+		}
+		e := entry{seq: seq + uint64(i), del: kind == typeDeletion}
+		if kind == typeValue {
+			value, ok := lengthPrefixed(&b)
+			if !ok {
+				return
+			}
+			e.value = append([]byte{}, value...)
+		} else if kind != typeDeletion {
+			return
+		}
+		put(entries, key, e)
+	}
+}
+
+// lengthPrefixed returns the bytes of b prefixed with their varint length
+// and moves b past them
+func lengthPrefixed(b *[]byte) ([]byte, bool) {
+	n, size := binary.Uvarint(*b)
+	if size <= 0 || n > uint64(len(*b)-size) {
+		return nil, false
+	}
+	v := (*b)[size : size+int(n)]
+	*b = (*b)[size+int(n):]
+	return v, true
+}
@@ -0,0 +1,49 @@
+package leveldbutil
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	db, err := leveldb.OpenFile(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := db.Put([]byte(fmt.Sprintf("k%03d", i)), []byte(fmt.Sprintf("v%d", i)), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the records above are moved into tables, the ones below stay in the log
+	if err := db.CompactRange(util.Range{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put([]byte("k001"), []byte("updated"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete([]byte("k002"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 99 {
+		t.Fatalf("read %d records, want 99", len(records))
+	}
+	want := []struct{ key, value string }{{"k000", "v0"}, {"k001", "updated"}, {"k003", "v3"}}
+	for i, w := range want {
+		if string(records[i].Key) != w.key || string(records[i].Value) != w.value {
+			t.Errorf("record %d = %s: %s, want %s: %s", i, records[i].Key, records[i].Value, w.key, w.value)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Package lz4util decodes LZ4 blocks and the mozLz4 files of Firefox, an
+// LZ4 block after a magic and the decoded size.
+// @https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md
+package lz4util
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	mozLz4Magic = "mozLz40\x00"
+	minMatch    = 4
+	// maxRatio bounds the decoded size of a block, a byte of a block
+	// decodes to at most 255 bytes
+	maxRatio = 255
+)
+
+var (
+	errNotMozLz4 = errors.New("not a mozLz4 file")
+	errCorrupt   = errors.New("corrupt lz4 block")
+)
+
+// ReadMozLz4 returns the decoded content of the mozLz4 file at path
+func ReadMozLz4(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMozLz4(b)
+}
+
+// DecodeMozLz4 returns the decoded content of the mozLz4 file b
+func DecodeMozLz4(b []byte) ([]byte, error) {
+	if len(b) < len(mozLz4Magic)+4 || !bytes.HasPrefix(b, []byte(mozLz4Magic)) {
+		return nil, errNotMozLz4
+	}
+	size := binary.LittleEndian.Uint32(b[len(mozLz4Magic):])
+	return DecodeBlock(b[len(mozLz4Magic)+4:], int(size))
+}
+
+// DecodeBlock decodes the LZ4 block src, which decodes to size bytes
+func DecodeBlock(src []byte, size int) ([]byte, error) {
+	if size < 0 || size > len(src)*maxRatio {
+		return nil, fmt.Errorf("%w: %d bytes decoded from %d", errCorrupt, size, len(src))
+	}
+	dst := make([]byte, 0, size)
+	for i := 0; i < len(src); {
+		token := int(src[i])
+		i++
+		// literals are followed by a match, but the last ones
+		literals, err := length(src, &i, token>>4)
+		if err != nil {
+			return nil, err
+		}
+		if literals > len(src)-i || literals > size-len(dst) {
+			return nil, fmt.Errorf("%w: %d literals at %d", errCorrupt, literals, i)
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			break
+		}
+		if i+2 > len(src) {
+			return nil, fmt.Errorf("%w: truncated offset at %d", errCorrupt, i)
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		match, err := length(src, &i, token&0xf)
+		if err != nil {
+			return nil, err
+		}
+		match += minMatch
+		if offset == 0 || offset > len(dst) || match > size-len(dst) {
+			return nil, fmt.Errorf("%w: match of %d at offset %d", errCorrupt, match, offset)
+		}
+		// a match may overlap the bytes it copies, they're copied one by one
+		start := len(dst) - offset
+		for j := 0; j < match; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+	if len(dst) != size {
+		return nil, fmt.Errorf("%w: decoded %d bytes, want %d", errCorrupt, len(dst), size)
+	}
+	return dst, nil
+}
+
+// length returns n, plus the bytes following it at *i if n is 15
+func length(src []byte, i *int, n int) (int, error) {
+	if n != 0xf {
+		return n, nil
+	}
+	for {
+		if *i >= len(src) {
+			return 0, fmt.Errorf("%w: truncated length", errCorrupt)
+		}
+		b := int(src[*i])
+		*i++
+		n += b
+		if b != 0xff {
+			return n, nil
+		}
+	}
+}
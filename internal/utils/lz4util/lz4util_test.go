@@ -0,0 +1,72 @@
+package lz4util
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"hack-browser-data/internal/testfixture"
+)
+
+func TestDecodeBlock(t *testing.T) {
+	tests := []struct {
+		name string
+		src  []byte
+		want string
+	}{
+		{name: "literals", src: append([]byte{0x50}, "hello"...), want: "hello"},
+		// "abc" then a match of 9 bytes at offset 3, overlapping its copy
+		{name: "overlap", src: []byte{0x35, 'a', 'b', 'c', 3, 0}, want: "abcabcabcabc"},
+		{name: "long match", src: []byte{0x1f, 'a', 1, 0, 16, 0x10, 'b'}, want: "a" + string(bytes.Repeat([]byte("a"), 35)) + "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeBlock(tt.src, len(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("DecodeBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMozLz4(t *testing.T) {
+	content := bytes.Repeat([]byte(`{"windows":[]}`), 100)
+	got, err := DecodeMozLz4(testfixture.MozLz4(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("DecodeMozLz4() = %q, want %q", got, content)
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{name: "json", b: []byte(`{"windows":[]}`), err: errNotMozLz4},
+		{name: "truncated", b: testfixture.MozLz4(content)[:100], err: errCorrupt},
+		{name: "offset before start", b: append([]byte("mozLz40\x00\x08\x00\x00\x00"), 0x00, 4, 0), err: errCorrupt},
+		{name: "size bomb", b: append([]byte("mozLz40\x00\xff\xff\xff\x7f"), 0x10, 'a'), err: errCorrupt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeMozLz4(tt.b); !errors.Is(err, tt.err) {
+				t.Errorf("DecodeMozLz4() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// FuzzDecodeBlock only requires DecodeBlock not to panic, run with
+// go test -fuzz=FuzzDecodeBlock
+func FuzzDecodeBlock(f *testing.F) {
+	f.Add([]byte{0x35, 'a', 'b', 'c', 3, 0}, 12)
+	f.Add(testfixture.MozLz4([]byte("hello"))[12:], 5)
+	f.Fuzz(func(t *testing.T, src []byte, size int) {
+		_, _ = DecodeBlock(src, size)
+	})
+}
@@ -0,0 +1,215 @@
+// Package sqliteutil picks queries that fit the schema of a browser database,
+// so a renamed or missing column in an older or newer build doesn't lose
+// the whole artifact.
+package sqliteutil
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the schema of a database, Version is read from the meta table
+// of chromium databases or PRAGMA user_version of firefox ones, it's 0 if
+// neither is set.
+type Schema struct {
+	Version int
+
+	db      *sql.DB
+	columns map[string]map[string]bool
+}
+
+// Column is a column of a Query, a column with Default is optional, the
+// default SQL literal is selected instead when it's missing or NULL.
+type Column struct {
+	// Table is the alias of the joined table of the column, it's empty for
+	// columns of Query.Table
+	Table   string
+	Name    string
+	Default string
+	// Expr is selected instead of the column if it's set, like MAX(d.date)
+	Expr string
+}
+
+// Query selects Columns of Table
+type Query struct {
+	Table string
+	// Alias names Table in Columns and Suffix
+	Alias string
+	// Joins are the tables joined by Suffix by their alias, their columns
+	// are checked like the ones of Table
+	Joins   map[string]string
+	Columns []Column
+	// Suffix follows FROM Table, like a JOIN or WHERE clause
+	Suffix string
+}
+
+const (
+	queryMetaVersion = `SELECT value FROM meta WHERE key = ?`
+	queryUserVersion = `PRAGMA user_version`
+)
+
+// ReadSchema reads the version of db, columns are read on demand
+func ReadSchema(db *sql.DB) (*Schema, error) {
+	s := &Schema{db: db, columns: make(map[string]map[string]bool)}
+	meta, err := s.Columns("meta")
+	if err != nil {
+		return nil, err
+	}
+	if len(meta) > 0 {
+		s.Version = s.metaVersion("version")
+		return s, nil
+	}
+	if err := db.QueryRow(queryUserVersion).Scan(&s.Version); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// metaVersion returns the version stored in meta, 0 if it's absent
+func (s *Schema) metaVersion(key string) int {
+	var v int
+	if err := s.db.QueryRow(queryMetaVersion, key).Scan(&v); err != nil {
+		return 0
+	}
+	return v
+}
+
+// Columns returns the set of column names of table, it's empty if the
+// table doesn't exist.
+func (s *Schema) Columns(table string) (map[string]bool, error) {
+	if c, ok := s.columns[table]; ok {
+		return c, nil
+	}
+	rows, err := s.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s.columns[table] = columns
+	return columns, nil
+}
+
+// Select returns the SELECT of the first query the schema fits, queries
+// are ordered from the newest. A query fits if the schema has all its
+// required columns.
+func (s *Schema) Select(queries ...Query) (string, error) {
+	var missing []string
+	for _, q := range queries {
+		tables, m, err := s.tables(q)
+		if err != nil {
+			return "", err
+		}
+		if len(m) == 0 {
+			m = q.missing(tables)
+		}
+		if len(m) > 0 {
+			missing = append(missing, m...)
+			continue
+		}
+		return q.selectFrom(tables), nil
+	}
+	return "", fmt.Errorf("schema version %d not supported, missing %s", s.Version, strings.Join(missing, ", "))
+}
+
+// Query runs the SELECT picked by Select
+func (s *Schema) Query(queries ...Query) (*sql.Rows, error) {
+	query, err := s.Select(queries...)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.Query(query)
+}
+
+// tables returns the columns of the tables of q by their alias, Table is
+// under the empty alias, and the tables that don't exist
+func (s *Schema) tables(q Query) (map[string]map[string]bool, []string, error) {
+	names := map[string]string{"": q.Table}
+	for alias, table := range q.Joins {
+		names[alias] = table
+	}
+	tables := make(map[string]map[string]bool, len(names))
+	var missing []string
+	for alias, table := range names {
+		columns, err := s.Columns(table)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(columns) == 0 {
+			missing = append(missing, "table "+table)
+		}
+		tables[alias] = columns
+	}
+	sort.Strings(missing)
+	return tables, missing, nil
+}
+
+func (q Query) missing(tables map[string]map[string]bool) []string {
+	var l []string
+	for _, c := range q.Columns {
+		if c.Default == "" && !tables[c.Table][c.Name] {
+			l = append(l, q.tableName(c)+"."+c.Name)
+		}
+	}
+	return l
+}
+
+// tableName returns the name of the table of c
+func (q Query) tableName(c Column) string {
+	if c.Table == "" {
+		return q.Table
+	}
+	return q.Joins[c.Table]
+}
+
+// expr returns what's selected for c if it exists
+func (q Query) expr(c Column) string {
+	switch {
+	case c.Expr != "":
+		return c.Expr
+	case c.Table != "":
+		return c.Table + "." + c.Name
+	case q.Alias != "":
+		return q.Alias + "." + c.Name
+	}
+	return c.Name
+}
+
+func (q Query) selectFrom(tables map[string]map[string]bool) string {
+	l := make([]string, 0, len(q.Columns))
+	for _, c := range q.Columns {
+		switch {
+		case c.Default == "":
+			l = append(l, q.expr(c))
+		case tables[c.Table][c.Name]:
+			l = append(l, fmt.Sprintf("IFNULL(%s, %s)", q.expr(c), c.Default))
+		default:
+			l = append(l, fmt.Sprintf("%s AS %s", c.Default, c.Name))
+		}
+	}
+	from := q.Table
+	if q.Alias != "" {
+		from += " " + q.Alias
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(l, ", "), from)
+	if q.Suffix != "" {
+		query += " " + q.Suffix
+	}
+	return query
+}
@@ -0,0 +1,137 @@
+package sqliteutil
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	// import sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T, stmts ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, s := range stmts {
+		if _, err := db.Exec(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+var testQueries = []Query{
+	{
+		Table:   "t",
+		Columns: []Column{{Name: "new_name"}, {Name: "extra", Default: "0"}},
+	},
+	{
+		Table:   "t",
+		Columns: []Column{{Name: "old_name"}, {Name: "extra", Default: "0"}},
+	},
+}
+
+func TestSchemaSelect(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		stmts   []string
+		version int
+		want    string
+	}{
+		{
+			name:    "meta version with optional column",
+			stmts:   []string{`CREATE TABLE meta (key TEXT, value TEXT)`, `INSERT INTO meta VALUES ('version', '12'), ('last_compatible_version', '11')`, `CREATE TABLE t (new_name TEXT, extra INTEGER)`},
+			version: 12,
+			want:    "SELECT new_name, IFNULL(extra, 0) FROM t",
+		},
+		{
+			name:    "old version falls back to older query",
+			stmts:   []string{`CREATE TABLE meta (key TEXT, value TEXT)`, `INSERT INTO meta VALUES ('version', '9')`, `CREATE TABLE t (old_name TEXT)`},
+			version: 9,
+			want:    "SELECT old_name, 0 AS extra FROM t",
+		},
+		{
+			name:    "no version falls back to columns",
+			stmts:   []string{`CREATE TABLE t (old_name TEXT)`},
+			version: 0,
+			want:    "SELECT old_name, 0 AS extra FROM t",
+		},
+		{
+			name:    "user_version",
+			stmts:   []string{`PRAGMA user_version = 14`, `CREATE TABLE t (new_name TEXT)`},
+			version: 14,
+			want:    "SELECT new_name, 0 AS extra FROM t",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			schema, err := ReadSchema(openDB(t, tt.stmts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if schema.Version != tt.version {
+				t.Errorf("Version = %d, want %d", schema.Version, tt.version)
+			}
+			got, err := schema.Select(testQueries...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Select() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaSelectUnsupported(t *testing.T) {
+	t.Parallel()
+	schema, err := ReadSchema(openDB(t, `CREATE TABLE t (other TEXT)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schema.Select(testQueries...); err == nil {
+		t.Error("Select() of a table without the required columns returned no error")
+	}
+}
+
+func TestSchemaSelectJoin(t *testing.T) {
+	t.Parallel()
+	queries := []Query{{
+		Table: "a",
+		Alias: "x",
+		Joins: map[string]string{"y": "b"},
+		Columns: []Column{
+			{Name: "id"}, {Table: "y", Name: "name", Default: "''"},
+			{Table: "y", Name: "date", Expr: "MAX(y.date)", Default: "0"}, {Table: "y", Name: "size", Default: "0"},
+		},
+		Suffix: "LEFT JOIN b y ON y.a_id = x.id GROUP BY x.id",
+	}}
+	schema, err := ReadSchema(openDB(t, `CREATE TABLE a (id INTEGER)`, `CREATE TABLE b (a_id INTEGER, name TEXT, date INTEGER)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := schema.Select(queries...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT x.id, IFNULL(y.name, ''), IFNULL(MAX(y.date), 0), 0 AS size FROM a x LEFT JOIN b y ON y.a_id = x.id GROUP BY x.id"
+	if got != want {
+		t.Errorf("Select() = %q, want %q", got, want)
+	}
+
+	schema, err = ReadSchema(openDB(t, `CREATE TABLE a (id INTEGER)`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schema.Select(queries...); err == nil || !strings.Contains(err.Error(), "table b") {
+		t.Errorf("Select() without the joined table error = %v, want missing table b", err)
+	}
+}
@@ -1,7 +1,9 @@
 package typeutil
 
 import (
+	"encoding/binary"
 	"time"
+	"unicode/utf16"
 
 	"golang.org/x/exp/constraints"
 )
@@ -51,3 +53,22 @@ func TimeEpoch(epoch int64) time.Time {
 	}
 	return t
 }
+
+// Latin1 returns the string of the latin-1 bytes b
+func Latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// UTF16LE returns the string of the utf-16 little endian bytes b, an odd
+// last byte is dropped
+func UTF16LE(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,351 @@
+// Package hbd is the public API of hack-browser-data, it discovers browsers,
+// extracts their browsing data and writes it out, the CLI is built on it.
+package hbd
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"hack-browser-data/internal/browingdata"
+	"hack-browser-data/internal/browser"
+	"hack-browser-data/internal/keyprovider"
+	"hack-browser-data/internal/log"
+	"hack-browser-data/internal/masterkey"
+	"hack-browser-data/internal/provider"
+	"hack-browser-data/internal/utils/fileutil"
+)
+
+// Browser is a browser profile found by Browsers
+type Browser struct {
+	b browser.Browser
+}
+
+// Name is the name of the browser and its profile, like chrome-default
+func (b Browser) Name() string {
+	return b.b.Name()
+}
+
+// Profile is the profile dir of the browser
+func (b Browser) Profile() string {
+	return b.b.Profile()
+}
+
+// Keys is the key material supplied by the caller, it's read by the static
+// key provider. ProfileOS is only used in offline mode, PasswordStore
+// selects the stores of the keystore provider on Linux.
+type Keys struct {
+	// ChromiumKey is the raw AES key of chromium, 16 bytes on macOS and Linux,
+	// 32 bytes on Windows (the DPAPI decrypted os_crypt.encrypted_key). On
+	// Linux it's the key of v11 blobs, v10 blobs use the hardcoded key.
+	ChromiumKey []byte
+	// SafeStorage is the secret stored in the keychain or secret service
+	// as "Chrome Safe Storage" and the like, the key is derived from it.
+	SafeStorage []byte
+	// ProfileOS is the OS the profiles were copied from, linux, darwin or windows
+	ProfileOS string
+	// PasswordStore is basic, gnome-libsecret, kwallet5 or kwallet6, every
+	// store is tried if it's empty
+	PasswordStore string
+	// FirefoxPassword is the primary password of firefox profiles
+	FirefoxPassword []byte
+	// FirefoxOSKey is the key of "Firefox Encrypted Storage" in the OS key
+	// store, newer firefox encrypts card numbers with it
+	FirefoxOSKey []byte
+}
+
+// SetChromiumKey sets ChromiumKey from a hex string, an empty string is ignored
+func (k *Keys) SetChromiumKey(s string) error {
+	m := k.material()
+	if err := m.SetChromiumKey(s); err != nil {
+		return err
+	}
+	k.ChromiumKey = m.ChromiumKey
+	return nil
+}
+
+// SetFirefoxOSKey sets FirefoxOSKey from a base64 string, an empty string is ignored
+func (k *Keys) SetFirefoxOSKey(s string) error {
+	m := k.material()
+	if err := m.SetFirefoxOSKey(s); err != nil {
+		return err
+	}
+	k.FirefoxOSKey = m.FirefoxOSKey
+	return nil
+}
+
+func (k *Keys) material() *masterkey.Material {
+	return &masterkey.Material{
+		ChromiumKey:     k.ChromiumKey,
+		SafeStorage:     k.SafeStorage,
+		ProfileOS:       k.ProfileOS,
+		PasswordStore:   k.PasswordStore,
+		FirefoxPassword: k.FirefoxPassword,
+		FirefoxOSKey:    k.FirefoxOSKey,
+	}
+}
+
+// names of the key providers, see Options.KeyProviders
+const (
+	KeyProviderStatic   = keyprovider.NameStatic
+	KeyProviderFile     = keyprovider.NameFile
+	KeyProviderEnv      = keyprovider.NameEnv
+	KeyProviderKeystore = keyprovider.NameKeystore
+	KeyProviderBasic    = keyprovider.NameBasic
+	KeyProviderPrompt   = keyprovider.NamePrompt
+)
+
+// Options controls which browsers are picked and how results are written.
+type Options struct {
+	// Browser is the browser name, see ListBrowsers, all by default
+	Browser string
+	// ProfilePath is a custom profile dir path
+	ProfilePath string
+	// OfflineDir parses profiles copied under the dir instead of the
+	// installed browsers, keys never come from the OS keystore
+	OfflineDir string
+	Keys       Keys
+	// KeyFile is the key material json read by the file key provider
+	KeyFile string
+	// KeyProviders are the names of key providers tried in order, static,
+	// file, env, keystore (left out offline) and basic by default. The
+	// report of a browser records the provider its key came from.
+	KeyProviders []string
+	// OutputDir is the dir of exported files, results by default
+	OutputDir string
+	// Format is the format of exported files, csv, json, jsonl or sqlite.
+	// Rows of all browsers are streamed to results.jsonl or results.db
+	// while parsed by jsonl and sqlite, in the order of browsers and
+	// items. Rows parsed ahead of that order are held until written.
+	Format string
+	// CookieFormats are cookie files written besides the cookie file of
+	// every browser, netscape (cookies.txt of curl and wget) or editor
+	// (JSON of cookie editor extensions). Streaming formats don't have them.
+	CookieFormats []string
+	// Compress compresses OutputDir to a zip file
+	Compress bool
+	// Concurrency is how many browsers and sources are processed at the
+	// same time, the number of CPUs by default. With a streamed Format the
+	// rows of a browser or source done before the ones ahead of it are
+	// kept until they're done, 10000 rows each in memory and the rest in a
+	// temp file.
+	Concurrency int
+}
+
+const (
+	defaultBrowser   = "all"
+	defaultOutputDir = "results"
+	defaultFormat    = "csv"
+)
+
+// ListBrowsers returns the names of supported browsers on this platform
+func ListBrowsers() []string {
+	return provider.ListBrowsers()
+}
+
+// Browsers returns the browser profiles picked by opts, ordered by name
+func Browsers(opts Options) ([]Browser, error) {
+	opts.setDefault()
+	keys, err := opts.keyChain()
+	if err != nil {
+		return nil, err
+	}
+	var picked []browser.Browser
+	if opts.OfflineDir != "" {
+		picked, err = provider.PickOfflineBrowsers(opts.Browser, opts.OfflineDir, keys)
+	} else {
+		picked, err = provider.PickBrowsers(opts.Browser, opts.ProfilePath, keys)
+	}
+	browsers := make([]Browser, 0, len(picked))
+	for _, b := range picked {
+		browsers = append(browsers, Browser{b: b})
+	}
+	sort.SliceStable(browsers, func(i, j int) bool {
+		return browsers[i].Name() < browsers[j].Name()
+	})
+	return browsers, err
+}
+
+// Extract returns the browsing data of b as typed records, Result.Items
+// tells which items failed.
+func Extract(b Browser) (*Result, error) {
+	data, err := b.b.BrowsingData(nil)
+	if err != nil {
+		return nil, err
+	}
+	return newResult(b, data), nil
+}
+
+// Export picks browsers, extracts their browsing data and writes it to
+// files under opts.OutputDir, with a Report of the run in ReportFile.
+// The error is only set if the run could not be done, failed browsers
+// and items are in the Report.
+func Export(opts Options) (*Report, error) {
+	return ExportContext(context.Background(), opts)
+}
+
+// ExportContext is Export stopped by ctx, browsers not started when ctx is
+// done are left out and reported with the error of ctx. Running browsers
+// are finished and written, the error is the one of ctx then.
+func ExportContext(ctx context.Context, opts Options) (*Report, error) {
+	opts.setDefault()
+	output := browingdata.NewOutPutter(opts.Format)
+	if err := output.SetCookieFormats(opts.CookieFormats); err != nil {
+		return nil, err
+	}
+	browsers, err := Browsers(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(browsers) == 0 {
+		log.Notice("no browser found")
+	}
+	browingdata.SetConcurrency(opts.Concurrency)
+	if output.Stream() && len(output.CookieFormats()) > 0 {
+		log.Warnf("cookie formats are not written with %s format", opts.Format)
+	}
+	var stream browingdata.StreamWriter
+	// rows of browsers are streamed in the order of browsers
+	var seq *browingdata.Sequence
+	if output.Stream() {
+		if stream, err = output.OpenStream(opts.OutputDir); err != nil {
+			return nil, err
+		}
+		sinks := make([]browingdata.Sink, len(browsers))
+		for i, b := range browsers {
+			sinks[i] = stream.Sink(b.Name(), b.Profile())
+		}
+		seq = browingdata.NewSequence(sinks)
+	}
+	// browsers are processed by a pool of workers, but written in order
+	type result struct {
+		data *browingdata.Data
+		err  error
+	}
+	done := make([]chan result, len(browsers))
+	for i := range done {
+		done[i] = make(chan result, 1)
+	}
+	jobs := make(chan int)
+	for w := 0; w < opts.Concurrency; w++ {
+		go func() {
+			for i := range jobs {
+				var sink browingdata.Sink
+				if seq != nil {
+					sink = seq.Sink(i)
+				}
+				data, err := browsers[i].b.BrowsingData(sink)
+				if err != nil {
+					log.Errorf("%s browsing data error: %s", browsers[i].Name(), err)
+				}
+				if seq != nil {
+					if err := seq.Done(i); err != nil {
+						log.Errorf("%s write rows error: %s", browsers[i].Name(), err)
+					}
+				}
+				done[i] <- result{data: data, err: err}
+			}
+		}()
+	}
+	start := func(i int) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		select {
+		case jobs <- i:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		defer close(jobs)
+		i := 0
+		for i < len(browsers) && start(i) {
+			i++
+		}
+		// browsers left are not started once ctx is done
+		for ; i < len(browsers); i++ {
+			if seq != nil {
+				if err := seq.Done(i); err != nil {
+					log.Errorf("%s write rows error: %s", browsers[i].Name(), err)
+				}
+			}
+			done[i] <- result{err: ctx.Err()}
+		}
+	}()
+	report := &Report{Browsers: make([]BrowserReport, 0, len(browsers))}
+	for i, b := range browsers {
+		r := <-done[i]
+		report.Browsers = append(report.Browsers, newBrowserReport(b, r.data, r.err))
+		if r.data == nil || stream != nil {
+			continue
+		}
+		r.data.Output(opts.OutputDir, b.Name(), output)
+	}
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			return nil, err
+		}
+		log.Noticef("output to file %s success", filepath.Join(opts.OutputDir, output.StreamFile()))
+	}
+	report.setStatus()
+	var compressErr error
+	if opts.Compress {
+		if compressErr = fileutil.CompressDir(opts.OutputDir); compressErr == nil {
+			log.Noticef("compress success")
+		}
+	}
+	// the report is written last to stay next to the compressed results
+	if err := report.write(opts.OutputDir); err != nil {
+		return report, err
+	}
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	return report, compressErr
+}
+
+// keyChain returns the chain of key providers of o
+func (o *Options) keyChain() (keyprovider.Chain, error) {
+	return keyprovider.New(keyprovider.Config{
+		Providers: o.KeyProviders,
+		Keys:      o.Keys.material(),
+		KeyFile:   o.KeyFile,
+		Offline:   o.OfflineDir != "",
+	})
+}
+
+func (o *Options) setDefault() {
+	if o.Browser == "" {
+		o.Browser = defaultBrowser
+	}
+	if o.OutputDir == "" {
+		o.OutputDir = defaultOutputDir
+	}
+	if o.Format == "" {
+		o.Format = defaultFormat
+	}
+	if o.Concurrency < 1 {
+		o.Concurrency = runtime.NumCPU()
+	}
+}
+
+// LoadKeyFile reads Keys from a JSON file with optional hex encoded
+// chromium_key, safe_storage, profile_os, password_store, firefox_password
+// and base64 encoded firefox_os_key.
+func LoadKeyFile(filename string) (*Keys, error) {
+	m, err := masterkey.LoadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{
+		ChromiumKey:     m.ChromiumKey,
+		SafeStorage:     m.SafeStorage,
+		ProfileOS:       m.ProfileOS,
+		PasswordStore:   m.PasswordStore,
+		FirefoxPassword: m.FirefoxPassword,
+		FirefoxOSKey:    m.FirefoxOSKey,
+	}, nil
+}
@@ -0,0 +1,202 @@
+package hbd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hack-browser-data/internal/testfixture"
+)
+
+// offlineDir writes two chromium user data dirs and a firefox profile under
+// a temp dir, chromium keys are static
+func offlineDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"chrome", "edge"} {
+		if err := testfixture.Chromium(filepath.Join(dir, name), testfixture.ChromiumWindowsKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := testfixture.Firefox(filepath.Join(dir, "firefox", "abcd1234.default-release"), nil); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestExportStreamOrder(t *testing.T) {
+	dir := offlineDir(t)
+	for _, format := range []string{"jsonl", "sqlite"} {
+		format := format
+		t.Run(format, func(t *testing.T) {
+			var runs [2][]byte
+			for n := range runs {
+				opts := Options{
+					OfflineDir:   dir,
+					Keys:         Keys{ChromiumKey: testfixture.ChromiumWindowsKey, ProfileOS: "windows"},
+					KeyProviders: []string{KeyProviderStatic},
+					OutputDir:    t.TempDir(),
+					Format:       format,
+					Concurrency:  4,
+				}
+				if _, err := Export(opts); err != nil {
+					t.Fatal(err)
+				}
+				b, err := dumpStream(opts.OutputDir, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				runs[n] = b
+			}
+			if len(runs[0]) == 0 {
+				t.Fatal("no rows streamed")
+			}
+			if !bytes.Equal(runs[0], runs[1]) {
+				t.Errorf("streamed rows differ between runs\nfirst:\n%s\nsecond:\n%s", runs[0], runs[1])
+			}
+		})
+	}
+}
+
+// dumpStream returns the streamed rows under dir, tables of results.db are
+// dumped in rowid order
+func dumpStream(dir, format string) ([]byte, error) {
+	if format == "jsonl" {
+		return os.ReadFile(filepath.Join(dir, "results.jsonl"))
+	}
+	return dumpSQLite(filepath.Join(dir, "results.db"))
+}
+
+func dumpSQLite(filename string) ([]byte, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var tables []string
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	for _, table := range tables {
+		rows, err := db.Query(`SELECT * FROM "` + table + `" ORDER BY rowid`)
+		if err != nil {
+			return nil, err
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		values := make([]any, len(columns))
+		for i := range values {
+			values[i] = new(any)
+		}
+		for rows.Next() {
+			if err := rows.Scan(values...); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			fmt.Fprint(&out, table)
+			for _, v := range values {
+				fmt.Fprintf(&out, " %v", *v.(*any))
+			}
+			out.WriteByte('\n')
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func TestExportContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := ExportContext(ctx, Options{
+		OfflineDir:   offlineDir(t),
+		KeyProviders: []string{KeyProviderStatic},
+		OutputDir:    t.TempDir(),
+		Format:       "jsonl",
+		Concurrency:  1,
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ExportContext() error = %v, want %v", err, context.Canceled)
+	}
+	if len(report.Browsers) != 3 {
+		t.Fatalf("report has %d browsers, want 3", len(report.Browsers))
+	}
+	for _, b := range report.Browsers {
+		if b.Error != context.Canceled.Error() {
+			t.Errorf("browser %s error = %q, want %q", b.Browser, b.Error, context.Canceled)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	browsers, err := Browsers(Options{
+		OfflineDir:   offlineDir(t),
+		Keys:         Keys{ChromiumKey: testfixture.ChromiumWindowsKey, ProfileOS: "windows"},
+		KeyProviders: []string{KeyProviderStatic},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// browsers are ordered by name, chrome comes first
+	if len(browsers) != 3 || browsers[0].Name() != "chrome_default" {
+		t.Fatalf("picked %d browsers, want chrome_default first", len(browsers))
+	}
+	result, err := Extract(browsers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.KeyProvider != KeyProviderStatic {
+		t.Errorf("key provider = %q, want %q", result.KeyProvider, KeyProviderStatic)
+	}
+	for _, i := range result.Items {
+		if i.Failed() {
+			t.Errorf("item %s status = %s, error %q", i.Item, i.Status, i.Error)
+		}
+	}
+	want := Password{
+		UserName:      "alice",
+		Password:      "correct horse",
+		LoginURL:      "https://accounts.example.com/login",
+		DecryptStatus: DecryptOK,
+	}
+	var found bool
+	for _, p := range result.Passwords {
+		if p.LoginURL == want.LoginURL {
+			found = true
+			p.CreateDate = want.CreateDate
+			if p != want {
+				t.Errorf("password = %+v, want %+v", p, want)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("password of %s not extracted from %d passwords", want.LoginURL, len(result.Passwords))
+	}
+	if len(result.Cookies) == 0 || len(result.History) == 0 {
+		t.Errorf("extracted %d cookies and %d visits, want some", len(result.Cookies), len(result.History))
+	}
+}
@@ -0,0 +1,311 @@
+package hbd
+
+import (
+	"time"
+
+	"hack-browser-data/internal/browingdata/address"
+	"hack-browser-data/internal/browingdata/autofill"
+	"hack-browser-data/internal/browingdata/bookmark"
+	"hack-browser-data/internal/browingdata/cookie"
+	"hack-browser-data/internal/browingdata/creditcard"
+	"hack-browser-data/internal/browingdata/download"
+	"hack-browser-data/internal/browingdata/extension"
+	"hack-browser-data/internal/browingdata/history"
+	"hack-browser-data/internal/browingdata/indexeddb"
+	"hack-browser-data/internal/browingdata/localstorage"
+	"hack-browser-data/internal/browingdata/password"
+	"hack-browser-data/internal/browingdata/searchterm"
+	"hack-browser-data/internal/browingdata/session"
+)
+
+// DecryptStatus tells why an encrypted value is empty if it could not be
+// decrypted
+type DecryptStatus string
+
+const (
+	DecryptOK DecryptStatus = "ok"
+	// DecryptPlain means the value was not encrypted
+	DecryptPlain             DecryptStatus = "plain"
+	DecryptWrongKey          DecryptStatus = "wrong-key"
+	DecryptCorruptBlob       DecryptStatus = "corrupt-blob"
+	DecryptUnsupportedPrefix DecryptStatus = "unsupported-prefix"
+	// DecryptUnsupportedAlgorithm means the OID of a firefox blob is unknown
+	DecryptUnsupportedAlgorithm DecryptStatus = "unsupported-algorithm"
+	// DecryptFailed is any other error, like a missing key
+	DecryptFailed DecryptStatus = "failed"
+)
+
+// Password is a saved login
+type Password struct {
+	UserName      string
+	Password      string
+	LoginURL      string
+	CreateDate    time.Time
+	DecryptStatus DecryptStatus
+}
+
+// Cookie is a cookie of a host
+type Cookie struct {
+	Host         string
+	Path         string
+	KeyName      string
+	Value        string
+	IsSecure     bool
+	IsHTTPOnly   bool
+	HasExpire    bool
+	IsPersistent bool
+	CreateDate   time.Time
+	ExpireDate   time.Time
+	// SameSite is unspecified, none, lax or strict
+	SameSite string
+	// Priority is low, medium or high, chromium only
+	Priority string
+	// PartitionKey is the top level site of a partitioned (CHIPS) cookie
+	PartitionKey string
+	// SourceScheme is unset, non_secure or secure, chromium only
+	SourceScheme string
+	// SourcePort is -1 if unspecified, chromium only
+	SourcePort     int
+	LastAccessDate time.Time
+	// OriginAttributes isolate the cookie by container or first party, firefox only
+	OriginAttributes string
+	DecryptStatus    DecryptStatus
+}
+
+// Visit is a single visit, not a single URL. Visits of the same URL share
+// the per-URL counters (VisitCount, TypedCount, LastVisitTime).
+type Visit struct {
+	VisitID       int64
+	Title         string
+	URL           string
+	VisitTime     time.Time
+	Transition    string
+	FromVisitID   int64
+	ReferrerURL   string
+	VisitDuration int64 // milliseconds, chromium only
+	VisitCount    int
+	TypedCount    int
+	LastVisitTime time.Time
+}
+
+// Download is a file downloaded by the browser
+type Download struct {
+	TargetPath string
+	URL        string
+	TotalBytes int64
+	StartTime  time.Time
+	EndTime    time.Time
+	MimeType   string
+}
+
+// Bookmark is a bookmark or a bookmark folder, Type tells them apart
+type Bookmark struct {
+	ID        int64
+	Name      string
+	Type      string
+	URL       string
+	DateAdded time.Time
+}
+
+// CreditCard is a saved credit card, Address is the id of its billing address
+type CreditCard struct {
+	GUID            string
+	Name            string
+	ExpirationYear  string
+	ExpirationMonth string
+	CardNumber      string
+	Address         string
+	NickName        string
+	DecryptStatus   DecryptStatus
+}
+
+// Address is an address saved for autofill
+type Address struct {
+	GUID         string
+	Name         string
+	Organization string
+	Street       string
+	City         string
+	State        string
+	PostalCode   string
+	Country      string
+	Phone        string
+	Email        string
+	ModifyDate   time.Time
+}
+
+// Autofill is a value typed into a form field, Name is the name of the
+// field. Firefox keeps them as form history.
+type Autofill struct {
+	Name         string
+	Value        string
+	Count        int
+	CreateDate   time.Time
+	LastUsedDate time.Time
+}
+
+// Session is an entry of the navigation stack of a tab, the tab is open
+// or closed in a window of the session File.
+type Session struct {
+	File     string
+	WindowID int
+	TabID    int
+	// TabIndex is the position of the tab in its window
+	TabIndex        int
+	Pinned          bool
+	NavigationIndex int
+	// Current is the navigation the tab shows, the one back and forward
+	// navigations start from
+	Current  bool
+	URL      string
+	Title    string
+	Referrer string // chromium only
+	// NavigationTime is the time of the navigation, chromium only
+	NavigationTime time.Time
+	LastActiveTime time.Time
+	Closed         bool
+	ClosedTime     time.Time
+}
+
+// SearchTerm is a search of a search engine, URL is its result page
+type SearchTerm struct {
+	Term          string
+	URL           string
+	VisitCount    int
+	LastVisitTime time.Time
+}
+
+// LocalStorage is an item of the local or session storage of an origin
+type LocalStorage struct {
+	URL   string
+	Key   string
+	Value string
+}
+
+// IndexedDB is a record of an object store, Key and Value are json. Raw is
+// the value as stored if it can't be decoded, like values kept in blobs.
+type IndexedDB struct {
+	URL         string
+	Database    string
+	ObjectStore string
+	Key         string
+	Value       string
+	Raw         []byte
+}
+
+// Extension is an installed extension
+type Extension struct {
+	Name        string
+	Description string
+	Version     string
+	HomepageURL string
+}
+
+// FirefoxPosture tells how key4.db protects the saved logins
+type FirefoxPosture struct {
+	PrimaryPassword bool
+	Algorithm       string
+	OID             string
+	Iterations      int
+	KeySize         int
+	LegacyNSSPBE    bool
+}
+
+// convert returns the rows converted by fn
+func convert[T, R any](rows []T, fn func(T) R) []R {
+	l := make([]R, 0, len(rows))
+	for _, row := range rows {
+		l = append(l, fn(row))
+	}
+	return l
+}
+
+func newPassword(l password.LoginData) Password {
+	return Password{
+		UserName:      l.UserName,
+		Password:      l.Password,
+		LoginURL:      l.LoginURL,
+		CreateDate:    l.CreateDate,
+		DecryptStatus: DecryptStatus(l.DecryptStatus),
+	}
+}
+
+func newCookie(c cookie.Cookie) Cookie {
+	return Cookie{
+		Host:             c.Host,
+		Path:             c.Path,
+		KeyName:          c.KeyName,
+		Value:            c.Value,
+		IsSecure:         c.IsSecure,
+		IsHTTPOnly:       c.IsHTTPOnly,
+		HasExpire:        c.HasExpire,
+		IsPersistent:     c.IsPersistent,
+		CreateDate:       c.CreateDate,
+		ExpireDate:       c.ExpireDate,
+		SameSite:         c.SameSite,
+		Priority:         c.Priority,
+		PartitionKey:     c.PartitionKey,
+		SourceScheme:     c.SourceScheme,
+		SourcePort:       c.SourcePort,
+		LastAccessDate:   c.LastAccessDate,
+		OriginAttributes: c.OriginAttributes,
+		DecryptStatus:    DecryptStatus(c.DecryptStatus),
+	}
+}
+
+func newVisit(v history.Visit) Visit {
+	return Visit(v)
+}
+
+func newDownload(d download.Download) Download {
+	return Download(d)
+}
+
+func newBookmark(b bookmark.Bookmark) Bookmark {
+	return Bookmark(b)
+}
+
+func newCreditCard(c creditcard.Card) CreditCard {
+	return CreditCard{
+		GUID:            c.GUID,
+		Name:            c.Name,
+		ExpirationYear:  c.ExpirationYear,
+		ExpirationMonth: c.ExpirationMonth,
+		CardNumber:      c.CardNumber,
+		Address:         c.Address,
+		NickName:        c.NickName,
+		DecryptStatus:   DecryptStatus(c.DecryptStatus),
+	}
+}
+
+func newAddress(a address.Address) Address {
+	return Address(a)
+}
+
+func newAutofill(e autofill.Entry) Autofill {
+	return Autofill(e)
+}
+
+func newSession(n session.Navigation) Session {
+	return Session(n)
+}
+
+func newSearchTerm(s searchterm.SearchTerm) SearchTerm {
+	return SearchTerm(s)
+}
+
+func newLocalStorage(s localstorage.Storage) LocalStorage {
+	return LocalStorage(s)
+}
+
+func newIndexedDB(r indexeddb.Record) IndexedDB {
+	return IndexedDB(r)
+}
+
+func newExtension(e *extension.Extension) Extension {
+	return Extension(*e)
+}
+
+func newFirefoxPosture(p password.Posture) FirefoxPosture {
+	return FirefoxPosture(p)
+}
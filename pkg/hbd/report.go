@@ -0,0 +1,126 @@
+package hbd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"hack-browser-data/internal/browingdata"
+)
+
+// ReportFile is the name of the run report written under Options.OutputDir
+const ReportFile = "report.json"
+
+// ItemReport is the outcome of an item of a browser profile
+type ItemReport struct {
+	Item   string     `json:"item"`
+	Status ItemStatus `json:"status"`
+	Rows   int        `json:"rows"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// Failed reports whether the item was found but not fully recovered
+func (r ItemReport) Failed() bool {
+	return r.Status == StatusDecryptFailed || r.Status == StatusParseFailed
+}
+
+// ItemStatus is the status of an ItemReport
+type ItemStatus string
+
+const (
+	// StatusOK means the item was parsed, it may have no rows
+	StatusOK ItemStatus = "ok"
+	// StatusSkipped means the item is not supported
+	StatusSkipped ItemStatus = "skipped"
+	// StatusMissing means the file of the item is not in the profile
+	StatusMissing ItemStatus = "missing"
+	// StatusDecryptFailed means the item was parsed but some rows could not be decrypted
+	StatusDecryptFailed ItemStatus = "decrypt-failed"
+	// StatusParseFailed means the item could not be read
+	StatusParseFailed ItemStatus = "parse-failed"
+)
+
+// RunStatus is the overall outcome of Export
+type RunStatus string
+
+const (
+	// RunOK means every item found was recovered
+	RunOK RunStatus = "ok"
+	// RunPartial means some items or browsers failed, others were recovered
+	RunPartial RunStatus = "partial"
+	// RunFailed means nothing was recovered
+	RunFailed RunStatus = "failed"
+)
+
+// Report lists every browser profile of a run and the outcome of its items
+type Report struct {
+	Status   RunStatus       `json:"status"`
+	Browsers []BrowserReport `json:"browsers"`
+}
+
+// BrowserReport is the outcome of a browser profile, Error is set if the
+// profile could not be processed at all, like a master key error.
+// KeyProvider names the key provider the master key came from.
+type BrowserReport struct {
+	Browser     string       `json:"browser"`
+	Profile     string       `json:"profile"`
+	KeyProvider string       `json:"key_provider,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Items       []ItemReport `json:"items"`
+}
+
+func newBrowserReport(b Browser, data *browingdata.Data, err error) BrowserReport {
+	r := BrowserReport{Browser: b.Name(), Profile: b.Profile(), Items: []ItemReport{}}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if data != nil {
+		r.Items = newItemReports(data)
+		r.KeyProvider = data.KeyProvider()
+	}
+	return r
+}
+
+func newItemReports(data *browingdata.Data) []ItemReport {
+	return convert(data.Report(), func(r browingdata.ItemReport) ItemReport {
+		return ItemReport{Item: r.Item, Status: ItemStatus(r.Status), Rows: r.Rows, Error: r.Error}
+	})
+}
+
+// setStatus sets the run status from the browsers, a run without any
+// recovered item is failed.
+func (r *Report) setStatus() {
+	var ok, failed int
+	for _, b := range r.Browsers {
+		if b.Error != "" {
+			failed++
+		}
+		for _, i := range b.Items {
+			switch {
+			case i.Status == StatusOK:
+				ok++
+			case i.Failed():
+				failed++
+			}
+		}
+	}
+	switch {
+	case ok == 0:
+		r.Status = RunFailed
+	case failed > 0:
+		r.Status = RunPartial
+	default:
+		r.Status = RunOK
+	}
+}
+
+func (r *Report) write(dir string) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ReportFile), b, 0o600)
+}
@@ -0,0 +1,110 @@
+package hbd
+
+import (
+	"hack-browser-data/internal/browingdata"
+	"hack-browser-data/internal/browingdata/address"
+	"hack-browser-data/internal/browingdata/autofill"
+	"hack-browser-data/internal/browingdata/bookmark"
+	"hack-browser-data/internal/browingdata/cookie"
+	"hack-browser-data/internal/browingdata/creditcard"
+	"hack-browser-data/internal/browingdata/download"
+	"hack-browser-data/internal/browingdata/extension"
+	"hack-browser-data/internal/browingdata/history"
+	"hack-browser-data/internal/browingdata/indexeddb"
+	"hack-browser-data/internal/browingdata/localstorage"
+	"hack-browser-data/internal/browingdata/password"
+	"hack-browser-data/internal/browingdata/searchterm"
+	"hack-browser-data/internal/browingdata/session"
+)
+
+// Result is the browsing data of one browser profile
+type Result struct {
+	Browser        string
+	Profile        string
+	KeyProvider    string
+	Items          []ItemReport
+	Passwords      []Password
+	Cookies        []Cookie
+	History        []Visit
+	Downloads      []Download
+	Bookmarks      []Bookmark
+	CreditCards    []CreditCard
+	Addresses      []Address
+	Autofill       []Autofill
+	Sessions       []Session
+	SearchTerms    []SearchTerm
+	LocalStorage   []LocalStorage
+	SessionStorage []LocalStorage
+	IndexedDB      []IndexedDB
+	Extensions     []Extension
+	FirefoxPosture []FirefoxPosture
+}
+
+func newResult(b Browser, data *browingdata.Data) *Result {
+	r := &Result{Browser: b.Name(), Profile: b.Profile(), Items: newItemReports(data), KeyProvider: data.KeyProvider()}
+	for _, source := range data.Sources() {
+		switch s := source.(type) {
+		case *password.ChromiumPassword:
+			r.Passwords = append(r.Passwords, convert(*s, newPassword)...)
+		case *password.YandexPassword:
+			r.Passwords = append(r.Passwords, convert(*s, newPassword)...)
+		case *password.FirefoxPassword:
+			r.Passwords = append(r.Passwords, convert(*s, newPassword)...)
+		case *password.FirefoxPosture:
+			r.FirefoxPosture = append(r.FirefoxPosture, convert(*s, newFirefoxPosture)...)
+		case *cookie.ChromiumCookie:
+			r.Cookies = append(r.Cookies, convert(*s, newCookie)...)
+		case *cookie.FirefoxCookie:
+			r.Cookies = append(r.Cookies, convert(*s, newCookie)...)
+		case *history.ChromiumHistory:
+			r.History = append(r.History, convert(*s, newVisit)...)
+		case *history.FirefoxHistory:
+			r.History = append(r.History, convert(*s, newVisit)...)
+		case *download.ChromiumDownload:
+			r.Downloads = append(r.Downloads, convert(*s, newDownload)...)
+		case *download.FirefoxDownload:
+			r.Downloads = append(r.Downloads, convert(*s, newDownload)...)
+		case *bookmark.ChromiumBookmark:
+			r.Bookmarks = append(r.Bookmarks, convert(*s, newBookmark)...)
+		case *bookmark.FirefoxBookmark:
+			r.Bookmarks = append(r.Bookmarks, convert(*s, newBookmark)...)
+		case *creditcard.ChromiumCreditCard:
+			r.CreditCards = append(r.CreditCards, convert(*s, newCreditCard)...)
+		case *creditcard.YandexCreditCard:
+			r.CreditCards = append(r.CreditCards, convert(*s, newCreditCard)...)
+		case *creditcard.FirefoxCreditCard:
+			r.CreditCards = append(r.CreditCards, convert(*s, newCreditCard)...)
+		case *address.ChromiumAddress:
+			r.Addresses = append(r.Addresses, convert(*s, newAddress)...)
+		case *autofill.ChromiumAutofill:
+			r.Autofill = append(r.Autofill, convert(*s, newAutofill)...)
+		case *autofill.FirefoxFormHistory:
+			r.Autofill = append(r.Autofill, convert(*s, newAutofill)...)
+		case *searchterm.ChromiumSearchTerm:
+			r.SearchTerms = append(r.SearchTerms, convert(*s, newSearchTerm)...)
+		case *searchterm.FirefoxSearchTerm:
+			r.SearchTerms = append(r.SearchTerms, convert(*s, newSearchTerm)...)
+		case *session.ChromiumSession:
+			r.Sessions = append(r.Sessions, convert(*s, newSession)...)
+		case *session.FirefoxSession:
+			r.Sessions = append(r.Sessions, convert(*s, newSession)...)
+		case *address.FirefoxAddress:
+			r.Addresses = append(r.Addresses, convert(*s, newAddress)...)
+		case *localstorage.ChromiumLocalStorage:
+			r.LocalStorage = append(r.LocalStorage, convert(*s, newLocalStorage)...)
+		case *localstorage.FirefoxLocalStorage:
+			r.LocalStorage = append(r.LocalStorage, convert(*s, newLocalStorage)...)
+		case *localstorage.ChromiumSessionStorage:
+			r.SessionStorage = append(r.SessionStorage, convert(*s, newLocalStorage)...)
+		case *indexeddb.ChromiumIndexedDB:
+			r.IndexedDB = append(r.IndexedDB, convert(*s, newIndexedDB)...)
+		case *indexeddb.FirefoxIndexedDB:
+			r.IndexedDB = append(r.IndexedDB, convert(*s, newIndexedDB)...)
+		case *extension.ChromiumExtension:
+			r.Extensions = append(r.Extensions, convert(*s, newExtension)...)
+		case *extension.FirefoxExtension:
+			r.Extensions = append(r.Extensions, convert(*s, newExtension)...)
+		}
+	}
+	return r
+}